@@ -16,6 +16,7 @@ import (
 	"auth-service/internal/infrastructure/persistence"
 	"shared/pkg/database"
 	sharedEvents "shared/pkg/events"
+	"shared/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -47,6 +48,18 @@ import (
 // @description JWT Authorization header using the Bearer scheme. Example: "Bearer {token}"
 
 func main() {
+	// Initialize tracing
+	shutdownTracer, err := tracing.InitTracer(tracing.Config{
+		ServiceName: "auth-service",
+		Environment: getEnv("ENVIRONMENT", "development"),
+		JaegerAgent: getEnv("JAEGER_ENDPOINT", "http://localhost:14268/api/traces"),
+		Enabled:     getEnv("ENABLE_TRACING", "false") == "true",
+	})
+	if err != nil {
+		log.Fatal("Failed to initialize tracing:", err)
+	}
+	defer shutdownTracer(context.Background())
+
 	// Initialize database connection
 	db, err := database.NewPostgresConnection()
 	if err != nil {
@@ -54,9 +67,14 @@ func main() {
 	}
 	defer db.Close()
 
-	// Initialize event bus (using memory bus)
-	eventBus := sharedEvents.NewMemoryEventBus()
-	defer eventBus.Close()
+	// Initialize event bus, backend selected by EVENT_BUS
+	eventBus, err := sharedEvents.NewEventBus(context.Background(), sharedEvents.BusConfigFromEnv("auth-service"))
+	if err != nil {
+		log.Fatal("Failed to create event bus:", err)
+	}
+	if err := eventBus.Start(context.Background()); err != nil {
+		log.Fatal("Failed to start event bus:", err)
+	}
 
 	// Initialize repositories
 	userRepo := persistence.NewPostgresUserRepository(db)
@@ -71,11 +89,34 @@ func main() {
 
 	sessionManager := auth.NewSessionManager(sessionRepo, tokenService, 24*time.Hour)
 
-	// Initialize universal event publisher
-	eventPublisher := sharedEvents.NewUniversalEventPublisher(eventBus)
+	// Initialize universal event publisher backed by the transactional outbox,
+	// validating payloads against the schema registry
+	schemaRegistry := sharedEvents.DefaultRegistry()
+	outboxBus := sharedEvents.NewOutboxEventBus(db, eventBus)
+	eventPublisher := sharedEvents.NewUniversalEventPublisher(sharedEvents.NewSchemaEventBus(outboxBus, schemaRegistry))
+
+	// Start outbox relay
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+
+	outboxRelay := sharedEvents.NewOutboxRelay(db, eventBus, sharedEvents.DefaultOutboxRelayConfig())
+	go outboxRelay.Run(relayCtx)
+
+	// Deliver events scheduled with PublishAt and PublishAfter once they are due
+	eventScheduler := sharedEvents.NewEventScheduler(db, eventBus, sharedEvents.DefaultSchedulerConfig())
+	go eventScheduler.Run(relayCtx)
 
 	// Initialize application services
-	authService := services.NewAuthService(userRepo, sessionManager, eventPublisher, tokenService)
+	txManager := database.NewTxManager(db)
+	sagaOrchestrator := sharedEvents.NewSagaOrchestrator(db)
+	authService := services.NewAuthService(userRepo, sessionManager, eventPublisher, tokenService, txManager, sagaOrchestrator)
+
+	// Run the tier upgrade saga on replies from the user service, compensating timed out steps
+	sagaOrchestrator.Register(authService.TierUpgradeSagaDefinition())
+	if err := eventBus.Subscribe(context.Background(), "user-events", sagaOrchestrator.Handle, sharedEvents.WithGroupID("auth-service")); err != nil {
+		log.Fatal("Failed to subscribe saga orchestrator:", err)
+	}
+	go sagaOrchestrator.Run(relayCtx)
 
 	// Initialize HTTP handlers
 	authHandler := handlers.NewAuthHandler(authService, sessionManager)
@@ -108,6 +149,16 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok", "service": "auth", "timestamp": time.Now()})
 	})
 
+	// Outbox backlog, including events moved to a dead-letter topic
+	r.GET("/health/outbox", func(c *gin.Context) {
+		stats, err := outboxRelay.Stats(c.Request.Context())
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, stats)
+	})
+
 	// Protected routes
 	protected := r.Group("/api/v1")
 	protected.Use(authMiddleware.RequireAuth())
@@ -125,7 +176,7 @@ func main() {
 
 	server := &http.Server{
 		Addr:         ":" + port,
-		Handler:      r,
+		Handler:      tracing.HTTPMiddleware("auth-service", r),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -149,7 +200,13 @@ func main() {
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+
+	// Stop relaying new outbox rows and scheduled events, then let in-flight handlers finish
+	stopRelay()
+	if err := sharedEvents.Shutdown(ctx, eventBus); err != nil {
+		log.Printf("Event bus did not shut down cleanly: %v", err)
 	}
 
 	log.Println("Auth service exited properly")
@@ -22,11 +22,11 @@ require (
 	github.com/go-openapi/spec v0.20.4 // indirect
 	github.com/go-openapi/swag v0.19.15 // indirect
 	github.com/josharian/intern v1.0.0 // indirect
-	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/lib/pq v1.10.9 // indirect
 	github.com/mailru/easyjson v0.7.6 // indirect
 	github.com/pierrec/lz4/v4 v4.1.15 // indirect
-	github.com/segmentio/kafka-go v0.4.42 // indirect
+	github.com/segmentio/kafka-go v0.4.50 // indirect
 	github.com/swaggo/swag v1.16.2 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -5,6 +5,7 @@ import (
 
 	"auth-service/internal/infrastructure/auth"
 	sharedDomain "shared/pkg/domain"
+	sharedEvents "shared/pkg/events"
 )
 
 type UserRepository interface {
@@ -15,8 +16,18 @@ type UserRepository interface {
 }
 
 type EventPublisher interface {
-	PublishUserRegistered(ctx context.Context, user interface{}) error
-	PublishUserTierUpgraded(ctx context.Context, userID string, oldTier, newTier interface{}) error
+	PublishUserRegistered(ctx context.Context, user *sharedDomain.User) error
+	PublishUserTierUpgraded(ctx context.Context, userID string, oldTier, newTier sharedDomain.UserTier) error
+	PublishUserTierReverted(ctx context.Context, userID string, fromTier, toTier sharedDomain.UserTier, reason string) error
+}
+
+// SagaOrchestrator starts registered sagas, see sharedEvents.SagaOrchestrator
+type SagaOrchestrator interface {
+	Start(ctx context.Context, name string, data interface{}) (string, error)
+}
+
+type TransactionManager interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type SessionRepository interface {
@@ -26,3 +37,5 @@ type SessionRepository interface {
 	DeleteByUserID(ctx context.Context, userID string) error
 	ListByUserID(ctx context.Context, userID string) ([]*auth.Session, error)
 }
+
+var _ EventPublisher = (*sharedEvents.UniversalEventPublisher)(nil)
@@ -18,6 +18,8 @@ type AuthService struct {
 	sessionManager *auth.SessionManager
 	eventPublisher ports.EventPublisher
 	tokenService   *auth.TokenService
+	txManager      ports.TransactionManager
+	sagas          ports.SagaOrchestrator
 }
 
 func NewAuthService(
@@ -25,12 +27,16 @@ func NewAuthService(
 	sessionManager *auth.SessionManager,
 	eventPublisher ports.EventPublisher,
 	tokenService *auth.TokenService,
+	txManager ports.TransactionManager,
+	sagas ports.SagaOrchestrator,
 ) *AuthService {
 	return &AuthService{
 		userRepo:       userRepo,
 		sessionManager: sessionManager,
 		eventPublisher: eventPublisher,
 		tokenService:   tokenService,
+		txManager:      txManager,
+		sagas:          sagas,
 	}
 }
 
@@ -84,8 +90,15 @@ func (s *AuthService) Register(ctx context.Context, req RegisterRequest) (*Regis
 		UpdatedAt:    time.Now().UTC(),
 	}
 
-	// Save user
-	if err := s.userRepo.Create(ctx, user); err != nil {
+	// Save user and record the registered event in the same transaction
+	err = s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+
+		return s.eventPublisher.PublishUserRegistered(ctx, user)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -95,11 +108,6 @@ func (s *AuthService) Register(ctx context.Context, req RegisterRequest) (*Regis
 		return nil, err
 	}
 
-	// Publish user registered event
-	if err := s.eventPublisher.PublishUserRegistered(ctx, user); err != nil {
-		// Log error but don't fail registration
-	}
-
 	return &RegisterResponse{
 		User:      user,
 		TokenPair: tokenPair,
@@ -180,24 +188,16 @@ type UpgradeTierRequest struct {
 	UserID string `json:"user_id"`
 }
 
+// UpgradeTier upgrades the user to pro by starting the tier upgrade saga; the upgrade is
+// rolled back if the user service cannot provision the new quotas
 func (s *AuthService) UpgradeTier(ctx context.Context, req UpgradeTierRequest) error {
-	user, err := s.userRepo.FindByID(ctx, req.UserID)
-	if err != nil {
-		return err
-	}
-
-	oldTier := user.Tier
-	user.Tier = sharedDomain.UserTierPro
-	user.UpdatedAt = time.Now().UTC()
-
-	if err := s.userRepo.Update(ctx, user); err != nil {
+	if _, err := s.userRepo.FindByID(ctx, req.UserID); err != nil {
 		return err
 	}
 
-	// Publish tier upgraded event
-	if err := s.eventPublisher.PublishUserTierUpgraded(ctx, user.ID.String(), oldTier, user.Tier); err != nil {
-		// Log error but don't fail operation
-	}
-
-	return nil
+	_, err := s.sagas.Start(ctx, TierUpgradeSaga, tierUpgradeData{
+		UserID:  req.UserID,
+		NewTier: string(sharedDomain.UserTierPro),
+	})
+	return err
 }
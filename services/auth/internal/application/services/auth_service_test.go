@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"auth-service/internal/infrastructure/auth"
+	sharedDomain "shared/pkg/domain"
+	sharedEvents "shared/pkg/events"
+	"shared/pkg/events/eventstest"
+)
+
+// contractsDir holds the pacts between the services, at the root of the repository
+const contractsDir = "../../../../../contracts"
+
+// memoryUserRepository keeps users in memory
+type memoryUserRepository struct {
+	mu    sync.Mutex
+	users map[string]sharedDomain.User
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *sharedDomain.User) error {
+	return r.Update(ctx, user)
+}
+
+func (r *memoryUserRepository) FindByID(ctx context.Context, id string) (*sharedDomain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) FindByEmail(ctx context.Context, email string) (*sharedDomain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, user *sharedDomain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users[user.ID.String()] = *user
+	return nil
+}
+
+// passthroughTx runs functions without a transaction
+type passthroughTx struct{}
+
+func (passthroughTx) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// newContractAuthService builds the auth service on in-memory users, publishing to bus
+func newContractAuthService(bus sharedEvents.EventBus) *AuthService {
+	tokenService := auth.NewTokenService(auth.TokenConfig{
+		SecretKey:       "contract-test-secret",
+		AccessTokenExp:  time.Minute,
+		RefreshTokenExp: time.Hour,
+	})
+	users := &memoryUserRepository{users: make(map[string]sharedDomain.User)}
+	return NewAuthService(users, nil, sharedEvents.NewUniversalEventPublisher(bus), tokenService, passthroughTx{}, nil)
+}
+
+// registerUser registers a free user and returns its ID
+func registerUser(ctx context.Context, service *AuthService) (string, error) {
+	resp, err := service.Register(ctx, RegisterRequest{
+		Email:    "jane@example.com",
+		Password: "Str0ng!Passw0rd",
+		FullName: "Jane Doe",
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.User.ID.String(), nil
+}
+
+// runTierUpgrade runs the steps of the tier upgrade saga for a new user and, when
+// provisioning fails with reason, its compensation
+func runTierUpgrade(ctx context.Context, bus sharedEvents.EventBus, reason string) error {
+	service := newContractAuthService(bus)
+	userID, err := registerUser(ctx, service)
+	if err != nil {
+		return err
+	}
+
+	saga := &sharedEvents.Saga{Name: TierUpgradeSaga}
+	if err := saga.SetData(tierUpgradeData{UserID: userID, NewTier: string(sharedDomain.UserTierPro)}); err != nil {
+		return err
+	}
+
+	steps := service.TierUpgradeSagaDefinition().Steps
+	for _, step := range steps {
+		if err := step.Action(ctx, saga); err != nil {
+			return err
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+
+	saga.LastError = reason
+	return steps[0].Compensate(ctx, saga)
+}
+
+// TestUserServiceContract verifies the events the auth service publishes against the pact
+// the user service wrote
+func TestUserServiceContract(t *testing.T) {
+	pacts := eventstest.LoadPacts(t, contractsDir, "auth-service")
+	if len(pacts) == 0 {
+		t.Fatalf("no pacts for auth-service in %s", contractsDir)
+	}
+
+	eventstest.VerifyProvider(t, pacts, map[string]eventstest.Producer{
+		"a registered user": func(ctx context.Context, bus sharedEvents.EventBus) error {
+			_, err := registerUser(ctx, newContractAuthService(bus))
+			return err
+		},
+		"a tier upgrade": func(ctx context.Context, bus sharedEvents.EventBus) error {
+			return runTierUpgrade(ctx, bus, "")
+		},
+		"a tier revert": func(ctx context.Context, bus sharedEvents.EventBus) error {
+			return runTierUpgrade(ctx, bus, "quota provisioning timed out")
+		},
+	})
+}
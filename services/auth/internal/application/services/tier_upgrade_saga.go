@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	sharedDomain "shared/pkg/domain"
+	sharedEvents "shared/pkg/events"
+)
+
+// TierUpgradeSaga is the name of the saga started by UpgradeTier
+const TierUpgradeSaga = "tier-upgrade"
+
+// tierUpgradeTimeout is how long the user service has to provision the new quotas
+const tierUpgradeTimeout = 5 * time.Minute
+
+// tierUpgradeData is the state of a tier upgrade saga
+type tierUpgradeData struct {
+	UserID  string `json:"user_id"`
+	OldTier string `json:"old_tier"`
+	NewTier string `json:"new_tier"`
+}
+
+// TierUpgradeSagaDefinition describes the tier upgrade: the auth service changes the
+// user's tier, then the user service provisions the quotas of the new tier. When
+// provisioning fails or times out the tier is reverted and user.tier.reverted tells the
+// user service to restore the old quotas.
+func (s *AuthService) TierUpgradeSagaDefinition() sharedEvents.SagaDefinition {
+	return sharedEvents.SagaDefinition{
+		Name: TierUpgradeSaga,
+		Steps: []sharedEvents.SagaStep{
+			{
+				Name:       "upgrade-auth-tier",
+				Action:     s.upgradeAuthTier,
+				Compensate: s.revertAuthTier,
+			},
+			{
+				Name:        "provision-quotas",
+				Action:      s.requestQuotaProvisioning,
+				CompletedBy: sharedEvents.UserQuotaProvisionedEvent,
+				FailedBy:    sharedEvents.UserQuotaProvisioningFailedEvent,
+				Timeout:     tierUpgradeTimeout,
+			},
+		},
+	}
+}
+
+func (s *AuthService) upgradeAuthTier(ctx context.Context, saga *sharedEvents.Saga) error {
+	var data tierUpgradeData
+	if err := saga.Decode(&data); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, data.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Keep the old tier for the compensation
+	data.OldTier = string(user.Tier)
+	user.Tier = sharedDomain.UserTier(data.NewTier)
+	user.UpdatedAt = time.Now().UTC()
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	return saga.SetData(data)
+}
+
+func (s *AuthService) requestQuotaProvisioning(ctx context.Context, saga *sharedEvents.Saga) error {
+	var data tierUpgradeData
+	if err := saga.Decode(&data); err != nil {
+		return err
+	}
+
+	return s.eventPublisher.PublishUserTierUpgraded(ctx, data.UserID, sharedDomain.UserTier(data.OldTier), sharedDomain.UserTier(data.NewTier))
+}
+
+func (s *AuthService) revertAuthTier(ctx context.Context, saga *sharedEvents.Saga) error {
+	var data tierUpgradeData
+	if err := saga.Decode(&data); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, data.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Leave tiers changed since the upgrade alone
+	if string(user.Tier) == data.NewTier {
+		user.Tier = sharedDomain.UserTier(data.OldTier)
+		user.UpdatedAt = time.Now().UTC()
+
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	return s.eventPublisher.PublishUserTierReverted(ctx, data.UserID, sharedDomain.UserTier(data.NewTier), sharedDomain.UserTier(data.OldTier), saga.LastError)
+}
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"errors"
 
+	"shared/pkg/database"
 	sharedDomain "shared/pkg/domain"
 
 	"github.com/jmoiron/sqlx"
@@ -25,7 +26,7 @@ func (r *PostgresUserRepository) Create(ctx context.Context, user *sharedDomain.
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := database.Executor(ctx, r.db).ExecContext(ctx, query,
 		user.ID,
 		user.Email,
 		user.PasswordHash,
@@ -42,7 +43,7 @@ func (r *PostgresUserRepository) FindByID(ctx context.Context, id string) (*shar
 	var user sharedDomain.User
 	query := `SELECT id, email, password_hash, full_name, tier, created_at, updated_at FROM users WHERE id = $1`
 
-	err := r.db.GetContext(ctx, &user, query, id)
+	err := database.Executor(ctx, r.db).GetContext(ctx, &user, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, domain.ErrUserNotFound
@@ -57,7 +58,7 @@ func (r *PostgresUserRepository) FindByEmail(ctx context.Context, email string)
 	var user sharedDomain.User
 	query := `SELECT id, email, password_hash, full_name, tier, created_at, updated_at FROM users WHERE email = $1`
 
-	err := r.db.GetContext(ctx, &user, query, email)
+	err := database.Executor(ctx, r.db).GetContext(ctx, &user, query, email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, domain.ErrUserNotFound
@@ -75,7 +76,7 @@ func (r *PostgresUserRepository) Update(ctx context.Context, user *sharedDomain.
 		WHERE id = $6
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := database.Executor(ctx, r.db).ExecContext(ctx, query,
 		user.Email,
 		user.PasswordHash,
 		user.FullName,
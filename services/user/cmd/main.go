@@ -3,12 +3,15 @@ package main
 import (
 	"context"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"shared/pkg/database"
 	sharedEvents "shared/pkg/events"
+	"shared/pkg/tracing"
 	"user-service/internal/application/services"
 	"user-service/internal/infrastructre/events"
 	"user-service/internal/infrastructre/http/handlers"
@@ -23,6 +26,18 @@ import (
 )
 
 func main() {
+	// Initialize tracing
+	shutdownTracer, err := tracing.InitTracer(tracing.Config{
+		ServiceName: "user-service",
+		Environment: getEnv("ENVIRONMENT", "development"),
+		JaegerAgent: getEnv("JAEGER_ENDPOINT", "http://localhost:14268/api/traces"),
+		Enabled:     getEnv("ENABLE_TRACING", "false") == "true",
+	})
+	if err != nil {
+		log.Fatal("Failed to initialize tracing:", err)
+	}
+	defer shutdownTracer(context.Background())
+
 	// Initialize database connection
 	db, err := database.NewPostgresConnection()
 	if err != nil {
@@ -30,28 +45,70 @@ func main() {
 	}
 	defer db.Close()
 
-	// Initialize event bus (using memory bus)
-	eventBus := sharedEvents.NewMemoryEventBus()
-	defer eventBus.Close()
+	// Initialize event bus, backend selected by EVENT_BUS; the memory bus handles
+	// each user's events in order. On Kafka, each consumed offset commits in one
+	// transaction with its dead letter, under a transactional ID stable for the pod;
+	// quota replies go through the outbox, their exactly-once path
+	busConfig := sharedEvents.BusConfigFromEnv("user-service")
+	busConfig.MemoryOptions = []sharedEvents.MemoryOption{sharedEvents.WithOrderedDelivery()}
+	if busConfig.KafkaTransactionalID == "" {
+		if hostname, err := os.Hostname(); err == nil {
+			busConfig.KafkaTransactionalID = "user-service-" + hostname
+		}
+	}
 
-	// Initialize infrastructure
+	eventBus, err := sharedEvents.NewEventBus(context.Background(), busConfig)
+	if err != nil {
+		log.Fatal("Failed to create event bus:", err)
+	}
+
+	eventBus.Use(
+		sharedEvents.Logging(),
+		sharedEvents.Metrics(),
+		sharedEvents.Timeout(30*time.Second),
+	)
+
+	// Initialize infrastructure; users are event sourced and the users table is their projection
 	userRepo := persistence.NewPostgresUserRepository(db)
+	userStore := persistence.NewEventSourcedUserRepository(sharedEvents.NewPostgresEventStore(db), userRepo)
 
-	// Initialize universal event publisher
-	eventPublisher := sharedEvents.NewUniversalEventPublisher(eventBus)
+	// Initialize universal event publisher backed by the transactional outbox,
+	// validating payloads against the schema registry
+	schemaRegistry := sharedEvents.DefaultRegistry()
+	outboxBus := sharedEvents.NewOutboxEventBus(db, eventBus)
+	eventPublisher := sharedEvents.NewUniversalEventPublisher(sharedEvents.NewSchemaEventBus(outboxBus, schemaRegistry))
 
 	// Initialize application services
-	userService := services.NewUserService(userRepo, eventPublisher)
+	txManager := database.NewTxManager(db)
+	userService := services.NewUserService(userRepo, userStore, eventPublisher, txManager)
 
 	// Initialize event subscriber (now using universal subscriber)
-	eventSubscriber := events.NewUniversalEventSubscriber(eventBus, userService, userRepo)
+	processedEvents := sharedEvents.NewPostgresProcessedEventStore(db)
+	eventSubscriber := events.NewUniversalEventSubscriber(sharedEvents.NewSchemaEventBus(eventBus, schemaRegistry), userService, processedEvents)
 
 	// Start event consumers
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	if err := eventSubscriber.SubscribeToUserEvents(ctx); err != nil {
 		log.Fatal("Failed to start event consumers:", err)
 	}
 
+	if err := eventBus.Start(ctx); err != nil {
+		log.Fatal("Failed to start event bus:", err)
+	}
+
+	// Start outbox relay
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+
+	outboxRelay := sharedEvents.NewOutboxRelay(db, eventBus, sharedEvents.DefaultOutboxRelayConfig())
+	go outboxRelay.Run(relayCtx)
+
+	// Deliver events scheduled with PublishAt and PublishAfter once they are due
+	eventScheduler := sharedEvents.NewEventScheduler(db, eventBus, sharedEvents.DefaultSchedulerConfig())
+	go eventScheduler.Run(relayCtx)
+
 	// Initialize HTTP handlers
 	userHandler := handlers.NewUserHandler(userService)
 
@@ -80,6 +137,16 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok", "service": "user"})
 	})
 
+	// Outbox backlog, including events moved to a dead-letter topic
+	r.GET("/health/outbox", func(c *gin.Context) {
+		stats, err := outboxRelay.Stats(c.Request.Context())
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, stats)
+	})
+
 	// Admin routes
 	admin := r.Group("/api/v1/admin")
 	{
@@ -92,9 +159,17 @@ func main() {
 		port = "8082"
 	}
 
+	server := &http.Server{
+		Addr:         ":" + port,
+		Handler:      tracing.HTTPMiddleware("user-service", r),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
 	go func() {
 		log.Printf("User service running on port %s", port)
-		if err := r.Run(":" + port); err != nil {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatal("Failed to start HTTP server:", err)
 		}
 	}()
@@ -105,4 +180,26 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down user service...")
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelShutdown()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+
+	// Stop relaying new outbox rows and scheduled events, then let in-flight handlers finish
+	stopRelay()
+	if err := sharedEvents.Shutdown(shutdownCtx, eventBus); err != nil {
+		log.Printf("Event bus did not shut down cleanly: %v", err)
+	}
+
+	log.Println("User service exited properly")
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
 }
@@ -0,0 +1,182 @@
+// Command replay rebuilds the users table of the user service, the projection of the user
+// streams in the event store.
+//
+// With -source store it projects every user stream of the event store. With -source topic
+// it replays the user-events topic from an offset or a point in time through the same
+// handlers the service subscribes with, plus the quota updates the service published,
+// recording the streams in memory only. Either way it writes into the shadow table
+// users_rebuild and reports how the result differs from the live table. In apply mode it
+// then swaps users_rebuild in for users in one transaction, keeping the replaced table as
+// users_backup, unless users are missing from the rebuild or differ from the live table;
+// pass -force to swap anyway. In dry-run mode users_rebuild is left for inspection.
+//
+// Stop the user service's consumers while applying a rebuild: events they handle between
+// the replay and the swap are not in the rebuilt table.
+//
+//	go run ./cmd/replay -mode dry-run
+//	go run ./cmd/replay -source store -mode apply
+//	go run ./cmd/replay -since 2024-01-01T00:00:00Z -mode apply -force
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"shared/pkg/database"
+	sharedEvents "shared/pkg/events"
+	"user-service/internal/application/services"
+	"user-service/internal/infrastructre/events"
+	"user-service/internal/infrastructre/persistence"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Replay modes
+const (
+	modeDryRun = "dry-run"
+	modeApply  = "apply"
+)
+
+// Sources of a rebuild
+const (
+	sourceTopic = "topic"
+	sourceStore = "store"
+)
+
+func main() {
+	topic := flag.String("topic", "user-events", "topic to replay")
+	offset := flag.Int64("offset", 0, "offset to replay from")
+	since := flag.String("since", "", "replay events stored at or after this RFC 3339 time instead of from -offset")
+	mode := flag.String("mode", modeDryRun, "dry-run only rebuilds and compares, apply also swaps the rebuilt table in")
+	source := flag.String("source", sourceTopic, "topic replays -topic, store projects the event store")
+	force := flag.Bool("force", false, "apply even when users are missing from the rebuild or differ from the live table")
+	flag.Parse()
+
+	from := sharedEvents.ReplayFrom{Offset: *offset}
+	if *since != "" {
+		t, err := time.Parse(time.RFC3339, *since)
+		if err != nil {
+			log.Fatalf("Invalid -since %q: %v", *since, err)
+		}
+		from.Time = t
+	}
+
+	if *mode != modeDryRun && *mode != modeApply {
+		log.Fatalf("Invalid -mode %q, expected %s or %s", *mode, modeDryRun, modeApply)
+	}
+	if *source != sourceTopic && *source != sourceStore {
+		log.Fatalf("Invalid -source %q, expected %s or %s", *source, sourceTopic, sourceStore)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, *source, *topic, from, *mode == modeApply, *force); err != nil {
+		log.Printf("Replay failed: %v", err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, source, topic string, from sharedEvents.ReplayFrom, apply, force bool) error {
+	db, err := database.NewPostgresConnection()
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	rebuild := persistence.NewUsersRebuild(db)
+	if err := rebuild.Prepare(ctx); err != nil {
+		return err
+	}
+
+	if source == sourceStore {
+		userStore := persistence.NewEventSourcedUserRepository(sharedEvents.NewPostgresEventStore(db), rebuild.Repository())
+		projected, err := userStore.Project(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to project the event store: %w", err)
+		}
+		log.Printf("Projected %d user streams", projected)
+	} else if err := replayTopic(ctx, db, rebuild, topic, from); err != nil {
+		return err
+	}
+
+	diff, err := rebuild.Diff(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("Live users: %d, rebuilt: %d, missing from rebuild: %d, only in rebuild: %d, differing: %d",
+		diff.Live, diff.Rebuilt, diff.Missing, diff.Unknown, diff.Differing)
+
+	if !apply {
+		log.Println("Dry run, users left unchanged; the rebuild is in users_rebuild")
+		return nil
+	}
+	if (diff.Missing > 0 || diff.Differing > 0) && !force {
+		return fmt.Errorf("refusing to swap a rebuild with %d missing and %d differing users, inspect users_rebuild or pass -force", diff.Missing, diff.Differing)
+	}
+
+	if err := rebuild.Swap(ctx); err != nil {
+		return err
+	}
+	log.Println("Rebuilt users swapped in, the previous table is kept as users_backup")
+	return nil
+}
+
+// replayTopic rebuilds users from the topic through the service's handlers. The streams
+// they record go to an in-memory store, leaving the service's event store untouched.
+func replayTopic(ctx context.Context, db *sqlx.DB, rebuild *persistence.UsersRebuild, topic string, from sharedEvents.ReplayFrom) error {
+	busConfig := sharedEvents.BusConfigFromEnv("user-service-replay")
+	eventBus, err := sharedEvents.NewEventBus(ctx, busConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create event bus: %w", err)
+	}
+	defer eventBus.Close()
+
+	replayer, ok := eventBus.(sharedEvents.Replayer)
+	if !ok {
+		return fmt.Errorf("the %s event bus does not keep events to replay", busConfig.Backend)
+	}
+
+	userRepo := rebuild.Repository()
+	userStore := persistence.NewEventSourcedUserRepository(sharedEvents.NewMemoryEventStore(), userRepo)
+
+	// Events the handlers publish while replaying were published the first time round, so
+	// they go to a bus nobody subscribes to
+	discardBus := sharedEvents.NewMemoryEventBus(sharedEvents.WithSynchronousDelivery())
+	if err := discardBus.Start(ctx); err != nil {
+		return err
+	}
+	defer discardBus.Close()
+
+	userService := services.NewUserService(userRepo, userStore, sharedEvents.NewUniversalEventPublisher(discardBus), database.NewTxManager(db))
+
+	// Every event is handled once per replay, whatever the live consumer already processed
+	subscriber := events.NewUniversalEventSubscriber(discardBus, userService, sharedEvents.NewMemoryProcessedEventStore())
+	handler := subscriber.ReplayHandler()
+
+	replayed := 0
+	err = replayer.Replay(ctx, topic, from, func(ctx context.Context, event *sharedEvents.Event) error {
+		replayed++
+
+		// The live consumer dead-lettered these too, e.g. upgrades of users registered
+		// before the replayed range
+		err := handler(ctx, event)
+		if sharedEvents.IsDeadLetter(err) {
+			log.Printf("Skipping event %s (%s): %v", event.ID, event.Type, err)
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replay %s: %w", topic, err)
+	}
+	log.Printf("Replayed %d events from %s", replayed, topic)
+	return nil
+}
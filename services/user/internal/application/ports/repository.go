@@ -4,21 +4,37 @@ import (
 	"context"
 
 	sharedDomain "shared/pkg/domain"
+	sharedEvents "shared/pkg/events"
+	"user-service/internal/domain"
 )
 
+// UserRepository reads the users projection
 type UserRepository interface {
-	Create(ctx context.Context, user *sharedDomain.User) error
 	FindByID(ctx context.Context, id string) (*sharedDomain.User, error)
 	FindByEmail(ctx context.Context, email string) (*sharedDomain.User, error)
-	Update(ctx context.Context, user *sharedDomain.User) error
-	UpdateQuotas(ctx context.Context, userID string, quotas sharedDomain.QuotaInfo) error
-	ResetAllMonthlyQuotas(ctx context.Context) error
+	ListIDs(ctx context.Context) ([]string, error)
+}
+
+// UserAggregateRepository loads users from their event streams and saves their changes
+type UserAggregateRepository interface {
+	Load(ctx context.Context, userID string) (*domain.UserAggregate, error)
+	Save(ctx context.Context, user *domain.UserAggregate) error
 }
 
 type EventPublisher interface {
-	PublishUserQuotaUpdated(ctx context.Context, userID string, quotas interface{}) error
+	PublishUserQuotaUpdated(ctx context.Context, userID string, quotas sharedDomain.QuotaInfo) error
+	PublishUserQuotasUpdated(ctx context.Context, updates []sharedEvents.UserQuotas) error
+	PublishUserQuotaProvisioned(ctx context.Context, userID string, tier sharedDomain.UserTier) error
+	PublishUserQuotaProvisioningFailed(ctx context.Context, userID string, reason string) error
+}
+
+type TransactionManager interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	WithinNewTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type EventSubscriber interface {
 	SubscribeToUserEvents(ctx context.Context) error
 }
+
+var _ EventPublisher = (*sharedEvents.UniversalEventPublisher)(nil)
@@ -2,22 +2,39 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	sharedDomain "shared/pkg/domain"
+	sharedEvents "shared/pkg/events"
 	"user-service/internal/application/ports"
 	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// versionConflictAttempts bounds how often a command runs on a user that keeps being
+	// changed concurrently
+	versionConflictAttempts = 5
+	// resetBatchSize is how many users ResetMonthlyQuotas resets per transaction
+	resetBatchSize = 100
 )
 
 type UserService struct {
 	userRepo       ports.UserRepository
+	users          ports.UserAggregateRepository
 	eventPublisher ports.EventPublisher
+	txManager      ports.TransactionManager
 }
 
-func NewUserService(userRepo ports.UserRepository, eventPublisher ports.EventPublisher) *UserService {
+func NewUserService(userRepo ports.UserRepository, users ports.UserAggregateRepository, eventPublisher ports.EventPublisher, txManager ports.TransactionManager) *UserService {
 	return &UserService{
 		userRepo:       userRepo,
+		users:          users,
 		eventPublisher: eventPublisher,
+		txManager:      txManager,
 	}
 }
 
@@ -50,29 +67,34 @@ func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*GetUse
 	}, nil
 }
 
-type UseAIDescriptionQuotaRequest struct {
-	UserID string `json:"user_id"`
+type RegisterUserRequest struct {
+	UserID    uuid.UUID
+	Email     string
+	FullName  string
+	Tier      sharedDomain.UserTier
+	CreatedAt time.Time
 }
 
-func (s *UserService) UseAIDescriptionQuota(ctx context.Context, req UseAIDescriptionQuotaRequest) error {
-	user, err := s.userRepo.FindByID(ctx, req.UserID)
+// RegisterUser starts the stream of a user registered with the auth service
+func (s *UserService) RegisterUser(ctx context.Context, req RegisterUserRequest) error {
+	user, err := domain.RegisterUser(req.UserID, req.Email, req.FullName, req.Tier, req.CreatedAt)
 	if err != nil {
 		return err
 	}
 
-	if err := user.UseAIDescriptionQuota(); err != nil {
-		return err
-	}
-
-	if err := s.userRepo.Update(ctx, user); err != nil {
-		return err
-	}
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		return s.users.Save(ctx, user)
+	})
+}
 
-	if err := s.eventPublisher.PublishUserQuotaUpdated(ctx, user.ID.String(), user.GetQuotaInfo()); err != nil {
-		fmt.Printf("Failed to publish quota updated event: %v\n", err)
-	}
+type UseAIDescriptionQuotaRequest struct {
+	UserID string `json:"user_id"`
+}
 
-	return nil
+func (s *UserService) UseAIDescriptionQuota(ctx context.Context, req UseAIDescriptionQuotaRequest) error {
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		return s.modifyUser(ctx, req.UserID, (*domain.UserAggregate).UseAIDescriptionQuota)
+	})
 }
 
 type UseAIVideoQuotaRequest struct {
@@ -80,76 +102,196 @@ type UseAIVideoQuotaRequest struct {
 }
 
 func (s *UserService) UseAIVideoQuota(ctx context.Context, req UseAIVideoQuotaRequest) error {
-	user, err := s.userRepo.FindByID(ctx, req.UserID)
-	if err != nil {
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		return s.modifyUser(ctx, req.UserID, (*domain.UserAggregate).UseAIVideoQuota)
+	})
+}
+
+type UseAutoPostingQuotaRequest struct {
+	UserID string `json:"user_id"`
+}
+
+func (s *UserService) UseAutoPostingQuota(ctx context.Context, req UseAutoPostingQuotaRequest) error {
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		return s.modifyUser(ctx, req.UserID, (*domain.UserAggregate).UseAutoPostingQuota)
+	})
+}
+
+type UpgradeToProRequest struct {
+	UserID string `json:"user_id"`
+}
+
+func (s *UserService) UpgradeToPro(ctx context.Context, req UpgradeToProRequest) error {
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		return s.modifyUser(ctx, req.UserID, (*domain.UserAggregate).UpgradeToPro)
+	})
+}
+
+// ProvisionTierUpgrade upgrades the user's quotas to pro as a step of the tier upgrade saga
+// and reports the outcome to the saga. Errors retrying cannot fix, such as an unknown
+// user, are reported as a failure, which makes the auth service revert the upgrade, and
+// returned marked for dead-lettering. The failure is published in a transaction of its
+// own, since the caller's transaction rolls back on the returned error.
+func (s *UserService) ProvisionTierUpgrade(ctx context.Context, req UpgradeToProRequest) error {
+	err := s.provisionTierUpgrade(ctx, req)
+	if err == nil || !permanent(err) {
 		return err
 	}
 
-	if err := user.UseAIVideoQuota(); err != nil {
-		return err
+	publishErr := s.txManager.WithinNewTransaction(ctx, func(ctx context.Context) error {
+		return s.eventPublisher.PublishUserQuotaProvisioningFailed(ctx, req.UserID, err.Error())
+	})
+	if publishErr != nil {
+		return fmt.Errorf("failed to publish quota provisioning failed event: %w", publishErr)
 	}
 
-	if err := s.userRepo.Update(ctx, user); err != nil {
+	if sharedEvents.IsDeadLetter(err) {
 		return err
 	}
+	return sharedEvents.DeadLetter(fmt.Errorf("failed to provision tier upgrade of user %s: %w", req.UserID, err))
+}
 
-	if err := s.eventPublisher.PublishUserQuotaUpdated(ctx, user.ID.String(), user.GetQuotaInfo()); err != nil {
-		fmt.Printf("Failed to publish quota updated event: %v\n", err)
+func (s *UserService) provisionTierUpgrade(ctx context.Context, req UpgradeToProRequest) error {
+	if _, err := uuid.Parse(req.UserID); err != nil {
+		return sharedEvents.DeadLetter(fmt.Errorf("invalid user ID %q: %w", req.UserID, err))
 	}
 
-	return nil
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.modifyUser(ctx, req.UserID, (*domain.UserAggregate).UpgradeToPro); err != nil {
+			return err
+		}
+
+		if err := s.eventPublisher.PublishUserQuotaProvisioned(ctx, req.UserID, sharedDomain.UserTierPro); err != nil {
+			return fmt.Errorf("failed to publish quota provisioned event: %w", err)
+		}
+
+		return nil
+	})
 }
 
-type UseAutoPostingQuotaRequest struct {
+// permanent reports whether retrying cannot fix err: domain errors and errors already
+// marked for dead-lettering
+func permanent(err error) bool {
+	var domainErr *domain.DomainError
+	return errors.As(err, &domainErr) || sharedEvents.IsDeadLetter(err)
+}
+
+type RevertTierUpgradeRequest struct {
 	UserID string `json:"user_id"`
+	ToTier string `json:"to_tier"`
 }
 
-func (s *UserService) UseAutoPostingQuota(ctx context.Context, req UseAutoPostingQuotaRequest) error {
-	user, err := s.userRepo.FindByID(ctx, req.UserID)
-	if err != nil {
-		return err
+// RevertTierUpgrade restores the quotas of the tier a user was moved back to by the tier
+// upgrade saga. Users that were never provisioned are left as they are.
+func (s *UserService) RevertTierUpgrade(ctx context.Context, req RevertTierUpgradeRequest) error {
+	if sharedDomain.UserTier(req.ToTier) != sharedDomain.UserTierFree {
+		return nil
 	}
 
-	if err := user.UseAutoPostingQuota(); err != nil {
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := s.modifyUser(ctx, req.UserID, (*domain.UserAggregate).DowngradeToFree)
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil
+		}
 		return err
-	}
+	})
+}
+
+type SyncQuotasRequest struct {
+	UserID    string
+	Quotas    sharedEvents.QuotaInfoData
+	UpdatedAt time.Time
+}
 
-	if err := s.userRepo.Update(ctx, user); err != nil {
+// SyncQuotas applies the quotas of a quota updated event to the user, e.g. when
+// rebuilding users from the topic. Unknown users are left out.
+func (s *UserService) SyncQuotas(ctx context.Context, req SyncQuotasRequest) error {
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := s.modifyUser(ctx, req.UserID, func(user *domain.UserAggregate) error {
+			return user.SyncQuotas(req.Quotas, req.UpdatedAt)
+		})
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil
+		}
 		return err
-	}
+	})
+}
 
-	if err := s.eventPublisher.PublishUserQuotaUpdated(ctx, user.ID.String(), user.GetQuotaInfo()); err != nil {
-		fmt.Printf("Failed to publish quota updated event: %v\n", err)
+// ResetMonthlyQuotas starts a new quota period for every user. Users are reset in batches,
+// each in a transaction of its own, so a failure leaves the batches before it reset.
+func (s *UserService) ResetMonthlyQuotas(ctx context.Context) error {
+	userIDs, err := s.userRepo.ListIDs(ctx)
+	if err != nil {
+		return err
 	}
 
+	for start := 0; start < len(userIDs); start += resetBatchSize {
+		batch := userIDs[start:min(start+resetBatchSize, len(userIDs))]
+		if err := s.resetMonthlyQuotas(ctx, batch); err != nil {
+			return fmt.Errorf("failed to reset quotas of users %d to %d: %w", start+1, start+len(batch), err)
+		}
+	}
 	return nil
 }
 
-type UpgradeToProRequest struct {
-	UserID string `json:"user_id"`
+// resetMonthlyQuotas records one reset event per user stream, and one quota updated event
+// per user published as a single batch
+func (s *UserService) resetMonthlyQuotas(ctx context.Context, userIDs []string) error {
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		updates := make([]sharedEvents.UserQuotas, len(userIDs))
+		for i, userID := range userIDs {
+			user, err := s.saveUser(ctx, userID, (*domain.UserAggregate).ResetMonthlyQuotas)
+			if err != nil {
+				return err
+			}
+			updates[i] = sharedEvents.UserQuotas{UserID: userID, Quotas: user.User().GetQuotaInfo()}
+		}
+
+		if err := s.eventPublisher.PublishUserQuotasUpdated(ctx, updates); err != nil {
+			return fmt.Errorf("failed to publish quota updated events: %w", err)
+		}
+
+		return nil
+	})
 }
 
-func (s *UserService) UpgradeToPro(ctx context.Context, req UpgradeToProRequest) error {
-	user, err := s.userRepo.FindByID(ctx, req.UserID)
+// modifyUser runs a command on the user's aggregate, saves the events it records and
+// records the quota updated event, all in the caller's transaction
+func (s *UserService) modifyUser(ctx context.Context, userID string, command func(*domain.UserAggregate) error) error {
+	user, err := s.saveUser(ctx, userID, command)
 	if err != nil {
 		return err
 	}
 
-	user.UpgradeToPro()
-
-	if err := s.userRepo.Update(ctx, user); err != nil {
-		return err
-	}
-
-	if err := s.eventPublisher.PublishUserQuotaUpdated(ctx, user.ID.String(), user.GetQuotaInfo()); err != nil {
-		fmt.Printf("Failed to publish quota updated event: %v\n", err)
+	if err := s.eventPublisher.PublishUserQuotaUpdated(ctx, userID, user.User().GetQuotaInfo()); err != nil {
+		return fmt.Errorf("failed to publish quota updated event: %w", err)
 	}
 
 	return nil
 }
 
-func (s *UserService) ResetMonthlyQuotas(ctx context.Context) error {
-	return s.userRepo.ResetAllMonthlyQuotas(ctx)
+// saveUser loads the user, runs a command on it and saves the events it records. When the
+// user was changed concurrently it loads the user again and reruns the command, up to
+// versionConflictAttempts times.
+func (s *UserService) saveUser(ctx context.Context, userID string, command func(*domain.UserAggregate) error) (*domain.UserAggregate, error) {
+	for attempt := 1; ; attempt++ {
+		user, err := s.users.Load(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := command(user); err != nil {
+			return nil, err
+		}
+
+		err = s.users.Save(ctx, user)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, sharedEvents.ErrVersionConflict) || attempt >= versionConflictAttempts {
+			return nil, err
+		}
+	}
 }
 
 func (s *UserService) CheckAIDescriptionQuota(ctx context.Context, userID string) (bool, error) {
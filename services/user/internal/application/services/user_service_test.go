@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	sharedDomain "shared/pkg/domain"
+	sharedEvents "shared/pkg/events"
+	"shared/pkg/events/eventstest"
+	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// storeUsers keeps user streams in a memory event store. beforeSave runs once before the
+// next save, e.g. to change the user concurrently; conflicts fails that many saves.
+type storeUsers struct {
+	store      *sharedEvents.MemoryEventStore
+	beforeSave func()
+	conflicts  int
+	saves      int
+}
+
+func (r *storeUsers) Load(ctx context.Context, userID string) (*domain.UserAggregate, error) {
+	events, _, err := r.store.Load(ctx, domain.UserStreamID(userID), 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, domain.ErrUserNotFound
+	}
+
+	user := domain.RestoreUserAggregate(sharedDomain.User{}, 0)
+	if err := user.LoadFromHistory(events); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *storeUsers) Save(ctx context.Context, user *domain.UserAggregate) error {
+	r.saves++
+	if hook := r.beforeSave; hook != nil {
+		r.beforeSave = nil
+		hook()
+	}
+	if r.conflicts > 0 {
+		r.conflicts--
+		return fmt.Errorf("%w: user %s", sharedEvents.ErrVersionConflict, user.User().ID)
+	}
+
+	streamID := domain.UserStreamID(user.User().ID.String())
+	if _, err := r.store.Append(ctx, streamID, user.Version(), user.Changes()); err != nil {
+		return err
+	}
+	user.MarkCommitted()
+	return nil
+}
+
+// ListIDs lists the users with a stream; the other lookups are not used by these tests
+func (r *storeUsers) ListIDs(ctx context.Context) ([]string, error) {
+	streamIDs, err := r.store.StreamIDs(ctx, domain.UserStreamPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(streamIDs))
+	for i, streamID := range streamIDs {
+		ids[i] = strings.TrimPrefix(streamID, domain.UserStreamPrefix)
+	}
+	return ids, nil
+}
+
+func (r *storeUsers) FindByID(ctx context.Context, id string) (*sharedDomain.User, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *storeUsers) FindByEmail(ctx context.Context, email string) (*sharedDomain.User, error) {
+	return nil, errors.New("not implemented")
+}
+
+// countingTx runs functions without a transaction and counts the transactions begun
+type countingTx struct {
+	begun int
+}
+
+func (tx *countingTx) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx.begun++
+	return fn(ctx)
+}
+
+func (tx *countingTx) WithinNewTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return tx.WithinTransaction(ctx, fn)
+}
+
+func newStoreUserService() (*UserService, *storeUsers, *countingTx, *eventstest.Recorder) {
+	users := &storeUsers{store: sharedEvents.NewMemoryEventStore()}
+	tx := &countingTx{}
+	recorder := eventstest.NewRecorder()
+	return NewUserService(users, users, sharedEvents.NewUniversalEventPublisher(recorder), tx), users, tx, recorder
+}
+
+func registerFreeUser(t *testing.T, service *UserService) string {
+	t.Helper()
+
+	id := uuid.New()
+	err := service.RegisterUser(context.Background(), RegisterUserRequest{
+		UserID:    id,
+		Email:     id.String() + "@example.com",
+		FullName:  "Jane Doe",
+		Tier:      sharedDomain.UserTierFree,
+		CreatedAt: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id.String()
+}
+
+func TestUseQuotaRetriesOnVersionConflict(t *testing.T) {
+	service, users, _, _ := newStoreUserService()
+	userID := registerFreeUser(t, service)
+	ctx := context.Background()
+
+	// Another request uses a quota between loading and saving the user
+	users.beforeSave = func() {
+		if err := service.UseAIDescriptionQuota(ctx, UseAIDescriptionQuotaRequest{UserID: userID}); err != nil {
+			t.Errorf("concurrent UseAIDescriptionQuota() error = %v", err)
+		}
+	}
+	if err := service.UseAIDescriptionQuota(ctx, UseAIDescriptionQuotaRequest{UserID: userID}); err != nil {
+		t.Fatalf("UseAIDescriptionQuota() error = %v", err)
+	}
+
+	user, err := users.Load(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used := user.User().AIDescriptionQuotaUsed; used != 2 {
+		t.Errorf("used %d AI descriptions, want both requests counted", used)
+	}
+}
+
+func TestUseQuotaGivesUpOnPersistentVersionConflicts(t *testing.T) {
+	service, users, _, _ := newStoreUserService()
+	userID := registerFreeUser(t, service)
+
+	users.conflicts = versionConflictAttempts
+	users.saves = 0
+	err := service.UseAIDescriptionQuota(context.Background(), UseAIDescriptionQuotaRequest{UserID: userID})
+	if !errors.Is(err, sharedEvents.ErrVersionConflict) {
+		t.Fatalf("UseAIDescriptionQuota() error = %v, want a version conflict", err)
+	}
+	if users.saves != versionConflictAttempts {
+		t.Errorf("saved %d times, want %d", users.saves, versionConflictAttempts)
+	}
+}
+
+func TestResetMonthlyQuotasInBatches(t *testing.T) {
+	service, _, tx, recorder := newStoreUserService()
+	for i := 0; i < 2*resetBatchSize+1; i++ {
+		registerFreeUser(t, service)
+	}
+
+	tx.begun = 0
+	if err := service.ResetMonthlyQuotas(context.Background()); err != nil {
+		t.Fatalf("ResetMonthlyQuotas() error = %v", err)
+	}
+
+	if tx.begun != 3 {
+		t.Errorf("reset %d users in %d transactions, want 3", 2*resetBatchSize+1, tx.begun)
+	}
+	updated := 0
+	for _, event := range recorder.Events("user-events") {
+		if event.Type == sharedEvents.UserQuotaUpdatedEvent {
+			updated++
+		}
+	}
+	if updated != 2*resetBatchSize+1 {
+		t.Errorf("published %d quota updated events, want %d", updated, 2*resetBatchSize+1)
+	}
+}
@@ -0,0 +1,322 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	sharedDomain "shared/pkg/domain"
+	sharedEvents "shared/pkg/events"
+
+	"github.com/google/uuid"
+)
+
+// UserStreamPrefix starts the ID of every user stream in the event store
+const UserStreamPrefix = "user-"
+
+// UserStreamID is the event store stream of a user
+func UserStreamID(userID string) string {
+	return UserStreamPrefix + userID
+}
+
+// UserAggregate is a user rebuilt from the events of its stream. Commands check the
+// user's state and record events; the state only ever changes by applying them, so
+// replaying the stream gives the same user. Recorded events are kept as changes until
+// the repository appends them to the stream.
+type UserAggregate struct {
+	user    sharedDomain.User
+	version int64
+	changes []*sharedEvents.Event
+}
+
+// RegisterUser starts the stream of a new user with the limits of their tier
+func RegisterUser(id uuid.UUID, email, fullName string, tier sharedDomain.UserTier, createdAt time.Time) (*UserAggregate, error) {
+	aggregate := &UserAggregate{}
+	err := aggregate.record(sharedEvents.UserRegisteredEvent, sharedEvents.UserRegisteredData{
+		UserID:    id.String(),
+		Email:     email,
+		FullName:  fullName,
+		Tier:      string(tier),
+		CreatedAt: createdAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return aggregate, nil
+}
+
+// RestoreUserAggregate recreates a user from a snapshot of its state at version
+func RestoreUserAggregate(user sharedDomain.User, version int64) *UserAggregate {
+	return &UserAggregate{user: user, version: version}
+}
+
+// LoadFromHistory applies events loaded from the stream after the aggregate's version
+func (a *UserAggregate) LoadFromHistory(events []*sharedEvents.Event) error {
+	for _, event := range events {
+		if err := a.apply(event); err != nil {
+			return err
+		}
+		a.version++
+	}
+	return nil
+}
+
+// User returns a copy of the user's current state
+func (a *UserAggregate) User() *sharedDomain.User {
+	user := a.user
+	return &user
+}
+
+// Version is the version of the stream the aggregate was loaded at, not counting changes
+func (a *UserAggregate) Version() int64 {
+	return a.version
+}
+
+// Changes returns the events recorded since the aggregate was loaded
+func (a *UserAggregate) Changes() []*sharedEvents.Event {
+	return a.changes
+}
+
+// MarkCommitted moves the version past the changes once they are appended to the stream
+func (a *UserAggregate) MarkCommitted() {
+	a.version += int64(len(a.changes))
+	a.changes = nil
+}
+
+func (a *UserAggregate) UseAIDescriptionQuota() error {
+	if !a.user.CanGenerateAIDescription() {
+		return ErrQuotaExceeded
+	}
+	return a.consume(sharedEvents.QuotaAIDescription, a.user.AIDescriptionQuotaUsed+1)
+}
+
+func (a *UserAggregate) UseAIVideoQuota() error {
+	if !a.user.CanGenerateAIVideo() {
+		return ErrQuotaExceeded
+	}
+	return a.consume(sharedEvents.QuotaAIVideo, a.user.AIVideoQuotaUsed+1)
+}
+
+func (a *UserAggregate) UseAutoPostingQuota() error {
+	if !a.user.CanAutoPost() {
+		return ErrQuotaExceeded
+	}
+	return a.consume(sharedEvents.QuotaAutoPosting, a.user.AutoPostingQuotaUsed+1)
+}
+
+func (a *UserAggregate) consume(quota string, used int) error {
+	return a.record(sharedEvents.UserQuotaConsumedEvent, sharedEvents.UserQuotaConsumedData{
+		UserID:     a.user.ID.String(),
+		Quota:      quota,
+		Used:       used,
+		ConsumedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// UpgradeToPro moves the user to the pro tier and its limits; pro users are left alone
+func (a *UserAggregate) UpgradeToPro() error {
+	target := a.user
+	target.UpgradeToPro()
+	return a.changeTier(target)
+}
+
+// DowngradeToFree moves the user to the free tier and its limits; free users are left alone
+func (a *UserAggregate) DowngradeToFree() error {
+	target := a.user
+	target.DowngradeToFree()
+	return a.changeTier(target)
+}
+
+// changeTier records the tier change and one limit change per limit that differs in target
+func (a *UserAggregate) changeTier(target sharedDomain.User) error {
+	if target.Tier == a.user.Tier {
+		return nil
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	err := a.record(sharedEvents.UserTierChangedEvent, sharedEvents.UserTierChangedData{
+		UserID:    a.user.ID.String(),
+		OldTier:   string(a.user.Tier),
+		NewTier:   string(target.Tier),
+		ChangedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	reason := fmt.Sprintf("tier changed to %s", target.Tier)
+	for _, quota := range []string{sharedEvents.QuotaAIDescription, sharedEvents.QuotaAIVideo, sharedEvents.QuotaAutoPosting} {
+		_, oldLimit, _ := quotaFields(&a.user, quota)
+		_, newLimit, _ := quotaFields(&target, quota)
+		if *oldLimit == *newLimit {
+			continue
+		}
+
+		err := a.record(sharedEvents.UserQuotaLimitChangedEvent, sharedEvents.UserQuotaLimitChangedData{
+			UserID:    a.user.ID.String(),
+			Quota:     quota,
+			OldLimit:  *oldLimit,
+			NewLimit:  *newLimit,
+			Reason:    reason,
+			ChangedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncQuotas brings every quota to the used count and limit of a quota updated event,
+// recording a limit change or consumption for each value that differs. Consumption and
+// resets only reach the user-events topic as quota updates, so replaying the topic
+// restores them this way.
+func (a *UserAggregate) SyncQuotas(quotas sharedEvents.QuotaInfoData, at time.Time) error {
+	targets := map[string]sharedEvents.QuotaData{
+		sharedEvents.QuotaAIDescription: quotas.AIDescription,
+		sharedEvents.QuotaAIVideo:       quotas.AIVideo,
+		sharedEvents.QuotaAutoPosting:   quotas.AutoPosting,
+	}
+
+	timestamp := at.UTC().Format(time.RFC3339)
+	for _, quota := range []string{sharedEvents.QuotaAIDescription, sharedEvents.QuotaAIVideo, sharedEvents.QuotaAutoPosting} {
+		target := targets[quota]
+		used, limit, _ := quotaFields(&a.user, quota)
+
+		if *limit != target.Limit {
+			err := a.record(sharedEvents.UserQuotaLimitChangedEvent, sharedEvents.UserQuotaLimitChangedData{
+				UserID:    a.user.ID.String(),
+				Quota:     quota,
+				OldLimit:  *limit,
+				NewLimit:  target.Limit,
+				Reason:    "synchronised with quota update",
+				ChangedAt: timestamp,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if *used != target.Used {
+			err := a.record(sharedEvents.UserQuotaConsumedEvent, sharedEvents.UserQuotaConsumedData{
+				UserID:     a.user.ID.String(),
+				Quota:      quota,
+				Used:       target.Used,
+				ConsumedAt: timestamp,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ResetMonthlyQuotas records the start of a new quota period
+func (a *UserAggregate) ResetMonthlyQuotas() error {
+	return a.record(sharedEvents.UserQuotasResetEvent, sharedEvents.UserQuotasResetData{
+		UserID:  a.user.ID.String(),
+		ResetAt: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// record applies a new event and keeps it as a change
+func (a *UserAggregate) record(eventType string, data interface{}) error {
+	event, err := sharedEvents.NewEvent(eventType, "user-service", "1.0", data)
+	if err != nil {
+		return fmt.Errorf("failed to create %s event: %w", eventType, err)
+	}
+
+	if err := a.apply(event); err != nil {
+		return err
+	}
+	event.Subject = a.user.ID.String()
+
+	a.changes = append(a.changes, event)
+	return nil
+}
+
+// apply changes the user's state by one event of its stream
+func (a *UserAggregate) apply(event *sharedEvents.Event) error {
+	switch event.Type {
+	case sharedEvents.UserRegisteredEvent:
+		var data sharedEvents.UserRegisteredData
+		if err := decode(event, &data); err != nil {
+			return err
+		}
+		id, err := uuid.Parse(data.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to parse user ID: %w", err)
+		}
+
+		a.user = sharedDomain.User{ID: id, Email: data.Email, FullName: data.FullName}
+		if sharedDomain.UserTier(data.Tier) == sharedDomain.UserTierPro {
+			a.user.UpgradeToPro()
+		} else {
+			a.user.DowngradeToFree()
+		}
+		a.user.CreatedAt = event.Timestamp
+		if createdAt, err := time.Parse(time.RFC3339, data.CreatedAt); err == nil {
+			a.user.CreatedAt = createdAt
+		}
+
+	case sharedEvents.UserQuotaConsumedEvent:
+		var data sharedEvents.UserQuotaConsumedData
+		if err := decode(event, &data); err != nil {
+			return err
+		}
+		used, _, ok := quotaFields(&a.user, data.Quota)
+		if !ok {
+			return fmt.Errorf("unknown quota %q in event %s", data.Quota, event.ID)
+		}
+		*used = data.Used
+
+	case sharedEvents.UserQuotaLimitChangedEvent:
+		var data sharedEvents.UserQuotaLimitChangedData
+		if err := decode(event, &data); err != nil {
+			return err
+		}
+		_, limit, ok := quotaFields(&a.user, data.Quota)
+		if !ok {
+			return fmt.Errorf("unknown quota %q in event %s", data.Quota, event.ID)
+		}
+		*limit = data.NewLimit
+
+	case sharedEvents.UserTierChangedEvent:
+		var data sharedEvents.UserTierChangedData
+		if err := decode(event, &data); err != nil {
+			return err
+		}
+		a.user.Tier = sharedDomain.UserTier(data.NewTier)
+
+	case sharedEvents.UserQuotasResetEvent:
+		a.user.ResetMonthlyQuotas()
+
+	default:
+		return fmt.Errorf("unknown event %s in user stream", event.Type)
+	}
+
+	a.user.UpdatedAt = event.Timestamp
+	return nil
+}
+
+func decode(event *sharedEvents.Event, v interface{}) error {
+	if err := json.Unmarshal(event.Data, v); err != nil {
+		return fmt.Errorf("failed to decode %s event %s: %w", event.Type, event.ID, err)
+	}
+	return nil
+}
+
+// quotaFields returns the used and limit fields of a quota
+func quotaFields(user *sharedDomain.User, quota string) (used, limit *int, ok bool) {
+	switch quota {
+	case sharedEvents.QuotaAIDescription:
+		return &user.AIDescriptionQuotaUsed, &user.AIDescriptionQuotaLimit, true
+	case sharedEvents.QuotaAIVideo:
+		return &user.AIVideoQuotaUsed, &user.AIVideoQuotaLimit, true
+	case sharedEvents.QuotaAutoPosting:
+		return &user.AutoPostingQuotaUsed, &user.AutoPostingQuotaLimit, true
+	default:
+		return nil, nil, false
+	}
+}
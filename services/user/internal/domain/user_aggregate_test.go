@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	sharedDomain "shared/pkg/domain"
+	sharedEvents "shared/pkg/events"
+
+	"github.com/google/uuid"
+)
+
+// changedUser registers a free user and runs commands on it, returning the aggregate with
+// every event it recorded still pending
+func changedUser(t *testing.T, commands ...func(*UserAggregate) error) *UserAggregate {
+	t.Helper()
+
+	user, err := RegisterUser(uuid.New(), "jane@example.com", "Jane Doe", sharedDomain.UserTierFree, time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range commands {
+		if err := command(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return user
+}
+
+func TestUserAggregateRebuildsFromItsEvents(t *testing.T) {
+	user := changedUser(t,
+		(*UserAggregate).UseAIDescriptionQuota,
+		(*UserAggregate).UpgradeToPro,
+		(*UserAggregate).UseAIVideoQuota,
+		(*UserAggregate).UseAIVideoQuota,
+	)
+
+	types := make([]string, len(user.Changes()))
+	for i, event := range user.Changes() {
+		types[i] = event.Type
+		if event.Subject != user.User().ID.String() {
+			t.Errorf("event %s has subject %q, want the user ID", event.Type, event.Subject)
+		}
+	}
+	want := []string{
+		sharedEvents.UserRegisteredEvent,
+		sharedEvents.UserQuotaConsumedEvent,
+		sharedEvents.UserTierChangedEvent,
+		sharedEvents.UserQuotaLimitChangedEvent,
+		sharedEvents.UserQuotaLimitChangedEvent,
+		sharedEvents.UserQuotaLimitChangedEvent,
+		sharedEvents.UserQuotaConsumedEvent,
+		sharedEvents.UserQuotaConsumedEvent,
+	}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("recorded %v, want %v", types, want)
+	}
+
+	rebuilt := RestoreUserAggregate(sharedDomain.User{}, 0)
+	if err := rebuilt.LoadFromHistory(user.Changes()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rebuilt.User(), user.User()) {
+		t.Errorf("rebuilt user %+v, want %+v", rebuilt.User(), user.User())
+	}
+	if rebuilt.Version() != int64(len(want)) || len(rebuilt.Changes()) != 0 {
+		t.Errorf("rebuilt user at version %d with %d changes, want version %d and none", rebuilt.Version(), len(rebuilt.Changes()), len(want))
+	}
+
+	got := rebuilt.User()
+	if got.Tier != sharedDomain.UserTierPro || got.AIDescriptionQuotaUsed != 1 || got.AIVideoQuotaUsed != 2 {
+		t.Errorf("rebuilt user is %s with %d descriptions and %d videos used, want pro with 1 and 2", got.Tier, got.AIDescriptionQuotaUsed, got.AIVideoQuotaUsed)
+	}
+}
+
+func TestUserAggregateRebuildsFromSnapshot(t *testing.T) {
+	user := changedUser(t, (*UserAggregate).UseAIDescriptionQuota, (*UserAggregate).UseAIDescriptionQuota)
+	user.MarkCommitted()
+	snapshot, version := *user.User(), user.Version()
+
+	if err := user.ResetMonthlyQuotas(); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.UseAutoPostingQuota(); err != nil {
+		t.Fatal(err)
+	}
+
+	rebuilt := RestoreUserAggregate(snapshot, version)
+	if err := rebuilt.LoadFromHistory(user.Changes()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rebuilt.User(), user.User()) {
+		t.Errorf("user rebuilt from snapshot %+v, want %+v", rebuilt.User(), user.User())
+	}
+	if rebuilt.Version() != version+2 {
+		t.Errorf("rebuilt user at version %d, want %d", rebuilt.Version(), version+2)
+	}
+	if got := rebuilt.User(); got.AIDescriptionQuotaUsed != 0 || got.AutoPostingQuotaUsed != 1 {
+		t.Errorf("rebuilt user has %d descriptions and %d posts used, want 0 and 1", got.AIDescriptionQuotaUsed, got.AutoPostingQuotaUsed)
+	}
+}
+
+func TestUserAggregateRejectsCommandsWithoutRecording(t *testing.T) {
+	user := changedUser(t)
+	user.MarkCommitted()
+
+	// Free users have no video quota and are already on the free tier
+	if err := user.UseAIVideoQuota(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("UseAIVideoQuota() error = %v, want ErrQuotaExceeded", err)
+	}
+	if err := user.DowngradeToFree(); err != nil {
+		t.Errorf("DowngradeToFree() error = %v", err)
+	}
+	if changes := user.Changes(); len(changes) != 0 {
+		t.Errorf("recorded %d events for commands that changed nothing", len(changes))
+	}
+}
+
+func TestUserAggregateRejectsUnknownEvents(t *testing.T) {
+	event, err := sharedEvents.NewEvent(sharedEvents.UserTierUpgradedEvent, "test", "1.0", sharedEvents.UserTierUpgradedData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := RestoreUserAggregate(sharedDomain.User{}, 0)
+	if err := user.LoadFromHistory([]*sharedEvents.Event{event}); err == nil {
+		t.Error("LoadFromHistory() accepted an event that is not part of user streams")
+	}
+}
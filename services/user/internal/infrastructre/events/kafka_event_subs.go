@@ -2,62 +2,69 @@ package events
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"time"
 
 	sharedDomain "shared/pkg/domain"
 	sharedEvents "shared/pkg/events"
 	"user-service/internal/application/services"
-	"user-service/internal/infrastructre/persistence"
 
 	"github.com/google/uuid"
 )
 
+// consumerName identifies this service in the processed events store and is its consumer group
+const consumerName = "user-service"
+
 type UniversalEventSubscriber struct {
-	eventBus    sharedEvents.EventBus
-	userService *services.UserService
-	userRepo    *persistence.PostgresUserRepository
+	eventBus        sharedEvents.EventBus
+	userService     *services.UserService
+	processedEvents sharedEvents.ProcessedEventStore
 }
 
 func NewUniversalEventSubscriber(
 	eventBus sharedEvents.EventBus,
 	userService *services.UserService,
-	userRepo *persistence.PostgresUserRepository,
+	processedEvents sharedEvents.ProcessedEventStore,
 ) *UniversalEventSubscriber {
 	return &UniversalEventSubscriber{
-		eventBus:    eventBus,
-		userService: userService,
-		userRepo:    userRepo,
+		eventBus:        eventBus,
+		userService:     userService,
+		processedEvents: processedEvents,
 	}
 }
 
 func (u *UniversalEventSubscriber) SubscribeToUserEvents(ctx context.Context) error {
 	subscriber := sharedEvents.NewUniversalEventSubscriber(u.eventBus)
-	return subscriber.SubscribeToUserEvents(ctx, u.handleUserEvent)
+	return subscriber.SubscribeToUserEvents(ctx, u.Handler(), sharedEvents.WithGroupID(consumerName))
 }
 
-func (u *UniversalEventSubscriber) handleUserEvent(ctx context.Context, event *sharedEvents.Event) error {
-	switch event.Type {
-	case sharedEvents.UserRegisteredEvent:
-		return u.handleUserRegistered(ctx, event)
-	case sharedEvents.UserTierUpgradedEvent:
-		return u.handleUserTierUpgraded(ctx, event)
-	default:
-		fmt.Printf("Unknown event type: %s\n", event.Type)
-		return nil
-	}
+// Handler returns the handler of the user events the service subscribes to
+func (u *UniversalEventSubscriber) Handler() sharedEvents.EventHandler {
+	return sharedEvents.Idempotent(u.processedEvents, consumerName, u.router().Handle)
 }
 
-func (u *UniversalEventSubscriber) handleUserRegistered(ctx context.Context, event *sharedEvents.Event) error {
-	var data sharedEvents.UserRegisteredData
-	if err := json.Unmarshal(event.Data, &data); err != nil {
-		return fmt.Errorf("failed to unmarshal user registered data: %w", err)
-	}
+// ReplayHandler returns the handler for rebuilding users from the topic. Besides the
+// events the service subscribes to it applies the quota updates the service published
+// itself, which carry consumption, resets and limit changes.
+func (u *UniversalEventSubscriber) ReplayHandler() sharedEvents.EventHandler {
+	router := u.router()
+	sharedEvents.On(router, sharedEvents.UserQuotaUpdatedEvent, u.handleUserQuotaUpdated)
 
+	return sharedEvents.Idempotent(u.processedEvents, consumerName, router.Handle)
+}
+
+func (u *UniversalEventSubscriber) router() *sharedEvents.Router {
+	router := sharedEvents.NewRouter(sharedEvents.WithUnknownEventPolicy(sharedEvents.UnknownEventIgnore))
+	sharedEvents.On(router, sharedEvents.UserRegisteredEvent, u.handleUserRegistered)
+	sharedEvents.On(router, sharedEvents.UserTierUpgradedEvent, u.handleUserTierUpgraded)
+	sharedEvents.On(router, sharedEvents.UserTierRevertedEvent, u.handleUserTierReverted)
+	return router
+}
+
+func (u *UniversalEventSubscriber) handleUserRegistered(ctx context.Context, data sharedEvents.UserRegisteredData) error {
 	userID, err := uuid.Parse(data.UserID)
 	if err != nil {
-		return fmt.Errorf("failed to parse user ID: %w", err)
+		return sharedEvents.DeadLetter(fmt.Errorf("failed to parse user ID: %w", err))
 	}
 
 	var tier sharedDomain.UserTier
@@ -70,46 +77,29 @@ func (u *UniversalEventSubscriber) handleUserRegistered(ctx context.Context, eve
 		tier = sharedDomain.UserTierFree
 	}
 
-	// Create user in user service database using shared domain
-	user := &sharedDomain.User{
-		ID:        userID,
+	req := services.RegisterUserRequest{
+		UserID:    userID,
 		Email:     data.Email,
 		FullName:  data.FullName,
 		Tier:      tier,
 		CreatedAt: parseTime(data.CreatedAt),
-		UpdatedAt: parseTime(data.CreatedAt),
-	}
-
-	// Set default quotas based on tier
-	if user.Tier == sharedDomain.UserTierFree {
-		user.AIDescriptionQuotaLimit = 5
-		user.AIVideoQuotaLimit = 0
-		user.AutoPostingQuotaLimit = 5
-	} else {
-		user.AIDescriptionQuotaLimit = 100
-		user.AIVideoQuotaLimit = 10
-		user.AutoPostingQuotaLimit = 1000
 	}
 
-	if err := u.userRepo.Create(ctx, user); err != nil {
+	if err := u.userService.RegisterUser(ctx, req); err != nil {
 		return fmt.Errorf("failed to create user in user service: %w", err)
 	}
 
-	fmt.Printf("User created in user service: %s (%s)\n", user.ID, user.Email)
+	fmt.Printf("User created in user service: %s (%s)\n", req.UserID, req.Email)
 	return nil
 }
 
-func (u *UniversalEventSubscriber) handleUserTierUpgraded(ctx context.Context, event *sharedEvents.Event) error {
-	var data sharedEvents.UserTierUpgradedData
-	if err := json.Unmarshal(event.Data, &data); err != nil {
-		return fmt.Errorf("failed to unmarshal user tier upgraded data: %w", err)
-	}
-
+func (u *UniversalEventSubscriber) handleUserTierUpgraded(ctx context.Context, data sharedEvents.UserTierUpgradedData) error {
 	req := services.UpgradeToProRequest{
 		UserID: data.UserID,
 	}
 
-	if err := u.userService.UpgradeToPro(ctx, req); err != nil {
+	// Reply to the tier upgrade saga that published the event
+	if err := u.userService.ProvisionTierUpgrade(ctx, req); err != nil {
 		return fmt.Errorf("failed to upgrade user tier: %w", err)
 	}
 
@@ -117,6 +107,33 @@ func (u *UniversalEventSubscriber) handleUserTierUpgraded(ctx context.Context, e
 	return nil
 }
 
+func (u *UniversalEventSubscriber) handleUserTierReverted(ctx context.Context, data sharedEvents.UserTierRevertedData) error {
+	req := services.RevertTierUpgradeRequest{
+		UserID: data.UserID,
+		ToTier: data.ToTier,
+	}
+
+	if err := u.userService.RevertTierUpgrade(ctx, req); err != nil {
+		return fmt.Errorf("failed to revert user tier: %w", err)
+	}
+
+	fmt.Printf("User tier reverted: %s from %s to %s (%s)\n", data.UserID, data.FromTier, data.ToTier, data.Reason)
+	return nil
+}
+
+func (u *UniversalEventSubscriber) handleUserQuotaUpdated(ctx context.Context, data sharedEvents.UserQuotaUpdatedData) error {
+	req := services.SyncQuotasRequest{
+		UserID:    data.UserID,
+		Quotas:    data.Quotas,
+		UpdatedAt: parseTime(data.UpdatedAt),
+	}
+
+	if err := u.userService.SyncQuotas(ctx, req); err != nil {
+		return fmt.Errorf("failed to sync user quotas: %w", err)
+	}
+	return nil
+}
+
 // Helper function to parse time strings
 func parseTime(timeStr string) time.Time {
 	if timeStr == "" {
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	sharedDomain "shared/pkg/domain"
+	sharedEvents "shared/pkg/events"
+	"shared/pkg/events/eventstest"
+	"user-service/internal/application/services"
+	"user-service/internal/domain"
+)
+
+// contractsDir holds the pacts between the services, at the root of the repository
+const contractsDir = "../../../../../contracts"
+
+const uuidPattern = `^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`
+
+// memoryUsers keeps user streams in memory
+type memoryUsers struct {
+	mu      sync.Mutex
+	streams map[string][]*sharedEvents.Event
+}
+
+func (r *memoryUsers) Load(ctx context.Context, userID string) (*domain.UserAggregate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stream, ok := r.streams[userID]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+
+	user := domain.RestoreUserAggregate(sharedDomain.User{}, 0)
+	if err := user.LoadFromHistory(stream); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *memoryUsers) Save(ctx context.Context, user *domain.UserAggregate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userID := user.User().ID.String()
+	r.streams[userID] = append(r.streams[userID], user.Changes()...)
+	user.MarkCommitted()
+	return nil
+}
+
+// passthroughTx runs functions without a transaction
+type passthroughTx struct{}
+
+func (passthroughTx) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (passthroughTx) WithinNewTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// TestAuthServiceContract declares the auth service events the user service consumes and
+// writes the pact the auth service verifies
+func TestAuthServiceContract(t *testing.T) {
+	users := &memoryUsers{streams: make(map[string][]*sharedEvents.Event)}
+	recorder := eventstest.NewRecorder()
+	userService := services.NewUserService(nil, users, sharedEvents.NewUniversalEventPublisher(recorder), passthroughTx{})
+	subscriber := NewUniversalEventSubscriber(recorder, userService, sharedEvents.NewMemoryProcessedEventStore())
+
+	fixture := func(eventType string) interface{} {
+		f, ok := eventstest.LookupFixture(eventType)
+		if !ok {
+			t.Fatalf("no fixture for %s", eventType)
+		}
+		return f.Data
+	}
+
+	// The messages are handled in order, so the upgrade and the revert find the registered user
+	pact := eventstest.NewPact(consumerName, "auth-service")
+	pact.Expect("a registered user", "user-events", sharedEvents.UserRegisteredEvent, fixture(sharedEvents.UserRegisteredEvent)).
+		MatchRegex("user_id", uuidPattern).
+		MatchRegex("tier", "^(free|pro)$")
+	pact.Expect("a tier upgrade", "user-events", sharedEvents.UserTierUpgradedEvent, fixture(sharedEvents.UserTierUpgradedEvent)).
+		MatchRegex("user_id", uuidPattern)
+	pact.Expect("a tier revert", "user-events", sharedEvents.UserTierRevertedEvent, fixture(sharedEvents.UserTierRevertedEvent)).
+		MatchRegex("user_id", uuidPattern).
+		MatchRegex("to_tier", "^(free|pro)$")
+
+	pact.VerifyConsumer(t, subscriber.Handler())
+	if t.Failed() {
+		return
+	}
+
+	// The upgrade is provisioned and the revert restores the free quotas
+	provisioned := 0
+	for _, event := range recorder.Events("user-events") {
+		if event.Type == sharedEvents.UserQuotaProvisionedEvent {
+			provisioned++
+		}
+	}
+	if provisioned != 1 {
+		t.Errorf("published %d quota provisioned events, want 1", provisioned)
+	}
+
+	user, err := users.Load(context.Background(), eventstest.FixtureUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.User().Tier != sharedDomain.UserTierFree {
+		t.Errorf("tier after the revert = %s, want free", user.User().Tier)
+	}
+
+	pact.Write(t, contractsDir)
+}
@@ -0,0 +1,165 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	sharedDomain "shared/pkg/domain"
+	sharedEvents "shared/pkg/events"
+	"user-service/internal/domain"
+)
+
+// DefaultUserSnapshotInterval is how many events a user's stream grows by between snapshots
+const DefaultUserSnapshotInterval = 100
+
+// EventSourcedUserRepository keeps users as event streams and the users table as their
+// projection. Save appends a user's new events with optimistic concurrency on the version
+// it was loaded at and updates the projection in the same transaction.
+//
+// Users recorded before the event store existed have a row but no stream. Loading one
+// adopts it by saving a snapshot of the row as version 0 of its stream.
+type EventSourcedUserRepository struct {
+	store            sharedEvents.EventStore
+	projection       *PostgresUserRepository
+	snapshotInterval int64
+}
+
+func NewEventSourcedUserRepository(store sharedEvents.EventStore, projection *PostgresUserRepository) *EventSourcedUserRepository {
+	return &EventSourcedUserRepository{
+		store:            store,
+		projection:       projection,
+		snapshotInterval: DefaultUserSnapshotInterval,
+	}
+}
+
+// Load rebuilds a user from its latest snapshot and the events recorded after it
+func (r *EventSourcedUserRepository) Load(ctx context.Context, userID string) (*domain.UserAggregate, error) {
+	streamID := domain.UserStreamID(userID)
+
+	aggregate, err := r.loadSnapshot(ctx, streamID)
+	if err != nil {
+		return nil, err
+	}
+
+	var after int64
+	if aggregate != nil {
+		after = aggregate.Version()
+	}
+	events, _, err := r.store.Load(ctx, streamID, after)
+	if err != nil {
+		return nil, err
+	}
+
+	if aggregate == nil {
+		if len(events) == 0 {
+			return r.adopt(ctx, userID)
+		}
+		aggregate = domain.RestoreUserAggregate(sharedDomain.User{}, 0)
+	}
+
+	if err := aggregate.LoadFromHistory(events); err != nil {
+		return nil, fmt.Errorf("failed to rebuild user %s: %w", userID, err)
+	}
+	return aggregate, nil
+}
+
+func (r *EventSourcedUserRepository) loadSnapshot(ctx context.Context, streamID string) (*domain.UserAggregate, error) {
+	snapshot, err := r.store.LoadSnapshot(ctx, streamID)
+	if err != nil || snapshot == nil {
+		return nil, err
+	}
+
+	var user sharedDomain.User
+	if err := json.Unmarshal(snapshot.State, &user); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot of stream %s: %w", streamID, err)
+	}
+	return domain.RestoreUserAggregate(user, snapshot.Version), nil
+}
+
+// adopt starts the stream of a user that only has a row in the users table
+func (r *EventSourcedUserRepository) adopt(ctx context.Context, userID string) (*domain.UserAggregate, error) {
+	user, err := r.projection.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregate := domain.RestoreUserAggregate(*user, 0)
+	if err := r.saveSnapshot(ctx, aggregate); err != nil {
+		return nil, err
+	}
+	return aggregate, nil
+}
+
+// Save appends the user's changes to its stream and projects the result into the users
+// table. It fails with sharedEvents.ErrVersionConflict when the user was changed since it
+// was loaded; load it again and retry the command.
+func (r *EventSourcedUserRepository) Save(ctx context.Context, aggregate *domain.UserAggregate) error {
+	changes := aggregate.Changes()
+	if len(changes) == 0 {
+		return nil
+	}
+
+	user := aggregate.User()
+	from := aggregate.Version()
+	isNew := from == 0 && changes[0].Type == sharedEvents.UserRegisteredEvent
+
+	version, err := r.store.Append(ctx, domain.UserStreamID(user.ID.String()), from, changes)
+	if err != nil {
+		return err
+	}
+	aggregate.MarkCommitted()
+
+	if isNew {
+		err = r.projection.Create(ctx, user)
+	} else {
+		err = r.projection.Update(ctx, user)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to project user %s: %w", user.ID, err)
+	}
+
+	// Snapshot whenever the stream crosses a multiple of the interval
+	if version/r.snapshotInterval > from/r.snapshotInterval {
+		return r.saveSnapshot(ctx, aggregate)
+	}
+	return nil
+}
+
+func (r *EventSourcedUserRepository) saveSnapshot(ctx context.Context, aggregate *domain.UserAggregate) error {
+	user := aggregate.User()
+	state, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot of user %s: %w", user.ID, err)
+	}
+
+	return r.store.SaveSnapshot(ctx, sharedEvents.Snapshot{
+		StreamID: domain.UserStreamID(user.ID.String()),
+		Version:  aggregate.Version(),
+		State:    state,
+	})
+}
+
+// Project writes every user stream of the store into the projection's table, e.g. a
+// shadow table being rebuilt, and returns how many users it wrote
+func (r *EventSourcedUserRepository) Project(ctx context.Context) (int, error) {
+	streamIDs, err := r.store.StreamIDs(ctx, domain.UserStreamPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, streamID := range streamIDs {
+		aggregate, err := r.Load(ctx, strings.TrimPrefix(streamID, domain.UserStreamPrefix))
+		if err != nil {
+			return i, err
+		}
+
+		user := aggregate.User()
+		if err := r.projection.Create(ctx, user); err != nil {
+			return i, fmt.Errorf("failed to project user %s: %w", user.ID, err)
+		}
+	}
+
+	return len(streamIDs), nil
+}
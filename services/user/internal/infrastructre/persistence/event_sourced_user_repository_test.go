@@ -0,0 +1,162 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"shared/pkg/database/databasetest"
+	sharedDomain "shared/pkg/domain"
+	sharedEvents "shared/pkg/events"
+	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// newMemoryUserRepository keeps streams in memory and projects users into a stand-in
+// database, snapshotting every interval events
+func newMemoryUserRepository(interval int64) (*EventSourcedUserRepository, *sharedEvents.MemoryEventStore, *databasetest.DB) {
+	store := sharedEvents.NewMemoryEventStore()
+	db := &databasetest.DB{RowsAffected: 1}
+	repo := NewEventSourcedUserRepository(store, NewPostgresUserRepository(db.Open()))
+	repo.snapshotInterval = interval
+	return repo, store, db
+}
+
+// projected counts the statements that wrote a user into the users table
+func projected(db *databasetest.DB, statement string) int {
+	n := 0
+	for _, exec := range db.Executed() {
+		if strings.HasPrefix(exec, statement) {
+			n++
+		}
+	}
+	return n
+}
+
+func registerUser(t *testing.T, repo *EventSourcedUserRepository) string {
+	t.Helper()
+
+	user, err := domain.RegisterUser(uuid.New(), "jane@example.com", "Jane Doe", sharedDomain.UserTierFree, time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Save(context.Background(), user); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	return user.User().ID.String()
+}
+
+func TestEventSourcedUserRepositoryRoundTrip(t *testing.T) {
+	repo, _, db := newMemoryUserRepository(DefaultUserSnapshotInterval)
+	ctx := context.Background()
+	userID := registerUser(t, repo)
+
+	user, err := repo.Load(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := user.UpgradeToPro(); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.UseAIVideoQuota(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Save(ctx, user); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if len(user.Changes()) != 0 {
+		t.Errorf("%d changes left after Save", len(user.Changes()))
+	}
+
+	loaded, err := repo.Load(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.User(), user.User()) || loaded.Version() != user.Version() {
+		t.Errorf("loaded %+v at version %d, want %+v at version %d", loaded.User(), loaded.Version(), user.User(), user.Version())
+	}
+
+	if created, updated := projected(db, "INSERT INTO users"), projected(db, "UPDATE users"); created != 1 || updated != 1 {
+		t.Errorf("projected %d inserts and %d updates, want one of each", created, updated)
+	}
+}
+
+func TestEventSourcedUserRepositoryLoadsFromSnapshot(t *testing.T) {
+	repo, store, _ := newMemoryUserRepository(4)
+	ctx := context.Background()
+	userID := registerUser(t, repo)
+
+	// The tier change and its limit changes cross the interval; the next use does not
+	user, err := repo.Load(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := user.UpgradeToPro(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Save(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	snapshotted := user.Version()
+	if err := user.UseAIDescriptionQuota(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Save(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := store.LoadSnapshot(ctx, domain.UserStreamID(userID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot == nil {
+		t.Fatalf("no snapshot, want one at version %d", snapshotted)
+	}
+	if snapshot.Version != snapshotted {
+		t.Fatalf("snapshot at version %d, want %d", snapshot.Version, snapshotted)
+	}
+
+	loaded, err := repo.Load(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.User(), user.User()) || loaded.Version() != user.Version() {
+		t.Errorf("loaded %+v at version %d, want %+v at version %d", loaded.User(), loaded.Version(), user.User(), user.Version())
+	}
+}
+
+func TestEventSourcedUserRepositoryRejectsStaleSave(t *testing.T) {
+	repo, _, db := newMemoryUserRepository(DefaultUserSnapshotInterval)
+	ctx := context.Background()
+	userID := registerUser(t, repo)
+
+	first, err := repo.Load(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := repo.Load(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.UseAIDescriptionQuota(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.UseAutoPostingQuota(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Save(ctx, first); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := repo.Save(ctx, second); !errors.Is(err, sharedEvents.ErrVersionConflict) {
+		t.Fatalf("Save() of a stale user error = %v, want a version conflict", err)
+	}
+
+	if updated := projected(db, "UPDATE users"); updated != 1 {
+		t.Errorf("projected %d updates, want only the first save", updated)
+	}
+}
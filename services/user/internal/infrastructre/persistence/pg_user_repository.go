@@ -4,34 +4,45 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"time"
+	"fmt"
 
+	"shared/pkg/database"
 	sharedDomain "shared/pkg/domain"
 	"user-service/internal/domain"
 
 	"github.com/jmoiron/sqlx"
 )
 
+// usersTable is the table the user service keeps users in
+const usersTable = "users"
+
 type PostgresUserRepository struct {
-	db *sqlx.DB
+	db    *sqlx.DB
+	table string
 }
 
 func NewPostgresUserRepository(db *sqlx.DB) *PostgresUserRepository {
-	return &PostgresUserRepository{db: db}
+	return NewPostgresUserRepositoryForTable(db, usersTable)
+}
+
+// NewPostgresUserRepositoryForTable creates a repository on a table with the layout of users,
+// e.g. the shadow table a projection rebuild writes to
+func NewPostgresUserRepositoryForTable(db *sqlx.DB, table string) *PostgresUserRepository {
+	return &PostgresUserRepository{db: db, table: table}
 }
 
 func (r *PostgresUserRepository) Create(ctx context.Context, user *sharedDomain.User) error {
-	query := `
-		INSERT INTO users (
+	query := fmt.Sprintf(`
+		INSERT INTO %s (
 			id, email, full_name, tier, 
 			ai_description_quota_used, ai_description_quota_limit,
 			ai_video_quota_used, ai_video_quota_limit,
 			auto_posting_quota_used, auto_posting_quota_limit,
 			created_at, updated_at
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-	`
+	`, r.table)
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := database.Executor(ctx, r.db).ExecContext(ctx, query,
 		user.ID,
 		user.Email,
 		user.FullName,
@@ -51,17 +62,17 @@ func (r *PostgresUserRepository) Create(ctx context.Context, user *sharedDomain.
 
 func (r *PostgresUserRepository) FindByID(ctx context.Context, id string) (*sharedDomain.User, error) {
 	var user sharedDomain.User
-	query := `
+	query := fmt.Sprintf(`
 		SELECT 
 			id, email, full_name, tier,
 			ai_description_quota_used, ai_description_quota_limit,
 			ai_video_quota_used, ai_video_quota_limit,
 			auto_posting_quota_used, auto_posting_quota_limit,
 			created_at, updated_at
-		FROM users WHERE id = $1
-	`
+		FROM %s WHERE id = $1
+	`, r.table)
 
-	err := r.db.GetContext(ctx, &user, query, id)
+	err := database.Executor(ctx, r.db).GetContext(ctx, &user, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, domain.ErrUserNotFound
@@ -74,17 +85,17 @@ func (r *PostgresUserRepository) FindByID(ctx context.Context, id string) (*shar
 
 func (r *PostgresUserRepository) FindByEmail(ctx context.Context, email string) (*sharedDomain.User, error) {
 	var user sharedDomain.User
-	query := `
+	query := fmt.Sprintf(`
 		SELECT 
 			id, email, full_name, tier,
 			ai_description_quota_used, ai_description_quota_limit,
 			ai_video_quota_used, ai_video_quota_limit,
 			auto_posting_quota_used, auto_posting_quota_limit,
 			created_at, updated_at
-		FROM users WHERE email = $1
-	`
+		FROM %s WHERE email = $1
+	`, r.table)
 
-	err := r.db.GetContext(ctx, &user, query, email)
+	err := database.Executor(ctx, r.db).GetContext(ctx, &user, query, email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, domain.ErrUserNotFound
@@ -96,17 +107,17 @@ func (r *PostgresUserRepository) FindByEmail(ctx context.Context, email string)
 }
 
 func (r *PostgresUserRepository) Update(ctx context.Context, user *sharedDomain.User) error {
-	query := `
-		UPDATE users 
+	query := fmt.Sprintf(`
+		UPDATE %s 
 		SET email = $1, full_name = $2, tier = $3,
 			ai_description_quota_used = $4, ai_description_quota_limit = $5,
 			ai_video_quota_used = $6, ai_video_quota_limit = $7,
 			auto_posting_quota_used = $8, auto_posting_quota_limit = $9,
 			updated_at = $10
 		WHERE id = $11
-	`
+	`, r.table)
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := database.Executor(ctx, r.db).ExecContext(ctx, query,
 		user.Email,
 		user.FullName,
 		string(user.Tier),
@@ -123,36 +134,15 @@ func (r *PostgresUserRepository) Update(ctx context.Context, user *sharedDomain.
 	return err
 }
 
-func (r *PostgresUserRepository) UpdateQuotas(ctx context.Context, userID string, quotas sharedDomain.QuotaInfo) error {
-	query := `
-		UPDATE users 
-		SET ai_description_quota_used = $1,
-			ai_video_quota_used = $2,
-			auto_posting_quota_used = $3,
-			updated_at = $4
-		WHERE id = $5
-	`
-
-	_, err := r.db.ExecContext(ctx, query,
-		quotas.AIDescription.Used,
-		quotas.AIVideo.Used,
-		quotas.AutoPosting.Used,
-		time.Now().UTC(),
-		userID,
-	)
+// ListIDs returns the IDs of all users
+func (r *PostgresUserRepository) ListIDs(ctx context.Context) ([]string, error) {
+	var ids []string
+	query := fmt.Sprintf(`SELECT id FROM %s ORDER BY id`, r.table)
 
-	return err
-}
-
-func (r *PostgresUserRepository) ResetAllMonthlyQuotas(ctx context.Context) error {
-	query := `
-		UPDATE users 
-		SET ai_description_quota_used = 0,
-			ai_video_quota_used = 0,
-			auto_posting_quota_used = 0,
-			updated_at = $1
-	`
+	err := database.Executor(ctx, r.db).SelectContext(ctx, &ids, query)
+	if err != nil {
+		return nil, err
+	}
 
-	_, err := r.db.ExecContext(ctx, query, time.Now().UTC())
-	return err
+	return ids, nil
 }
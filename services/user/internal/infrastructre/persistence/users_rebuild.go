@@ -0,0 +1,113 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Tables of a users projection rebuild
+const (
+	usersShadowTable = "users_rebuild"
+	usersBackupTable = "users_backup"
+)
+
+// UsersRebuild rebuilds the users table in a shadow table and swaps it in once complete.
+// The replaced table is kept as users_backup until the next swap.
+type UsersRebuild struct {
+	db *sqlx.DB
+}
+
+// RebuildDiff compares the shadow table with the live users table
+type RebuildDiff struct {
+	Live      int `db:"live"`
+	Rebuilt   int `db:"rebuilt"`
+	Missing   int `db:"missing"`
+	Unknown   int `db:"unknown"`
+	Differing int `db:"differing"`
+}
+
+func NewUsersRebuild(db *sqlx.DB) *UsersRebuild {
+	return &UsersRebuild{db: db}
+}
+
+// Prepare creates an empty shadow table with the columns, defaults and indexes of users,
+// dropping what a previous rebuild left behind
+func (r *UsersRebuild) Prepare(ctx context.Context) error {
+	query := fmt.Sprintf(`
+		DROP TABLE IF EXISTS %[1]s;
+		CREATE TABLE %[1]s (LIKE %[2]s INCLUDING ALL);
+	`, usersShadowTable, usersTable)
+
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create %s: %w", usersShadowTable, err)
+	}
+	return nil
+}
+
+// Repository returns a repository writing to the shadow table
+func (r *UsersRebuild) Repository() *PostgresUserRepository {
+	return NewPostgresUserRepositoryForTable(r.db, usersShadowTable)
+}
+
+// Diff counts users missing from the shadow table, users only in the shadow table and users
+// whose profile, tier or quotas differ
+func (r *UsersRebuild) Diff(ctx context.Context) (RebuildDiff, error) {
+	var diff RebuildDiff
+	query := fmt.Sprintf(`
+		SELECT
+			COUNT(live.id) AS live,
+			COUNT(rebuilt.id) AS rebuilt,
+			COUNT(*) FILTER (WHERE rebuilt.id IS NULL) AS missing,
+			COUNT(*) FILTER (WHERE live.id IS NULL) AS unknown,
+			COUNT(*) FILTER (WHERE live.id IS NOT NULL AND rebuilt.id IS NOT NULL AND (
+				live.email, live.full_name, live.tier,
+				live.ai_description_quota_used, live.ai_description_quota_limit,
+				live.ai_video_quota_used, live.ai_video_quota_limit,
+				live.auto_posting_quota_used, live.auto_posting_quota_limit
+			) IS DISTINCT FROM (
+				rebuilt.email, rebuilt.full_name, rebuilt.tier,
+				rebuilt.ai_description_quota_used, rebuilt.ai_description_quota_limit,
+				rebuilt.ai_video_quota_used, rebuilt.ai_video_quota_limit,
+				rebuilt.auto_posting_quota_used, rebuilt.auto_posting_quota_limit
+			)) AS differing
+		FROM %s live
+		FULL JOIN %s rebuilt ON rebuilt.id = live.id
+	`, usersTable, usersShadowTable)
+
+	if err := r.db.GetContext(ctx, &diff, query); err != nil {
+		return RebuildDiff{}, fmt.Errorf("failed to compare %s with %s: %w", usersShadowTable, usersTable, err)
+	}
+	return diff, nil
+}
+
+// Swap replaces users with the shadow table in one transaction. Readers and writers of users
+// wait on its lock and then see the rebuilt table.
+func (r *UsersRebuild) Swap(ctx context.Context) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+		LOCK TABLE %[1]s IN ACCESS EXCLUSIVE MODE;
+		DROP TABLE IF EXISTS %[3]s;
+		ALTER TABLE %[1]s RENAME TO %[3]s;
+		ALTER TABLE %[2]s RENAME TO %[1]s;
+	`, usersTable, usersShadowTable, usersBackupTable)
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to swap in %s: %w", usersShadowTable, err)
+	}
+	return tx.Commit()
+}
+
+// Discard drops the shadow table
+func (r *UsersRebuild) Discard(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, "DROP TABLE IF EXISTS "+usersShadowTable); err != nil {
+		return fmt.Errorf("failed to drop %s: %w", usersShadowTable, err)
+	}
+	return nil
+}
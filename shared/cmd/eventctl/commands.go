@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"shared/pkg/events"
+
+	"github.com/google/uuid"
+)
+
+// shutdownTimeout bounds how long a command waits for in-flight handlers and writes on exit
+const shutdownTimeout = 10 * time.Second
+
+// dumpRecord is one line of a dump. Headers are kept next to the event because only
+// headers named like CloudEvents extensions survive its JSON encoding.
+type dumpRecord struct {
+	Event   *events.Event     `json:"event"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+func shutdown(bus events.ManagedEventBus) error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return events.Shutdown(ctx, bus)
+}
+
+func runTail(ctx context.Context, args []string) error {
+	fs, bf := newFlagSet("tail")
+	var filter eventFilter
+	fs.StringVar(&filter.eventType, "type", "", "only events of this type, '*' matches any run of characters")
+	fs.StringVar(&filter.source, "source", "", "only events from this source")
+	fs.Var(&filter.where, "where", "only events whose value at a JSON `path=value` matches, e.g. data.tier=pro; repeatable")
+	group := fs.String("group", "", "consumer group to tail with (default a new group per run)")
+	raw := fs.Bool("json", false, "print events as CloudEvents JSON, one per line")
+	fs.Parse(args)
+
+	bus, err := bf.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *group == "" {
+		host, _ := os.Hostname()
+		*group = fmt.Sprintf("%s-%s-%d", consumerGroup, host, os.Getpid())
+	}
+
+	// Backends may call the handler from several goroutines
+	var mu sync.Mutex
+	handler := func(ctx context.Context, event *events.Event) error {
+		matched, err := filter.matches(event)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping event %s: %v\n", event.ID, err)
+			return nil
+		}
+		if !matched {
+			return nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if *raw {
+			return json.NewEncoder(os.Stdout).Encode(event)
+		}
+		printEvent(os.Stdout, event)
+		return nil
+	}
+
+	err = bus.Subscribe(ctx, bf.topic, handler,
+		events.WithGroupID(*group),
+		events.WithStartOffset(events.StartOffsetLatest),
+		events.WithDeadLetter(false),
+	)
+	if err != nil {
+		bus.Close()
+		return err
+	}
+
+	<-ctx.Done()
+	return shutdown(bus)
+}
+
+// printEvent writes the attributes of an event on one line followed by its indented payload
+func printEvent(w io.Writer, event *events.Event) {
+	fmt.Fprintf(w, "%s  %s  %s  id=%s", event.Timestamp.Format(time.RFC3339Nano), event.Type, event.Source, event.ID)
+	if event.Subject != "" {
+		fmt.Fprintf(w, "  subject=%s", event.Subject)
+	}
+	fmt.Fprintln(w)
+
+	var data bytes.Buffer
+	if err := json.Indent(&data, event.Data, "    ", "  "); err != nil {
+		fmt.Fprintf(w, "    %s\n", event.Data)
+		return
+	}
+	fmt.Fprintf(w, "    %s\n", data.Bytes())
+}
+
+func runPublish(ctx context.Context, args []string) error {
+	fs, bf := newFlagSet("publish")
+	file := fs.String("file", "-", "file to publish, '-' reads stdin")
+	eventType := fs.String("type", "", "publish the file as the payload of a new event of this type instead of as a whole event")
+	source := fs.String("source", consumerGroup, "source of a new event")
+	version := fs.String("version", "1.0", "version of a new event")
+	subject := fs.String("subject", "", "subject of a new event")
+	fs.Parse(args)
+
+	content, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+
+	var event *events.Event
+	if *eventType != "" {
+		if !json.Valid(content) {
+			return fmt.Errorf("%s does not hold valid JSON", *file)
+		}
+		event, err = events.NewEvent(*eventType, *source, *version, json.RawMessage(content))
+		if err != nil {
+			return err
+		}
+		event.Subject = *subject
+	} else {
+		event = &events.Event{}
+		if err := json.Unmarshal(content, event); err != nil {
+			return fmt.Errorf("failed to decode event: %w", err)
+		}
+		if event.ID == "" {
+			event.ID = uuid.New().String()
+		}
+		if event.Timestamp.IsZero() {
+			event.Timestamp = time.Now().UTC()
+		}
+	}
+
+	bus, err := bf.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = bus.Publish(ctx, bf.topic, event)
+	if err == nil {
+		fmt.Fprintf(os.Stderr, "Published %s %s to %s\n", event.Type, event.ID, bf.topic)
+	}
+	return errors.Join(err, shutdown(bus))
+}
+
+func runLag(ctx context.Context, args []string) error {
+	fs, bf := newFlagSet("lag")
+	group := fs.String("group", "", "consumer group, usually the service name")
+	fs.Parse(args)
+
+	if *group == "" {
+		return fmt.Errorf("-group is required")
+	}
+
+	bus, err := bf.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer shutdown(bus)
+
+	reporter, ok := bus.(events.LagReporter)
+	if !ok {
+		return fmt.Errorf("this event bus does not track consumer groups")
+	}
+
+	lag, err := reporter.Lag(ctx, *group, bf.topic)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\t%s\t%d\n", bf.topic, *group, lag)
+	return nil
+}
+
+func runDump(ctx context.Context, args []string) error {
+	fs, bf := newFlagSet("dump")
+	offset := fs.Int64("offset", 0, "offset to dump from")
+	since := fs.String("since", "", "dump events stored at or after this RFC 3339 time instead of from -offset")
+	out := fs.String("out", "-", "file to write, '-' writes stdout")
+	fs.Parse(args)
+
+	from := events.ReplayFrom{Offset: *offset}
+	if *since != "" {
+		t, err := time.Parse(time.RFC3339, *since)
+		if err != nil {
+			return fmt.Errorf("invalid -since %q: %w", *since, err)
+		}
+		from.Time = t
+	}
+
+	bus, err := bf.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer shutdown(bus)
+
+	replayer, ok := bus.(events.Replayer)
+	if !ok {
+		return fmt.Errorf("this event bus does not keep events to dump")
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		if w, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+
+	buf := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buf)
+	dumped := 0
+	err = replayer.Replay(ctx, bf.topic, from, func(ctx context.Context, event *events.Event) error {
+		dumped++
+		return encoder.Encode(dumpRecord{Event: event, Headers: event.Headers})
+	})
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Dumped %d events from %s\n", dumped, bf.topic)
+	return nil
+}
+
+func runRestore(ctx context.Context, args []string) error {
+	fs, bf := newFlagSet("restore")
+	in := fs.String("in", "-", "dump to restore, '-' reads stdin")
+	fs.Parse(args)
+
+	r := os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	bus, err := bf.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	restored, err := restore(ctx, bus, bf.topic, bufio.NewReader(r))
+	fmt.Fprintf(os.Stderr, "Restored %d events to %s\n", restored, bf.topic)
+	return errors.Join(err, shutdown(bus))
+}
+
+// restore publishes every line of a dump in order, stopping at the first failure
+func restore(ctx context.Context, bus events.EventBus, topic string, r *bufio.Reader) (int, error) {
+	restored := 0
+	for line := 1; ; line++ {
+		content, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(content)) > 0 {
+			var record dumpRecord
+			if err := json.Unmarshal(content, &record); err != nil {
+				return restored, fmt.Errorf("failed to decode line %d: %w", line, err)
+			}
+			if record.Event == nil {
+				return restored, fmt.Errorf("line %d holds no event", line)
+			}
+			for key, value := range record.Headers {
+				record.Event.SetHeader(key, value)
+			}
+
+			if err := bus.Publish(ctx, topic, record.Event); err != nil {
+				return restored, fmt.Errorf("failed to publish line %d: %w", line, err)
+			}
+			restored++
+		}
+
+		if errors.Is(err, io.EOF) {
+			return restored, nil
+		}
+		if err != nil {
+			return restored, err
+		}
+	}
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
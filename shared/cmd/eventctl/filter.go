@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"shared/pkg/events"
+)
+
+// eventFilter selects the events tail prints
+type eventFilter struct {
+	eventType string
+	source    string
+	where     whereFlags
+}
+
+// wherePath requires the value at a dotted JSON path of the event, e.g. data.tier, to equal value
+type wherePath struct {
+	path  []string
+	value string
+}
+
+// whereFlags collects repeated -where flags
+type whereFlags []wherePath
+
+func (w *whereFlags) String() string {
+	parts := make([]string, len(*w))
+	for i, p := range *w {
+		parts[i] = strings.Join(p.path, ".") + "=" + p.value
+	}
+	return strings.Join(parts, ",")
+}
+
+func (w *whereFlags) Set(s string) error {
+	path, value, ok := strings.Cut(s, "=")
+	if !ok || path == "" {
+		return fmt.Errorf("expected path=value, got %q", s)
+	}
+	*w = append(*w, wherePath{path: strings.Split(path, "."), value: value})
+	return nil
+}
+
+// matches reports whether event passes every filter. Paths are looked up in the event's
+// CloudEvents JSON, so data.* reaches into the decoded payload.
+func (f eventFilter) matches(event *events.Event) (bool, error) {
+	if f.eventType != "" && !events.MatchTopicPattern(f.eventType, event.Type) {
+		return false, nil
+	}
+	if f.source != "" && event.Source != f.source {
+		return false, nil
+	}
+	if len(f.where) == 0 {
+		return true, nil
+	}
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		return false, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return false, err
+	}
+
+	for _, w := range f.where {
+		value, ok := lookup(doc, w.path)
+		if !ok || formatValue(value) != w.value {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// lookup follows path through objects and, for numeric segments, arrays
+func lookup(doc interface{}, path []string) (interface{}, bool) {
+	for _, segment := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			doc = value
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// formatValue renders strings as they are and anything else as JSON, so -where compares
+// data.tier=pro, data.quota=5 and data.active=true alike
+func formatValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
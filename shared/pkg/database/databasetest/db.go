@@ -0,0 +1,104 @@
+// Package databasetest provides a stand-in database for tests of code built on sqlx that
+// cannot reach Postgres
+package databasetest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DB is a database/sql connector that answers every query with Rows and records every
+// statement executed against it, reporting RowsAffected rows for each. Query and Exec,
+// when set, answer instead, e.g. from a table the test keeps. Transactions are accepted
+// and neither commit nor roll back anything.
+type DB struct {
+	Columns      []string
+	Rows         [][]driver.Value
+	RowsAffected int64
+
+	Query func(query string, args []driver.NamedValue) (columns []string, rows [][]driver.Value, err error)
+	Exec  func(query string, args []driver.NamedValue) (rowsAffected int64, err error)
+
+	mu    sync.Mutex
+	execs []string
+}
+
+// Open returns a sqlx handle on the stand-in using Postgres bind variables
+func (d *DB) Open() *sqlx.DB {
+	return sqlx.NewDb(sql.OpenDB(d), "postgres")
+}
+
+// Executed returns the statements executed so far with their whitespace collapsed
+func (d *DB) Executed() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.execs...)
+}
+
+func (d *DB) Connect(context.Context) (driver.Conn, error) { return d, nil }
+func (d *DB) Driver() driver.Driver                        { return nil }
+
+func (d *DB) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("databasetest: prepared statements are not supported")
+}
+
+func (d *DB) Close() error              { return nil }
+func (d *DB) Begin() (driver.Tx, error) { return d, nil }
+func (d *DB) Commit() error             { return nil }
+func (d *DB) Rollback() error           { return nil }
+
+func (d *DB) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if d.Query != nil {
+		columns, values, err := d.Query(query, args)
+		if err != nil {
+			return nil, err
+		}
+		return &rows{columns: columns, values: values}, nil
+	}
+	return &rows{columns: d.Columns, values: d.Rows}, nil
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	d.mu.Lock()
+	d.execs = append(d.execs, strings.Join(strings.Fields(query), " "))
+	d.mu.Unlock()
+
+	if d.Exec != nil {
+		affected, err := d.Exec(query, args)
+		if err != nil {
+			return nil, err
+		}
+		return driver.RowsAffected(affected), nil
+	}
+	return driver.RowsAffected(d.RowsAffected), nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var (
+	_ driver.Connector      = (*DB)(nil)
+	_ driver.QueryerContext = (*DB)(nil)
+	_ driver.ExecerContext  = (*DB)(nil)
+)
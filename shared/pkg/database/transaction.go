@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Querier is the subset of sqlx shared by *sqlx.DB and *sqlx.Tx
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+}
+
+type txKey struct{}
+
+// TxFromContext returns the transaction bound to ctx, if any
+func TxFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sqlx.Tx)
+	return tx, ok && tx != nil
+}
+
+// Executor returns the transaction bound to ctx, falling back to db
+func Executor(ctx context.Context, db *sqlx.DB) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return db
+}
+
+// TxManager runs units of work inside a single SQL transaction
+type TxManager struct {
+	db *sqlx.DB
+}
+
+// NewTxManager creates a new transaction manager
+func NewTxManager(db *sqlx.DB) *TxManager {
+	return &TxManager{db: db}
+}
+
+// WithinTransaction runs fn with a transaction bound to its context.
+// Nested calls join the outer transaction instead of opening a new one.
+func (m *TxManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// WithinNewTransaction runs fn in a transaction of its own even when ctx carries one, so
+// its changes are committed whatever becomes of the outer transaction
+func (m *TxManager) WithinNewTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.WithinTransaction(context.WithValue(ctx, txKey{}, (*sqlx.Tx)(nil)), fn)
+}
@@ -91,6 +91,15 @@ func (u *User) UpgradeToPro() {
 	u.UpdatedAt = time.Now().UTC()
 }
 
+// DowngradeToFree moves the user to the free tier and its limits; quota used so far is kept
+func (u *User) DowngradeToFree() {
+	u.Tier = UserTierFree
+	u.AIDescriptionQuotaLimit = 5
+	u.AIVideoQuotaLimit = 0
+	u.AutoPostingQuotaLimit = 5
+	u.UpdatedAt = time.Now().UTC()
+}
+
 func (u *User) ResetMonthlyQuotas() {
 	u.AIDescriptionQuotaUsed = 0
 	u.AIVideoQuotaUsed = 0
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"shared/pkg/database"
+)
+
+// BatchPublisher is implemented by buses that publish many events of a topic in one round
+// trip. A failed batch may have been published in part.
+type BatchPublisher interface {
+	PublishBatch(ctx context.Context, topic string, events []*Event) error
+}
+
+// PublishBatch publishes events with one PublishBatch call when bus supports it and one
+// Publish call per event otherwise
+func PublishBatch(ctx context.Context, bus EventBus, topic string, events []*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	if batcher, ok := bus.(BatchPublisher); ok {
+		return batcher.PublishBatch(ctx, topic, events)
+	}
+
+	for _, event := range events {
+		if err := bus.Publish(ctx, topic, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outboxBatchRows bounds the rows of one outbox INSERT, keeping it under Postgres' limit
+// of 65535 parameters
+const outboxBatchRows = 1000
+
+// PublishBatch stores all events in the outbox with multi-row inserts, in the caller's
+// transaction when ctx carries one
+func (o *OutboxEventBus) PublishBatch(ctx context.Context, topic string, events []*Event) error {
+	now := time.Now().UTC()
+
+	for start := 0; start < len(events); start += outboxBatchRows {
+		chunk := events[start:min(start+outboxBatchRows, len(events))]
+
+		values := make([]string, len(chunk))
+		args := make([]interface{}, 0, 5*len(chunk))
+		for i, event := range chunk {
+			injectMetadata(ctx, event)
+
+			payload, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("failed to marshal event: %w", err)
+			}
+
+			n := len(args)
+			values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+5)
+			args = append(args, event.ID, topic, event.Type, payload, now)
+		}
+
+		query := `
+			INSERT INTO outbox (event_id, topic, event_type, payload, created_at, next_attempt_at)
+			VALUES ` + strings.Join(values, ", ")
+
+		if _, err := database.Executor(ctx, o.db).ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to write events to outbox: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// PublishBatch validates every event against its schema before publishing any of them
+func (s *SchemaEventBus) PublishBatch(ctx context.Context, topic string, events []*Event) error {
+	for _, event := range events {
+		if err := s.registry.Validate(event); err != nil {
+			return err
+		}
+	}
+	return PublishBatch(ctx, s.bus, topic, events)
+}
+
+var (
+	_ BatchPublisher = (*KafkaEventBus)(nil)
+	_ BatchPublisher = (*OutboxEventBus)(nil)
+	_ BatchPublisher = (*SchemaEventBus)(nil)
+)
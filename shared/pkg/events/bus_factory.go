@@ -0,0 +1,125 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// Event bus backends selectable with EVENT_BUS
+const (
+	BackendMemory = "memory"
+	BackendKafka  = "kafka"
+	BackendRedis  = "redis"
+	BackendNATS   = "nats"
+	BackendFile   = "file"
+)
+
+// ManagedEventBus is an EventBus with a lifecycle and handler middleware; every backend implements it
+type ManagedEventBus interface {
+	EventBus
+	Lifecycle
+	Use(middleware ...EventMiddleware)
+}
+
+// BusConfig selects and configures an event bus backend
+type BusConfig struct {
+	Backend       string
+	ConsumerGroup string
+	KafkaBrokers  []string
+	// KafkaTransactionalID enables WithTransactionalID on the Kafka bus when set
+	KafkaTransactionalID string
+	RedisAddr            string
+	RedisPassword        string
+	NATSURL              string
+	FileDir              string
+	MemoryOptions        []MemoryOption
+	KafkaOptions         []KafkaOption
+}
+
+// BusConfigFromEnv reads the backend from EVENT_BUS (kafka, redis, nats, memory or file,
+// default memory) and its connection settings from KAFKA_BROKERS, KAFKA_TRANSACTIONAL_ID,
+// REDIS_HOST, REDIS_PORT, REDIS_PASSWORD, NATS_URL and EVENT_BUS_DIR. consumerGroup is
+// usually the service name.
+func BusConfigFromEnv(consumerGroup string) BusConfig {
+	return BusConfig{
+		Backend:              envOrDefault("EVENT_BUS", BackendMemory),
+		ConsumerGroup:        consumerGroup,
+		KafkaBrokers:         strings.Split(envOrDefault("KAFKA_BROKERS", "localhost:9092"), ","),
+		KafkaTransactionalID: os.Getenv("KAFKA_TRANSACTIONAL_ID"),
+		RedisAddr:            envOrDefault("REDIS_HOST", "localhost") + ":" + envOrDefault("REDIS_PORT", "6379"),
+		RedisPassword:        os.Getenv("REDIS_PASSWORD"),
+		NATSURL:              envOrDefault("NATS_URL", nats.DefaultURL),
+		FileDir:              envOrDefault("EVENT_BUS_DIR", filepath.Join(os.TempDir(), "smm-events")),
+	}
+}
+
+// NewEventBus creates the event bus selected by config
+func NewEventBus(ctx context.Context, config BusConfig) (ManagedEventBus, error) {
+	group := config.ConsumerGroup
+	if group == "" {
+		group = DefaultConsumerGroup
+	}
+
+	switch config.Backend {
+	case BackendMemory, "":
+		return NewMemoryEventBus(config.MemoryOptions...), nil
+
+	case BackendKafka:
+		opts := []KafkaOption{WithConsumerGroup(group)}
+		if config.KafkaTransactionalID != "" {
+			opts = append(opts, WithTransactionalID(config.KafkaTransactionalID))
+		}
+		opts = append(opts, config.KafkaOptions...)
+		return NewKafkaEventBus(config.KafkaBrokers, opts...), nil
+
+	case BackendRedis:
+		client := redis.NewClient(&redis.Options{
+			Addr:     config.RedisAddr,
+			Password: config.RedisPassword,
+		})
+		if err := client.Ping(ctx).Err(); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to redis at %s: %w", config.RedisAddr, err)
+		}
+		return NewRedisEventBus(client, WithRedisConsumerGroup(group)), nil
+
+	case BackendNATS:
+		conn, err := nats.Connect(config.NATSURL, nats.Name(group))
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to nats at %s: %w", config.NATSURL, err)
+		}
+		bus, err := NewNATSEventBus(ctx, conn, WithNATSConsumerGroup(group))
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return bus, nil
+
+	case BackendFile:
+		return NewFileEventBus(config.FileDir, WithFileConsumerGroup(group))
+
+	default:
+		return nil, fmt.Errorf("unknown event bus backend %q", config.Backend)
+	}
+}
+
+func envOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+var (
+	_ ManagedEventBus = (*MemoryEventBus)(nil)
+	_ ManagedEventBus = (*KafkaEventBus)(nil)
+	_ ManagedEventBus = (*RedisEventBus)(nil)
+	_ ManagedEventBus = (*NATSEventBus)(nil)
+	_ ManagedEventBus = (*FileEventBus)(nil)
+)
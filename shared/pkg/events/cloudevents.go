@@ -0,0 +1,365 @@
+package events
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// CloudEvents 1.0 constants
+const (
+	CloudEventsSpecVersion = "1.0"
+	CloudEventsContentType = "application/cloudevents+json"
+	JSONContentType        = "application/json"
+
+	// cloudEventsVersionExtension carries Event.Version, which has no CloudEvents attribute
+	cloudEventsVersionExtension = "eventversion"
+	kafkaCloudEventsPrefix      = "ce_"
+	kafkaContentTypeHeader      = "content-type"
+)
+
+// ContentMode selects how events are laid out in a broker message
+type ContentMode int
+
+const (
+	// ContentModeStructured puts the whole CloudEvent in the message value as JSON
+	ContentModeStructured ContentMode = iota
+	// ContentModeBinary puts attributes in ce_* headers and only the payload in the value
+	ContentModeBinary
+)
+
+// cloudEventsAttributes are the context attributes owned by the spec or by Event itself
+var cloudEventsAttributes = map[string]bool{
+	"specversion":               true,
+	"id":                        true,
+	"source":                    true,
+	"type":                      true,
+	"subject":                   true,
+	"time":                      true,
+	"datacontenttype":           true,
+	"dataschema":                true,
+	"data":                      true,
+	"data_base64":               true,
+	cloudEventsVersionExtension: true,
+}
+
+// extensionName matches valid CloudEvents extension attribute names
+var extensionName = regexp.MustCompile(`^[a-z0-9]{1,20}$`)
+
+// MarshalJSON encodes the event in CloudEvents structured JSON mode.
+// Headers with valid extension names become extension attributes. JSON data is
+// embedded as is, data of other content types is base64 encoded in data_base64.
+func (e Event) MarshalJSON() ([]byte, error) {
+	attributes := e.cloudEventAttributes()
+
+	switch {
+	case !isJSONContentType(e.DataContentType):
+		if len(e.Data) > 0 {
+			attributes["data_base64"] = base64.StdEncoding.EncodeToString(e.Data)
+		}
+	case len(e.Data) == 0:
+		attributes["data"] = json.RawMessage("null")
+	default:
+		attributes["data"] = e.Data
+	}
+
+	return json.Marshal(attributes)
+}
+
+// isJSONContentType reports whether data of the media type is embedded as JSON; an
+// empty content type means JSON
+func isJSONContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return mediaType == "" || mediaType == JSONContentType || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// dataContentType returns the media type of the event's data
+func (e Event) dataContentType() string {
+	if e.DataContentType == "" {
+		return JSONContentType
+	}
+	return e.DataContentType
+}
+
+// UnmarshalJSON decodes both CloudEvents structured JSON and the legacy Event shape,
+// whose data is base64 encoded
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+
+	if _, ok := fields["specversion"]; ok {
+		return e.unmarshalCloudEvent(fields)
+	}
+	return e.unmarshalLegacy(b)
+}
+
+func (e Event) cloudEventAttributes() map[string]interface{} {
+	attributes := map[string]interface{}{
+		"specversion":     CloudEventsSpecVersion,
+		"id":              e.ID,
+		"source":          e.Source,
+		"type":            e.Type,
+		"time":            e.Timestamp.UTC().Format(time.RFC3339Nano),
+		"datacontenttype": e.dataContentType(),
+	}
+	if e.Subject != "" {
+		attributes["subject"] = e.Subject
+	}
+	if e.DataSchema != "" {
+		attributes["dataschema"] = e.DataSchema
+	}
+	if e.Version != "" {
+		attributes[cloudEventsVersionExtension] = e.Version
+	}
+
+	for key, value := range e.Headers {
+		if extensionName.MatchString(key) && !cloudEventsAttributes[key] {
+			attributes[key] = value
+		}
+	}
+
+	return attributes
+}
+
+// plainHeaders returns the headers that are not valid extension names, such as the
+// x-dlq-* headers. MarshalJSON leaves them out, so transports storing the JSON document
+// carry them next to it.
+func (e Event) plainHeaders() map[string]string {
+	var headers map[string]string
+	for key, value := range e.Headers {
+		if extensionName.MatchString(key) && !cloudEventsAttributes[key] {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[key] = value
+	}
+	return headers
+}
+
+// mergeHeaders adds headers carried next to the JSON document to a decoded event,
+// keeping the ones the document already set
+func mergeHeaders(event *Event, headers map[string]string) {
+	for key, value := range headers {
+		if _, exists := event.Headers[key]; !exists {
+			event.SetHeader(key, value)
+		}
+	}
+}
+
+func (e *Event) unmarshalCloudEvent(fields map[string]json.RawMessage) error {
+	attributes := make(map[string]string, len(fields))
+	for key, raw := range fields {
+		if key == "data" || key == "data_base64" {
+			continue
+		}
+
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			// Extension attributes may be booleans or integers
+			value = string(raw)
+		}
+		attributes[key] = value
+	}
+
+	var data json.RawMessage
+	if raw, ok := fields["data_base64"]; ok {
+		var encoded string
+		if err := json.Unmarshal(raw, &encoded); err != nil {
+			return fmt.Errorf("invalid data_base64: %w", err)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("invalid data_base64: %w", err)
+		}
+		data = decoded
+	} else if raw, ok := fields["data"]; ok && !bytes.Equal(raw, []byte("null")) {
+		data = raw
+		// Text of other content types is embedded as a JSON string
+		var text string
+		if !isJSONContentType(attributes["datacontenttype"]) && json.Unmarshal(raw, &text) == nil {
+			data = []byte(text)
+		}
+	}
+
+	return e.fromCloudEventAttributes(attributes, data)
+}
+
+func (e *Event) fromCloudEventAttributes(attributes map[string]string, data []byte) error {
+	if attributes["specversion"] != CloudEventsSpecVersion {
+		return fmt.Errorf("unsupported cloudevents specversion %q", attributes["specversion"])
+	}
+
+	timestamp := time.Time{}
+	if value := attributes["time"]; value != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("invalid cloudevents time: %w", err)
+		}
+		timestamp = parsed.UTC()
+	}
+
+	*e = Event{
+		ID:         attributes["id"],
+		Type:       attributes["type"],
+		Source:     attributes["source"],
+		Version:    attributes[cloudEventsVersionExtension],
+		Subject:    attributes["subject"],
+		DataSchema: attributes["dataschema"],
+		Timestamp:  timestamp,
+		Data:       data,
+		Headers:    make(map[string]string),
+	}
+	if contentType := attributes["datacontenttype"]; contentType != JSONContentType {
+		e.DataContentType = contentType
+	}
+
+	for key, value := range attributes {
+		if !cloudEventsAttributes[key] {
+			e.Headers[key] = value
+		}
+	}
+
+	return nil
+}
+
+func (e *Event) unmarshalLegacy(b []byte) error {
+	// legacyEvent has Event's fields without its JSON methods
+	type legacyEvent struct {
+		ID         string            `json:"id"`
+		Type       string            `json:"type"`
+		Source     string            `json:"source"`
+		Version    string            `json:"version"`
+		Subject    string            `json:"subject"`
+		DataSchema string            `json:"dataschema"`
+		Timestamp  time.Time         `json:"timestamp"`
+		Data       json.RawMessage   `json:"data"`
+		Headers    map[string]string `json:"headers"`
+	}
+
+	var legacy legacyEvent
+	if err := json.Unmarshal(b, &legacy); err != nil {
+		return err
+	}
+
+	data := legacy.Data
+	if bytes.HasPrefix(legacy.Data, []byte(`"`)) {
+		// Legacy producers marshalled Data as []byte, i.e. a base64 string
+		var decoded []byte
+		if err := json.Unmarshal(legacy.Data, &decoded); err != nil {
+			return fmt.Errorf("invalid legacy event data: %w", err)
+		}
+		data = decoded
+	} else if bytes.Equal(legacy.Data, []byte("null")) {
+		data = nil
+	}
+
+	*e = Event{
+		ID:         legacy.ID,
+		Type:       legacy.Type,
+		Source:     legacy.Source,
+		Version:    legacy.Version,
+		Subject:    legacy.Subject,
+		DataSchema: legacy.DataSchema,
+		Timestamp:  legacy.Timestamp,
+		Data:       data,
+		Headers:    legacy.Headers,
+	}
+	if e.Headers == nil {
+		e.Headers = make(map[string]string)
+	}
+
+	return nil
+}
+
+// encodeKafkaMessage lays the event out as a Kafka message in the given content mode
+func encodeKafkaMessage(topic string, key []byte, event *Event, mode ContentMode) (kafka.Message, error) {
+	if mode == ContentModeBinary {
+		attributes := event.cloudEventAttributes()
+		delete(attributes, "datacontenttype")
+
+		headers := make([]kafka.Header, 0, len(attributes)+len(event.Headers)+1)
+		headers = append(headers, kafka.Header{Key: kafkaContentTypeHeader, Value: []byte(event.dataContentType())})
+		for name, value := range attributes {
+			headers = append(headers, kafka.Header{Key: kafkaCloudEventsPrefix + name, Value: []byte(fmt.Sprint(value))})
+		}
+		// Headers that are not valid extension names still travel as plain headers
+		for name, value := range event.Headers {
+			if _, ok := attributes[name]; !ok {
+				headers = append(headers, kafka.Header{Key: name, Value: []byte(value)})
+			}
+		}
+
+		return kafka.Message{
+			Topic:   topic,
+			Key:     key,
+			Value:   event.Data,
+			Headers: headers,
+		}, nil
+	}
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	headers := toKafkaHeaders(event.Headers)
+	headers = append(headers, kafka.Header{Key: kafkaContentTypeHeader, Value: []byte(CloudEventsContentType)})
+
+	return kafka.Message{
+		Topic:   topic,
+		Key:     key,
+		Value:   value,
+		Headers: headers,
+	}, nil
+}
+
+// decodeKafkaMessage reads an event in CloudEvents binary, CloudEvents structured or legacy format
+func decodeKafkaMessage(msg kafka.Message) (*Event, error) {
+	var event Event
+
+	if isBinaryCloudEvent(msg.Headers) {
+		attributes := make(map[string]string)
+		for _, h := range msg.Headers {
+			if strings.HasPrefix(h.Key, kafkaCloudEventsPrefix) {
+				attributes[strings.TrimPrefix(h.Key, kafkaCloudEventsPrefix)] = string(h.Value)
+			}
+			// The content-type header carries datacontenttype in binary mode
+			if h.Key == kafkaContentTypeHeader {
+				attributes["datacontenttype"] = string(h.Value)
+			}
+		}
+
+		var data []byte
+		if len(msg.Value) > 0 {
+			data = msg.Value
+		}
+		if err := event.fromCloudEventAttributes(attributes, data); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+
+	mergeKafkaHeaders(&event, msg.Headers)
+	return &event, nil
+}
+
+func isBinaryCloudEvent(headers []kafka.Header) bool {
+	for _, h := range headers {
+		if h.Key == kafkaCloudEventsPrefix+"specversion" {
+			return true
+		}
+	}
+	return false
+}
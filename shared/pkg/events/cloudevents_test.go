@@ -0,0 +1,213 @@
+package events
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// newCloudEvent returns an event with an extension attribute and a header that is not a
+// valid extension name
+func newCloudEvent(t *testing.T) *Event {
+	t.Helper()
+
+	event, err := NewEvent(UserQuotasResetEvent, "user-service", "1.0", UserQuotasResetData{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event.Subject = "u1"
+	event.Timestamp = time.Date(2024, 1, 15, 10, 30, 0, 123456789, time.UTC)
+	event.SetHeader(HeaderCorrelationID, "c1")
+	event.SetHeader(DeadLetterErrorHeader, "quota service unavailable")
+	return event
+}
+
+func kafkaHeaders(msg kafka.Message) map[string]string {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	return headers
+}
+
+func TestCloudEventsStructuredRoundTrip(t *testing.T) {
+	event := newCloudEvent(t)
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(value, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"specversion":     `"1.0"`,
+		"datacontenttype": `"application/json"`,
+		"subject":         `"u1"`,
+		"eventversion":    `"1.0"`,
+		"correlationid":   `"c1"`,
+		"data":            string(event.Data),
+	} {
+		if got := string(fields[name]); got != want {
+			t.Errorf("attribute %s = %s, want %s", name, got, want)
+		}
+	}
+	if _, ok := fields[DeadLetterErrorHeader]; ok {
+		t.Errorf("header %s is not a valid extension name but became an attribute", DeadLetterErrorHeader)
+	}
+
+	var decoded Event
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	want := *event
+	want.Headers = map[string]string{HeaderCorrelationID: "c1"}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("decoded %+v, want %+v", decoded, want)
+	}
+
+	// Kafka carries the headers the document cannot next to it
+	msg, err := encodeKafkaMessage("user-events", []byte("u1"), event, ContentModeStructured)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := kafkaHeaders(msg)[kafkaContentTypeHeader]; got != CloudEventsContentType {
+		t.Errorf("content-type header = %q, want %q", got, CloudEventsContentType)
+	}
+	fromKafka, err := decodeKafkaMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromKafka, event) {
+		t.Errorf("decoded %+v from Kafka, want %+v", fromKafka, event)
+	}
+}
+
+func TestCloudEventsBinaryRoundTrip(t *testing.T) {
+	event := newCloudEvent(t)
+
+	msg, err := encodeKafkaMessage("user-events", []byte("u1"), event, ContentModeBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := kafkaHeaders(msg)
+	for name, want := range map[string]string{
+		"ce_specversion":       "1.0",
+		"ce_id":                event.ID,
+		"ce_type":              UserQuotasResetEvent,
+		"ce_subject":           "u1",
+		"ce_time":              "2024-01-15T10:30:00.123456789Z",
+		"ce_eventversion":      "1.0",
+		"ce_correlationid":     "c1",
+		kafkaContentTypeHeader: JSONContentType,
+		DeadLetterErrorHeader:  "quota service unavailable",
+	} {
+		if got := headers[name]; got != want {
+			t.Errorf("header %s = %q, want %q", name, got, want)
+		}
+	}
+	if _, ok := headers["ce_datacontenttype"]; ok {
+		t.Error("datacontenttype travels as a ce_ header, want the content-type header")
+	}
+	if string(msg.Value) != string(event.Data) {
+		t.Errorf("value = %s, want the data alone", msg.Value)
+	}
+
+	decoded, err := decodeKafkaMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, event) {
+		t.Errorf("decoded %+v, want %+v", decoded, event)
+	}
+}
+
+func TestCloudEventsDecodesLegacyEvents(t *testing.T) {
+	data := base64.StdEncoding.EncodeToString([]byte(`{"user_id":"u1"}`))
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{
+			name:  "base64 data",
+			value: `{"id":"e1","type":"user.quotas.reset","source":"user-service","version":"1.0","timestamp":"2024-01-15T10:30:00Z","data":"` + data + `","headers":{"correlationid":"c1"}}`,
+		},
+		{
+			name:  "embedded data",
+			value: `{"id":"e1","type":"user.quotas.reset","source":"user-service","version":"1.0","timestamp":"2024-01-15T10:30:00Z","data":{"user_id":"u1"},"headers":{"correlationid":"c1"}}`,
+		},
+	}
+
+	want := &Event{
+		ID:        "e1",
+		Type:      UserQuotasResetEvent,
+		Source:    "user-service",
+		Version:   "1.0",
+		Timestamp: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		Data:      json.RawMessage(`{"user_id":"u1"}`),
+		Headers:   map[string]string{HeaderCorrelationID: "c1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := decodeKafkaMessage(kafka.Message{Value: []byte(tt.value)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, want) {
+				t.Errorf("decoded %+v, want %+v", decoded, want)
+			}
+		})
+	}
+}
+
+func TestCloudEventsNonJSONContentType(t *testing.T) {
+	event := newCloudEvent(t)
+	event.DataContentType = "text/plain; charset=utf-8"
+	event.Data = []byte("quotas reset for u1")
+	delete(event.Headers, DeadLetterErrorHeader)
+
+	for _, mode := range []ContentMode{ContentModeStructured, ContentModeBinary} {
+		msg, err := encodeKafkaMessage("user-events", []byte("u1"), event, mode)
+		if err != nil {
+			t.Fatalf("encoding in mode %d: %v", mode, err)
+		}
+		decoded, err := decodeKafkaMessage(msg)
+		if err != nil {
+			t.Fatalf("decoding in mode %d: %v", mode, err)
+		}
+		if !reflect.DeepEqual(decoded, event) {
+			t.Errorf("decoded %+v in mode %d, want %+v", decoded, mode, event)
+		}
+	}
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(value, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["data"]; ok {
+		t.Error("text data embedded as JSON, want data_base64")
+	}
+	if got := string(fields["datacontenttype"]); got != `"text/plain; charset=utf-8"` {
+		t.Errorf("datacontenttype = %s, want the event's", got)
+	}
+
+	// Other producers may embed text as a JSON string
+	var decoded Event
+	text := `{"specversion":"1.0","id":"e1","source":"partner","type":"partner.note","datacontenttype":"text/plain","data":"quotas reset"}`
+	if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded.Data) != "quotas reset" || decoded.DataContentType != "text/plain" {
+		t.Errorf("decoded %q of type %q, want the text", decoded.Data, decoded.DataContentType)
+	}
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// commitFlushTimeout bounds the final commit made when a consumer stops
+const commitFlushTimeout = 5 * time.Second
+
+// offsetCommitter commits processed messages either one by one or, with an
+// interval, in batches holding the latest processed message of each partition
+type offsetCommitter struct {
+	reader   MessageReader
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[topicPartition]kafka.Message
+}
+
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+func newOffsetCommitter(reader MessageReader, interval time.Duration) *offsetCommitter {
+	return &offsetCommitter{
+		reader:   reader,
+		interval: interval,
+		pending:  make(map[topicPartition]kafka.Message),
+	}
+}
+
+// commit records msg as processed, committing it immediately when no interval is set
+func (c *offsetCommitter) commit(ctx context.Context, msg kafka.Message) error {
+	if c.interval <= 0 {
+		return c.reader.CommitMessages(ctx, msg)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.track(msg)
+	return nil
+}
+
+// track keeps msg if it is the latest of its partition; callers hold c.mu
+func (c *offsetCommitter) track(msg kafka.Message) {
+	key := topicPartition{topic: msg.Topic, partition: msg.Partition}
+	if current, exists := c.pending[key]; !exists || msg.Offset > current.Offset {
+		c.pending[key] = msg
+	}
+}
+
+// run flushes pending commits every interval until ctx is done
+func (c *offsetCommitter) run(ctx context.Context) {
+	if c.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.commitPending(ctx); err != nil {
+				log.Printf("Failed to commit offsets: %v", err)
+			}
+		}
+	}
+}
+
+// flush commits whatever is still pending; it is called when the consumer stops,
+// usually after ctx was cancelled, so it uses its own deadline
+func (c *offsetCommitter) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), commitFlushTimeout)
+	defer cancel()
+
+	if err := c.commitPending(ctx); err != nil {
+		log.Printf("Failed to commit offsets: %v", err)
+	}
+}
+
+func (c *offsetCommitter) commitPending(ctx context.Context) error {
+	c.mu.Lock()
+	msgs := make([]kafka.Message, 0, len(c.pending))
+	for _, msg := range c.pending {
+		msgs = append(msgs, msg)
+	}
+	c.pending = make(map[topicPartition]kafka.Message)
+	c.mu.Unlock()
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	if err := c.reader.CommitMessages(ctx, msgs...); err != nil {
+		// Keep the batch so the next flush retries it
+		c.mu.Lock()
+		for _, msg := range msgs {
+			c.track(msg)
+		}
+		c.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
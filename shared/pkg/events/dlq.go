@@ -0,0 +1,300 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers attached to messages routed to a dead-letter topic
+const (
+	DeadLetterErrorHeader           = "x-dlq-error"
+	DeadLetterAttemptsHeader        = "x-dlq-attempts"
+	DeadLetterSourceTopicHeader     = "x-dlq-source-topic"
+	DeadLetterSourcePartitionHeader = "x-dlq-source-partition"
+	DeadLetterSourceOffsetHeader    = "x-dlq-source-offset"
+	DeadLetterFailedAtHeader        = "x-dlq-failed-at"
+
+	deadLetterHeaderPrefix = "x-dlq-"
+	deadLetterTopicSuffix  = ".dlq"
+)
+
+// ErrDeadLetterNotFound is returned when a dead-letter message does not exist
+var ErrDeadLetterNotFound = errors.New("dead-letter message not found")
+
+// DeadLetterTopic returns the dead-letter topic for a source topic
+func DeadLetterTopic(topic string) string {
+	return topic + deadLetterTopicSuffix
+}
+
+// DeadLetterMessage is a message that exhausted its retries, as stored on the dead-letter topic
+type DeadLetterMessage struct {
+	Topic           string            `json:"topic"`
+	Partition       int               `json:"partition"`
+	Offset          int64             `json:"offset"`
+	SourceTopic     string            `json:"source_topic"`
+	SourcePartition int               `json:"source_partition"`
+	SourceOffset    int64             `json:"source_offset"`
+	Error           string            `json:"error"`
+	Attempts        int               `json:"attempts"`
+	FailedAt        time.Time         `json:"failed_at"`
+	Key             []byte            `json:"key"`
+	Value           []byte            `json:"value"`
+	Headers         map[string]string `json:"headers"`
+}
+
+// Event decodes the original event carried by the message
+func (d *DeadLetterMessage) Event() (*Event, error) {
+	return decodeKafkaMessage(kafka.Message{
+		Topic:   d.SourceTopic,
+		Key:     d.Key,
+		Value:   d.Value,
+		Headers: toKafkaHeaders(d.Headers),
+	})
+}
+
+// newDeadLetterMessage builds the dead-letter copy of msg, keeping its original headers
+func newDeadLetterMessage(msg kafka.Message, attempts int, cause error) kafka.Message {
+	headers := make([]kafka.Header, 0, len(msg.Headers)+6)
+	for _, h := range msg.Headers {
+		if !strings.HasPrefix(h.Key, deadLetterHeaderPrefix) {
+			headers = append(headers, h)
+		}
+	}
+
+	headers = append(headers,
+		kafka.Header{Key: DeadLetterErrorHeader, Value: []byte(cause.Error())},
+		kafka.Header{Key: DeadLetterAttemptsHeader, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: DeadLetterSourceTopicHeader, Value: []byte(msg.Topic)},
+		kafka.Header{Key: DeadLetterSourcePartitionHeader, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: DeadLetterSourceOffsetHeader, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: DeadLetterFailedAtHeader, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	return kafka.Message{
+		Topic:   DeadLetterTopic(msg.Topic),
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	}
+}
+
+// newDeadLetterEvent copies event for a dead-letter topic, adding the same x-dlq-* headers
+// as newDeadLetterMessage; it is used by buses that store whole events rather than raw messages
+func newDeadLetterEvent(event *Event, sourceTopic string, partition int, offset string, attempts int, cause error) *Event {
+	dlqEvent := *event
+	dlqEvent.Headers = make(map[string]string, len(event.Headers)+6)
+	for key, value := range event.Headers {
+		if !strings.HasPrefix(key, deadLetterHeaderPrefix) {
+			dlqEvent.Headers[key] = value
+		}
+	}
+
+	dlqEvent.SetHeader(DeadLetterErrorHeader, cause.Error())
+	dlqEvent.SetHeader(DeadLetterAttemptsHeader, strconv.Itoa(attempts))
+	dlqEvent.SetHeader(DeadLetterSourceTopicHeader, sourceTopic)
+	dlqEvent.SetHeader(DeadLetterSourcePartitionHeader, strconv.Itoa(partition))
+	dlqEvent.SetHeader(DeadLetterSourceOffsetHeader, offset)
+	dlqEvent.SetHeader(DeadLetterFailedAtHeader, time.Now().UTC().Format(time.RFC3339Nano))
+	return &dlqEvent
+}
+
+// undecodableEvent wraps a payload that could not be decoded, so it can still be dead-lettered
+func undecodableEvent(id, key string, raw []byte) *Event {
+	data, _ := json.Marshal(string(raw))
+	return &Event{
+		ID:      id,
+		Subject: key,
+		Data:    data,
+		Headers: make(map[string]string),
+	}
+}
+
+func parseDeadLetterMessage(msg kafka.Message) DeadLetterMessage {
+	dlq := DeadLetterMessage{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   make(map[string]string),
+	}
+
+	for _, h := range msg.Headers {
+		value := string(h.Value)
+		switch h.Key {
+		case DeadLetterErrorHeader:
+			dlq.Error = value
+		case DeadLetterAttemptsHeader:
+			dlq.Attempts, _ = strconv.Atoi(value)
+		case DeadLetterSourceTopicHeader:
+			dlq.SourceTopic = value
+		case DeadLetterSourcePartitionHeader:
+			dlq.SourcePartition, _ = strconv.Atoi(value)
+		case DeadLetterSourceOffsetHeader:
+			dlq.SourceOffset, _ = strconv.ParseInt(value, 10, 64)
+		case DeadLetterFailedAtHeader:
+			dlq.FailedAt, _ = time.Parse(time.RFC3339Nano, value)
+		default:
+			dlq.Headers[h.Key] = value
+		}
+	}
+
+	if dlq.SourceTopic == "" {
+		dlq.SourceTopic = strings.TrimSuffix(msg.Topic, deadLetterTopicSuffix)
+	}
+
+	return dlq
+}
+
+// KafkaDeadLetterQueue lists, inspects and re-drives messages on Kafka dead-letter topics
+type KafkaDeadLetterQueue struct {
+	brokers []string
+	writer  *kafka.Writer
+}
+
+// NewKafkaDeadLetterQueue creates a new dead-letter queue client. Re-driven messages are
+// partitioned like KafkaEventBus.Publish partitions them, so they land on the partition of
+// their key and keep its order.
+func NewKafkaDeadLetterQueue(brokers []string) *KafkaDeadLetterQueue {
+	return &KafkaDeadLetterQueue{
+		brokers: brokers,
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Balancer:               &kafka.Murmur2Balancer{},
+			AllowAutoTopicCreation: true,
+			RequiredAcks:           kafka.RequireAll,
+		},
+	}
+}
+
+// List returns up to limit messages from the dead-letter topic of topic, oldest first per partition
+func (q *KafkaDeadLetterQueue) List(ctx context.Context, topic string, limit int) ([]DeadLetterMessage, error) {
+	dlqTopic := DeadLetterTopic(topic)
+
+	partitions, err := q.partitions(ctx, dlqTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []DeadLetterMessage
+	for _, partition := range partitions {
+		remaining := limit - len(messages)
+		if limit > 0 && remaining <= 0 {
+			break
+		}
+
+		batch, err := q.readPartition(ctx, dlqTopic, partition, remaining)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, batch...)
+	}
+
+	return messages, nil
+}
+
+// Inspect returns a single dead-letter message of topic by partition and offset. Records of
+// aborted transactions are not found.
+func (q *KafkaDeadLetterQueue) Inspect(ctx context.Context, topic string, partition int, offset int64) (*DeadLetterMessage, error) {
+	dlqTopic := DeadLetterTopic(topic)
+
+	first, last, err := committedRange(ctx, q.brokers, dlqTopic, partition, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if offset < first || offset >= last {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	var found *DeadLetterMessage
+	err = readCommitted(ctx, q.brokers, dlqTopic, partition, offset, offset+1, func(msg kafka.Message) (bool, error) {
+		if msg.Offset == offset {
+			dlq := parseDeadLetterMessage(msg)
+			found = &dlq
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead-letter message: %w", err)
+	}
+	if found == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	return found, nil
+}
+
+// Redrive publishes a dead-letter message back onto its source topic with its original headers
+func (q *KafkaDeadLetterQueue) Redrive(ctx context.Context, msg *DeadLetterMessage) error {
+	headers := make([]kafka.Header, 0, len(msg.Headers))
+	for key, value := range msg.Headers {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+
+	// Messages dead-lettered without a key are keyed like the bus keys their event
+	key := msg.Key
+	if len(key) == 0 {
+		if event, err := msg.Event(); err == nil {
+			key = []byte(partitionKey(event))
+		}
+	}
+
+	err := q.writer.WriteMessages(ctx, kafka.Message{
+		Topic:   msg.SourceTopic,
+		Key:     key,
+		Value:   msg.Value,
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to re-drive message to %s: %w", msg.SourceTopic, err)
+	}
+
+	return nil
+}
+
+// Close releases the underlying writer
+func (q *KafkaDeadLetterQueue) Close() error {
+	return q.writer.Close()
+}
+
+func (q *KafkaDeadLetterQueue) partitions(ctx context.Context, topic string) ([]int, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", q.brokers[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to broker: %w", err)
+	}
+	defer conn.Close()
+
+	partitions, err := conn.ReadPartitions(topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partitions of %s: %w", topic, err)
+	}
+
+	ids := make([]int, 0, len(partitions))
+	for _, p := range partitions {
+		ids = append(ids, p.ID)
+	}
+	return ids, nil
+}
+
+func (q *KafkaDeadLetterQueue) readPartition(ctx context.Context, topic string, partition int, limit int) ([]DeadLetterMessage, error) {
+	first, last, err := committedRange(ctx, q.brokers, topic, partition, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []DeadLetterMessage
+	err = readCommitted(ctx, q.brokers, topic, partition, first, last, func(msg kafka.Message) (bool, error) {
+		messages = append(messages, parseDeadLetterMessage(msg))
+		return limit <= 0 || len(messages) < limit, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead-letter messages: %w", err)
+	}
+	return messages, nil
+}
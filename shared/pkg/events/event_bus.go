@@ -2,59 +2,225 @@ package events
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"shared/pkg/domain"
+	"shared/pkg/tracing"
 
 	"github.com/segmentio/kafka-go"
 )
 
 type EventBus interface {
 	Publish(ctx context.Context, topic string, event *Event) error
-	Subscribe(ctx context.Context, topic string, handler EventHandler) error
+	Subscribe(ctx context.Context, topic string, handler EventHandler, opts ...SubscribeOption) error
 	Close() error
 }
 
 type EventHandler func(ctx context.Context, event *Event) error
 
 type EventPublisher interface {
-	PublishUserRegistered(ctx context.Context, user interface{}) error
-	PublishUserTierUpgraded(ctx context.Context, userID string, oldTier, newTier interface{}) error
-	PublishUserQuotaUpdated(ctx context.Context, userID string, quotas interface{}) error
+	PublishUserRegistered(ctx context.Context, user *domain.User) error
+	PublishUserTierUpgraded(ctx context.Context, userID string, oldTier, newTier domain.UserTier) error
+	PublishUserQuotaUpdated(ctx context.Context, userID string, quotas domain.QuotaInfo) error
+}
+
+// ProductEventPublisher publishes the product catalog events. Timestamps left empty in
+// the payloads are set to the current time.
+type ProductEventPublisher interface {
+	PublishProductCreated(ctx context.Context, data ProductCreatedData) error
+	PublishProductUpdated(ctx context.Context, data ProductUpdatedData) error
+	PublishProductDeleted(ctx context.Context, data ProductDeletedData) error
+}
+
+// ContentEventPublisher publishes the lifecycle events of social media posts. Timestamps
+// left empty in the payloads are set to the current time.
+type ContentEventPublisher interface {
+	PublishContentDrafted(ctx context.Context, data ContentDraftedData) error
+	PublishContentScheduled(ctx context.Context, data ContentScheduledData) error
+	PublishContentPublished(ctx context.Context, data ContentPublishedData) error
+	PublishContentFailed(ctx context.Context, data ContentFailedData) error
+}
+
+// AIEventPublisher publishes AI content requests and their results. Timestamps left empty
+// in the payloads are set to the current time.
+type AIEventPublisher interface {
+	PublishAIDescriptionRequested(ctx context.Context, data AIDescriptionRequestedData) error
+	PublishAIDescriptionCompleted(ctx context.Context, data AIDescriptionCompletedData) error
+	PublishAIDescriptionFailed(ctx context.Context, data AIDescriptionFailedData) error
+	PublishAIVideoRequested(ctx context.Context, data AIVideoRequestedData) error
+	PublishAIVideoCompleted(ctx context.Context, data AIVideoCompletedData) error
+}
+
+// DefaultConsumerGroup is the consumer group used when neither the bus nor the subscription sets one
+const DefaultConsumerGroup = "smm-platform"
+
+// MessageReader is the part of *kafka.Reader used by KafkaEventBus; tests can supply a fake
+type MessageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// MessageWriter is the part of *kafka.Writer used by KafkaEventBus; tests can supply a fake
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// ReaderFactory creates the reader of a subscription
+type ReaderFactory func(config kafka.ReaderConfig) MessageReader
+
+// TopicLister lists the topics of a cluster; pattern subscriptions use it to find their topics
+type TopicLister interface {
+	ListTopics(ctx context.Context) ([]string, error)
 }
 
 type KafkaEventBus struct {
-	brokers []string
-	writer  *kafka.Writer
-	readers map[string]*kafka.Reader
+	brokers     []string
+	writer      MessageWriter
+	ackWriter   MessageWriter // synchronous writer for dead letters and the outbox relay
+	producer    KafkaProducerConfig
+	newReader   ReaderFactory
+	topics      TopicLister
+	readers     []MessageReader
+	groupID     string
+	isolation   kafka.IsolationLevel
+	contentMode ContentMode
+	middleware  []EventMiddleware
+
+	// transactions commits what handlers publish with their offsets, see WithTransactionalID
+	transactionalID string
+	transactions    TransactionWriter
+
+	state        busState
+	pending      []subscription
+	stopFetching []context.CancelFunc
+	consumers    sync.WaitGroup
+	mu           sync.RWMutex
+}
+
+// subscription is a Subscribe call waiting for the bus to start
+type subscription struct {
+	ctx     context.Context
+	topic   string
+	handler EventHandler
+	config  SubscribeConfig
+}
+
+// KafkaOption configures a KafkaEventBus
+type KafkaOption func(*KafkaEventBus)
+
+// WithContentMode sets the CloudEvents content mode used for published messages
+func WithContentMode(mode ContentMode) KafkaOption {
+	return func(k *KafkaEventBus) {
+		k.contentMode = mode
+	}
+}
+
+// WithConsumerGroup sets the default consumer group for subscriptions, usually the service name
+func WithConsumerGroup(groupID string) KafkaOption {
+	return func(k *KafkaEventBus) {
+		k.groupID = groupID
+	}
+}
+
+// WithIsolationLevel sets which records subscriptions read. The default, kafka.ReadCommitted,
+// hides records of open and aborted transactions written by transactional producers such as
+// Kafka Connect or Streams; kafka.ReadUncommitted reads them too.
+func WithIsolationLevel(level kafka.IsolationLevel) KafkaOption {
+	return func(k *KafkaEventBus) {
+		k.isolation = level
+	}
+}
+
+// WithMessageWriter replaces the Kafka writer, e.g. with an in-process stand-in
+func WithMessageWriter(writer MessageWriter) KafkaOption {
+	return func(k *KafkaEventBus) {
+		k.writer = writer
+	}
+}
+
+// WithReaderFactory replaces how subscription readers are created, e.g. with an in-process stand-in
+func WithReaderFactory(factory ReaderFactory) KafkaOption {
+	return func(k *KafkaEventBus) {
+		k.newReader = factory
+	}
+}
+
+// WithTopicLister replaces how pattern subscriptions list topics, e.g. with an in-process stand-in
+func WithTopicLister(lister TopicLister) KafkaOption {
+	return func(k *KafkaEventBus) {
+		k.topics = lister
+	}
 }
 
-func NewKafkaEventBus(brokers []string) *KafkaEventBus {
-	writer := &kafka.Writer{
-		Addr:                   kafka.TCP(brokers...),
-		Balancer:               &kafka.LeastBytes{},
-		AllowAutoTopicCreation: true,
+func NewKafkaEventBus(brokers []string, opts ...KafkaOption) *KafkaEventBus {
+	bus := &KafkaEventBus{
+		brokers:     brokers,
+		producer:    DefaultKafkaProducerConfig(),
+		newReader:   newKafkaReader,
+		topics:      kafkaTopicLister{brokers: brokers},
+		groupID:     DefaultConsumerGroup,
+		isolation:   kafka.ReadCommitted,
+		contentMode: ContentModeStructured,
+	}
+
+	for _, opt := range opts {
+		opt(bus)
+	}
+
+	if bus.writer == nil {
+		bus.writer = bus.newWriter(bus.producer.Async)
+		if bus.producer.Async {
+			bus.ackWriter = bus.newWriter(false)
+		}
+	}
+	if bus.ackWriter == nil {
+		bus.ackWriter = bus.writer
 	}
 
-	return &KafkaEventBus{
-		brokers: brokers,
-		writer:  writer,
-		readers: make(map[string]*kafka.Reader),
+	if bus.transactionalID == "" {
+		bus.transactions = nil
+	} else if bus.transactions == nil {
+		bus.transactions = bus.newTransactionWriter()
 	}
+
+	return bus
 }
 
+// Publish writes the event keyed by its aggregate ID (Subject), so all events of one
+// aggregate land on the same partition and are consumed in order
 func (k *KafkaEventBus) Publish(ctx context.Context, topic string, event *Event) error {
-	eventBytes, err := json.Marshal(event)
+	k.mu.RLock()
+	closed := k.state == busClosed
+	k.mu.RUnlock()
+	if closed {
+		return ErrBusClosed
+	}
+
+	key := partitionKey(event)
+
+	ctx, span := tracing.StartKafkaProducerSpan(ctx, topic, key)
+	defer span.End()
+
+	injectMetadata(ctx, event)
+
+	msg, err := encodeKafkaMessage(topic, []byte(key), event, k.contentMode)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return err
 	}
 
-	err = k.writer.WriteMessages(ctx, kafka.Message{
-		Topic: topic,
-		Key:   []byte(event.ID),
-		Value: eventBytes,
-	})
+	err = k.writerFor(ctx).WriteMessages(ctx, msg)
 	if err != nil {
+		span.RecordError(err)
 		return fmt.Errorf("failed to publish event: %w", err)
 	}
 
@@ -62,54 +228,424 @@ func (k *KafkaEventBus) Publish(ctx context.Context, topic string, event *Event)
 	return nil
 }
 
-func (k *KafkaEventBus) Subscribe(ctx context.Context, topic string, handler EventHandler) error {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: k.brokers,
-		Topic:   topic,
-		GroupID: "smm-platform",
-	})
+// Use appends middleware applied to every handler invocation, after built-in panic recovery
+func (k *KafkaEventBus) Use(middleware ...EventMiddleware) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.middleware = append(k.middleware, middleware...)
+}
+
+func (k *KafkaEventBus) wrap(handler EventHandler) EventHandler {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return Chain(Chain(handler, k.middleware...), Recover())
+}
+
+// Subscribe registers a consumer for topic, which starts fetching once the bus is started.
+// Messages of a partition are handled one at a time, so per-aggregate ordering established
+// by Publish is preserved. The consumer stops when ctx is done or the bus is drained.
+//
+// topic may also be a pattern such as "user.*" or "*.upgraded", matched against the topic
+// and the event type. kafka-go has no regex subscriptions, so the consumer group reads every
+// topic that exists when the consumer starts and skips events the pattern does not match;
+// topics created later are picked up when the service restarts.
+func (k *KafkaEventBus) Subscribe(ctx context.Context, topic string, handler EventHandler, opts ...SubscribeOption) error {
+	sub := subscription{
+		ctx:     ctx,
+		topic:   topic,
+		handler: handler,
+		config:  newSubscribeConfig(opts...),
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	switch k.state {
+	case busDraining, busClosed:
+		return ErrBusClosed
+	case busRunning:
+		k.startConsumer(sub)
+	default:
+		k.pending = append(k.pending, sub)
+	}
+	return nil
+}
+
+// Start starts the consumers of all subscriptions registered so far
+func (k *KafkaEventBus) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
 
-	k.readers[topic] = reader
+	switch k.state {
+	case busDraining, busClosed:
+		return ErrBusClosed
+	case busRunning:
+		return nil
+	}
 
-	go k.consumeMessages(ctx, reader, handler)
+	k.state = busRunning
+	for _, sub := range k.pending {
+		k.startConsumer(sub)
+	}
+	k.pending = nil
 	return nil
 }
 
-func (k *KafkaEventBus) consumeMessages(ctx context.Context, reader *kafka.Reader, handler EventHandler) {
-	for {
+// startConsumer starts consuming a subscription in the background; callers hold k.mu
+func (k *KafkaEventBus) startConsumer(sub subscription) {
+	groupID := sub.config.GroupID
+	if groupID == "" {
+		groupID = k.groupID
+	}
+	sub.config.GroupID = groupID
+
+	// Draining only stops fetching; in-flight handlers keep the subscription's context
+	fetchCtx, stopFetching := context.WithCancel(sub.ctx)
+	k.stopFetching = append(k.stopFetching, stopFetching)
+
+	k.consumers.Add(1)
+	go func() {
+		defer k.consumers.Done()
+
+		// Offsets are committed explicitly by consumeMessages, which does its own batching
+		config := kafka.ReaderConfig{
+			Brokers:        k.brokers,
+			GroupID:        groupID,
+			StartOffset:    sub.config.StartOffset,
+			MinBytes:       sub.config.MinBytes,
+			MaxBytes:       sub.config.MaxBytes,
+			IsolationLevel: k.isolation,
+		}
+
+		if IsTopicPattern(sub.topic) {
+			topics, err := k.resolvePattern(fetchCtx, sub.topic, sub.config.Retry)
+			if err != nil {
+				return
+			}
+			config.GroupTopics = topics
+		} else {
+			config.Topic = sub.topic
+		}
+
+		for {
+			reader, err := k.addReader(config)
+			if err != nil {
+				return
+			}
+
+			err = k.consumeMessages(fetchCtx, sub.ctx, reader, sub)
+			if err == nil {
+				return
+			}
+
+			// Closing the reader leaves the group, which rebalances and redelivers the
+			// uncommitted message from the committed offset
+			log.Printf("Rejoining group %s after failure: %v", groupID, err)
+			k.removeReader(reader)
+
+			select {
+			case <-fetchCtx.Done():
+				return
+			case <-time.After(sub.config.Retry.Backoff(1)):
+			}
+		}
+	}()
+}
+
+// addReader creates a reader that is closed together with the bus
+func (k *KafkaEventBus) addReader(config kafka.ReaderConfig) (MessageReader, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.state == busClosed {
+		return nil, ErrBusClosed
+	}
+
+	reader := k.newReader(config)
+	k.readers = append(k.readers, reader)
+	return reader, nil
+}
+
+// removeReader closes a reader created by addReader and forgets it
+func (k *KafkaEventBus) removeReader(reader MessageReader) {
+	k.mu.Lock()
+	k.readers = slices.DeleteFunc(k.readers, func(r MessageReader) bool { return r == reader })
+	k.mu.Unlock()
+
+	if err := reader.Close(); err != nil {
+		log.Printf("Failed to close reader: %v", err)
+	}
+}
+
+// resolvePattern lists the topics a pattern subscription reads, waiting until there is
+// at least one; it only fails when ctx is done
+func (k *KafkaEventBus) resolvePattern(ctx context.Context, pattern string, retry RetryPolicy) ([]string, error) {
+	for attempt := 1; ; attempt++ {
+		all, err := k.topics.ListTopics(ctx)
+		if err != nil {
+			log.Printf("Failed to list topics for pattern %s: %v", pattern, err)
+		} else if topics := patternTopics(pattern, all); len(topics) > 0 {
+			log.Printf("Pattern %s subscribed to topics: %s", pattern, strings.Join(topics, ", "))
+			return topics, nil
+		}
+
 		select {
 		case <-ctx.Done():
-			return
-		default:
-			msg, err := reader.ReadMessage(ctx)
-			if err != nil {
-				log.Printf("Error reading message: %v", err)
-				continue
+			return nil, ctx.Err()
+		case <-time.After(retry.Backoff(attempt)):
+		}
+	}
+}
+
+// consumeMessages delivers messages at least once: an offset is only committed after
+// the handler succeeded or the message was routed to the dead-letter topic. It returns nil
+// once the subscription stops, or the error that left a message uncommitted.
+func (k *KafkaEventBus) consumeMessages(fetchCtx, ctx context.Context, reader MessageReader, sub subscription) error {
+	config := sub.config
+	committer := newOffsetCommitter(reader, config.commitInterval())
+	go committer.run(fetchCtx)
+	defer committer.flush()
+
+	failures := 0
+	for fetchCtx.Err() == nil {
+		msg, err := reader.FetchMessage(fetchCtx)
+		if err != nil {
+			if fetchCtx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil
 			}
 
-			var event Event
-			if err := json.Unmarshal(msg.Value, &event); err != nil {
-				log.Printf("Error unmarshaling event: %v", err)
-				continue
+			failures++
+			log.Printf("Error reading message: %v", err)
+
+			select {
+			case <-fetchCtx.Done():
+				return nil
+			case <-time.After(config.Retry.Backoff(failures)):
 			}
+			continue
+		}
+		failures = 0
 
-			if err := handler(ctx, &event); err != nil {
-				log.Printf("Error handling event: %v", err)
+		published, err := k.processMessage(ctx, msg, sub)
+		if err == nil && k.transactions != nil {
+			err = k.commitTransaction(ctx, msg, published, config)
+		}
+		if err != nil {
+			// Committing a later offset would implicitly commit this one, so stop here
+			log.Printf("Leaving message from %s[%d]@%d uncommitted: %v", msg.Topic, msg.Partition, msg.Offset, err)
+			if ctx.Err() != nil {
+				return nil
 			}
+			return err
 		}
+		if k.transactions != nil {
+			// The transaction committed the offset
+			continue
+		}
+
+		if err := committer.commit(ctx, msg); err != nil {
+			log.Printf("Failed to commit offset %s[%d]@%d: %v", msg.Topic, msg.Partition, msg.Offset, err)
+		}
+	}
+	return nil
+}
+
+// processMessage handles a message, routing it to the dead-letter topic when handling fails.
+// It only returns an error when the message was neither handled nor dead-lettered. In
+// transactional mode it returns what the handler published, or the dead letter, for the
+// transaction that commits the offset instead of writing it.
+func (k *KafkaEventBus) processMessage(ctx context.Context, msg kafka.Message, sub subscription) ([]kafka.Message, error) {
+	config := sub.config
+
+	event, err := decodeKafkaMessage(msg)
+	if err != nil {
+		log.Printf("Error decoding event: %v", err)
+		return k.deadLetter(ctx, msg, config, 0, err)
+	}
+
+	// Pattern subscriptions read whole topics; skip the events the pattern does not match
+	if !subscriptionMatches(sub.topic, msg.Topic, event) {
+		return nil, nil
+	}
+
+	handler := k.wrap(sub.handler)
+	current := func() *kafkaTransaction { return nil }
+	if k.transactions != nil {
+		handler, current = k.transactional(handler)
+	}
+
+	handlerCtx, span := tracing.StartKafkaConsumerSpan(extractMetadata(ctx, event), msg.Topic, msg.Partition, msg.Offset)
+	attempts, err := handleWithRetry(handlerCtx, config.Retry, handler, event)
+	endSpan(span, err)
+	published := current().end()
+
+	if err != nil {
+		log.Printf("Error handling event %s after %d attempts: %v", event.ID, attempts, err)
+		return k.deadLetter(ctx, msg, config, attempts, err)
 	}
+
+	return published, nil
 }
 
+// deadLetter routes a message that could not be handled to its dead-letter topic, retrying
+// the write until it succeeds, the retry policy is exhausted or ctx is done. Without dead-lettering the message is dropped.
+// The write is synchronous even for an async producer, since the offset is committed next.
+// In transactional mode the dead letter is returned for the transaction instead.
+func (k *KafkaEventBus) deadLetter(ctx context.Context, msg kafka.Message, config SubscribeConfig, attempts int, cause error) ([]kafka.Message, error) {
+	if !config.DeadLetter {
+		log.Printf("Dropping message from %s[%d]@%d: %v", msg.Topic, msg.Partition, msg.Offset, cause)
+		return nil, nil
+	}
+
+	dlqMsg := newDeadLetterMessage(msg, attempts, cause)
+	if k.transactions != nil {
+		return []kafka.Message{dlqMsg}, nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := k.ackWriter.WriteMessages(ctx, dlqMsg)
+		if err == nil {
+			break
+		}
+
+		log.Printf("Failed to route message to dead-letter topic %s (attempt %d): %v", DeadLetterTopic(msg.Topic), attempt, err)
+		if config.Retry.Exhausted(attempt) {
+			return nil, fmt.Errorf("failed to route message to dead-letter topic: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to route message to dead-letter topic: %w", err)
+		case <-time.After(config.Retry.Backoff(attempt)):
+		}
+	}
+
+	log.Printf("Message from %s[%d]@%d routed to %s", msg.Topic, msg.Partition, msg.Offset, DeadLetterTopic(msg.Topic))
+	return nil, nil
+}
+
+// Drain stops fetching and waits until in-flight messages are handled and committed or
+// ctx is done. Publishing keeps working until Close, so handlers can still emit events.
+func (k *KafkaEventBus) Drain(ctx context.Context) error {
+	k.mu.Lock()
+	if k.state == busClosed {
+		k.mu.Unlock()
+		return ErrBusClosed
+	}
+	k.state = busDraining
+	k.pending = nil
+	for _, stop := range k.stopFetching {
+		stop()
+	}
+	k.mu.Unlock()
+
+	if err := waitGroupDone(ctx, &k.consumers); err != nil {
+		return fmt.Errorf("failed to drain kafka event bus: %w", err)
+	}
+	return nil
+}
+
+// Close stops all consumers without waiting for them, flushes the writer and closes every
+// reader. Call Drain first for a graceful shutdown.
 func (k *KafkaEventBus) Close() error {
-	if err := k.writer.Close(); err != nil {
-		return err
+	k.mu.Lock()
+	if k.state == busClosed {
+		k.mu.Unlock()
+		return nil
 	}
+	k.state = busClosed
+	for _, stop := range k.stopFetching {
+		stop()
+	}
+	readers := k.readers
+	k.mu.Unlock()
 
-	for _, reader := range k.readers {
+	var errs []error
+	if err := k.writer.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close writer: %w", err))
+	}
+	if k.ackWriter != k.writer {
+		if err := k.ackWriter.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close writer: %w", err))
+		}
+	}
+	if k.transactions != nil {
+		if err := k.transactions.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close transactional writer: %w", err))
+		}
+	}
+	for _, reader := range readers {
 		if err := reader.Close(); err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("failed to close reader: %w", err))
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+func toKafkaHeaders(headers map[string]string) []kafka.Header {
+	kafkaHeaders := make([]kafka.Header, 0, len(headers))
+	for key, value := range headers {
+		kafkaHeaders = append(kafkaHeaders, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	return kafkaHeaders
+}
+
+// mergeKafkaHeaders copies plain message headers onto the event without overriding its own
+func mergeKafkaHeaders(event *Event, headers []kafka.Header) {
+	for _, h := range headers {
+		if h.Key == kafkaContentTypeHeader || strings.HasPrefix(h.Key, kafkaCloudEventsPrefix) {
+			continue
+		}
+		if _, exists := event.Headers[h.Key]; !exists {
+			event.SetHeader(h.Key, string(h.Value))
+		}
+	}
+}
+
+func newKafkaReader(config kafka.ReaderConfig) MessageReader {
+	return kafka.NewReader(config)
+}
+
+// kafkaTopicLister reads the topic list from cluster metadata
+type kafkaTopicLister struct {
+	brokers []string
+}
+
+func (l kafkaTopicLister) ListTopics(ctx context.Context) ([]string, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", l.brokers[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to broker: %w", err)
+	}
+	defer conn.Close()
+
+	partitions, err := conn.ReadPartitions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topics: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var topics []string
+	for _, p := range partitions {
+		if !seen[p.Topic] {
+			seen[p.Topic] = true
+			topics = append(topics, p.Topic)
+		}
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+// partitionKey returns the aggregate ID of the event, falling back to the event ID
+func partitionKey(event *Event) string {
+	if event.Subject != "" {
+		return event.Subject
+	}
+	return event.ID
 }
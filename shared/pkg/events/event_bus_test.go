@@ -0,0 +1,419 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"shared/pkg/events"
+	"shared/pkg/events/eventstest"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const testGroup = "test-group"
+
+// fastRetry keeps retry tests quick
+var fastRetry = events.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 1}
+
+// dlqFailingWriter fails the first failures writes to a dead-letter topic, or every one
+// when failures is 0, and passes others through
+type dlqFailingWriter struct {
+	*eventstest.Kafka
+	failures int32
+	attempts atomic.Int32
+}
+
+func (w *dlqFailingWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, msg := range msgs {
+		if !strings.HasSuffix(msg.Topic, ".dlq") {
+			continue
+		}
+		if attempt := w.attempts.Add(1); w.failures == 0 || attempt <= w.failures {
+			return errors.New("broker unavailable")
+		}
+	}
+	return w.Kafka.WriteMessages(ctx, msgs...)
+}
+
+func newTestKafkaBus(t *testing.T, k *eventstest.Kafka, opts ...events.KafkaOption) *events.KafkaEventBus {
+	t.Helper()
+
+	bus := events.NewKafkaEventBus(nil, append(k.Options(), opts...)...)
+	t.Cleanup(func() { bus.Close() })
+	return bus
+}
+
+func publishTestEvent(t *testing.T, bus events.EventBus, topic string) *events.Event {
+	t.Helper()
+	return publishUserEvent(t, bus, topic, "u1")
+}
+
+// publishUserEvent publishes a quotas reset event keyed by userID
+func publishUserEvent(t *testing.T, bus events.EventBus, topic, userID string) *events.Event {
+	t.Helper()
+
+	event, err := events.NewEvent(events.UserQuotasResetEvent, "test", "1.0", events.UserQuotasResetData{UserID: userID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event.Subject = userID
+	if err := bus.Publish(context.Background(), topic, event); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	return event
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestKafkaEventBusCommitsAfterHandlerSucceeds(t *testing.T) {
+	k := eventstest.NewKafka()
+	bus := newTestKafkaBus(t, k)
+	publishTestEvent(t, bus, "user-events")
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := func(ctx context.Context, event *events.Event) error {
+		close(entered)
+		<-release
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := bus.Subscribe(ctx, "user-events", handler, events.WithGroupID(testGroup)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	<-entered
+	if committed := k.Committed(testGroup, "user-events"); committed != -1 {
+		t.Errorf("offset %d committed while the handler is running", committed)
+	}
+
+	close(release)
+	waitFor(t, "the offset to be committed", func() bool {
+		return k.Committed(testGroup, "user-events") == 1
+	})
+	if messages := k.Messages("user-events.dlq"); len(messages) != 0 {
+		t.Errorf("handled message was dead-lettered: %v", messages)
+	}
+}
+
+func TestKafkaEventBusRetriesThenDeadLetters(t *testing.T) {
+	k := eventstest.NewKafka()
+	bus := newTestKafkaBus(t, k)
+	event := publishTestEvent(t, bus, "user-events")
+
+	var calls atomic.Int32
+	handler := func(ctx context.Context, event *events.Event) error {
+		calls.Add(1)
+		return errors.New("quota service unavailable")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := bus.Subscribe(ctx, "user-events", handler, events.WithGroupID(testGroup), events.WithRetryPolicy(fastRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the offset to be committed", func() bool {
+		return k.Committed(testGroup, "user-events") == 1
+	})
+	if got := calls.Load(); got != int32(fastRetry.MaxAttempts) {
+		t.Errorf("handler called %d times, want %d", got, fastRetry.MaxAttempts)
+	}
+
+	dlq := k.Messages(events.DeadLetterTopic("user-events"))
+	if len(dlq) != 1 {
+		t.Fatalf("dead-letter topic has %d messages, want 1", len(dlq))
+	}
+
+	headers := make(map[string]string)
+	for _, h := range dlq[0].Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	want := map[string]string{
+		events.DeadLetterErrorHeader:           "quota service unavailable",
+		events.DeadLetterAttemptsHeader:        "3",
+		events.DeadLetterSourceTopicHeader:     "user-events",
+		events.DeadLetterSourcePartitionHeader: "0",
+		events.DeadLetterSourceOffsetHeader:    "0",
+	}
+	for key, value := range want {
+		if headers[key] != value {
+			t.Errorf("header %s = %q, want %q", key, headers[key], value)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339Nano, headers[events.DeadLetterFailedAtHeader]); err != nil {
+		t.Errorf("header %s = %q is not a timestamp", events.DeadLetterFailedAtHeader, headers[events.DeadLetterFailedAtHeader])
+	}
+	if string(dlq[0].Key) != "u1" {
+		t.Errorf("dead-letter key = %q, want u1", dlq[0].Key)
+	}
+
+	dead, err := (&events.DeadLetterMessage{SourceTopic: "user-events", Key: dlq[0].Key, Value: dlq[0].Value, Headers: headers}).Event()
+	if err != nil {
+		t.Fatalf("failed to decode dead-lettered event: %v", err)
+	}
+	if dead.ID != event.ID {
+		t.Errorf("dead-lettered event %s, want %s", dead.ID, event.ID)
+	}
+}
+
+func TestKafkaEventBusDeadLettersMarkedErrorsWithoutRetrying(t *testing.T) {
+	k := eventstest.NewKafka()
+	bus := newTestKafkaBus(t, k)
+	publishTestEvent(t, bus, "user-events")
+
+	var calls atomic.Int32
+	handler := func(ctx context.Context, event *events.Event) error {
+		calls.Add(1)
+		return events.DeadLetter(errors.New("invalid payload"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := bus.Subscribe(ctx, "user-events", handler, events.WithGroupID(testGroup), events.WithRetryPolicy(fastRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the offset to be committed", func() bool {
+		return k.Committed(testGroup, "user-events") == 1
+	})
+	if got := calls.Load(); got != 1 {
+		t.Errorf("handler called %d times, want 1", got)
+	}
+	if dlq := k.Messages(events.DeadLetterTopic("user-events")); len(dlq) != 1 {
+		t.Errorf("dead-letter topic has %d messages, want 1", len(dlq))
+	}
+}
+
+func TestKafkaEventBusLeavesOffsetWhenDeadLetteringFails(t *testing.T) {
+	k := eventstest.NewKafka()
+	writer := &dlqFailingWriter{Kafka: k}
+	bus := newTestKafkaBus(t, k, events.WithMessageWriter(writer))
+	publishTestEvent(t, bus, "user-events")
+
+	handler := func(ctx context.Context, event *events.Event) error {
+		return errors.New("quota service unavailable")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := bus.Subscribe(ctx, "user-events", handler, events.WithGroupID(testGroup), events.WithRetryPolicy(fastRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bus retries the dead-letter write, rejoins the group and retries the message
+	// until the subscription is cancelled
+	waitFor(t, "the message to be redelivered", func() bool {
+		return writer.attempts.Load() >= 2*int32(fastRetry.MaxAttempts)
+	})
+	cancel()
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelDrain()
+	if err := bus.Drain(drainCtx); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+
+	if committed := k.Committed(testGroup, "user-events"); committed != -1 {
+		t.Errorf("offset %d committed although the message was neither handled nor dead-lettered", committed)
+	}
+}
+
+func TestKafkaEventBusRedeliversAfterDeadLetteringFails(t *testing.T) {
+	k := eventstest.NewKafka()
+	writer := &dlqFailingWriter{Kafka: k, failures: int32(fastRetry.MaxAttempts)}
+	bus := newTestKafkaBus(t, k, events.WithMessageWriter(writer))
+	publishTestEvent(t, bus, "user-events")
+
+	var calls atomic.Int32
+	handler := func(ctx context.Context, event *events.Event) error {
+		calls.Add(1)
+		return errors.New("quota service unavailable")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := bus.Subscribe(ctx, "user-events", handler, events.WithGroupID(testGroup), events.WithRetryPolicy(fastRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The consumer gives up on the dead-letter write, rejoins and handles the message again
+	waitFor(t, "the redelivered message to be dead-lettered", func() bool {
+		return k.Committed(testGroup, "user-events") == 1
+	})
+	if got, want := calls.Load(), 2*int32(fastRetry.MaxAttempts); got != want {
+		t.Errorf("handler called %d times, want %d", got, want)
+	}
+	if dlq := k.Messages(events.DeadLetterTopic("user-events")); len(dlq) != 1 {
+		t.Errorf("dead-letter topic has %d messages, want 1", len(dlq))
+	}
+}
+
+// txnFailingWriter fails every transaction and passes plain writes through
+type txnFailingWriter struct {
+	*eventstest.Kafka
+	attempts atomic.Int32
+}
+
+func (w *txnFailingWriter) WriteTransaction(ctx context.Context, group string, consumed kafka.Message, msgs ...kafka.Message) error {
+	w.attempts.Add(1)
+	return errors.New("transaction coordinator unavailable")
+}
+
+func TestKafkaEventBusCommitsHandlerOutputWithOffset(t *testing.T) {
+	k := eventstest.NewKafka()
+	bus := newTestKafkaBus(t, k, events.WithTransactionalID("user-service-0"))
+	publishTestEvent(t, bus, "user-events")
+
+	var calls atomic.Int32
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := func(ctx context.Context, event *events.Event) error {
+		publishUserEvent(t, &contextBus{EventBus: bus, ctx: ctx}, "quota-events", "u1")
+		// The first attempt fails after publishing; its event must not be written
+		if calls.Add(1) == 1 {
+			return errors.New("quota service unavailable")
+		}
+		close(entered)
+		<-release
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := bus.Subscribe(ctx, "user-events", handler, events.WithGroupID(testGroup), events.WithRetryPolicy(fastRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	<-entered
+	if messages := k.Messages("quota-events"); len(messages) != 0 {
+		t.Errorf("%d events written before the handler returned", len(messages))
+	}
+	if committed := k.Committed(testGroup, "user-events"); committed != -1 {
+		t.Errorf("offset %d committed while the handler is running", committed)
+	}
+
+	close(release)
+	waitFor(t, "the transaction to be committed", func() bool {
+		return k.Committed(testGroup, "user-events") == 1
+	})
+	if messages := k.Messages("quota-events"); len(messages) != 1 {
+		t.Errorf("transaction wrote %d events, want the one of the successful attempt", len(messages))
+	}
+}
+
+func TestKafkaEventBusDeadLettersInTransaction(t *testing.T) {
+	k := eventstest.NewKafka()
+	bus := newTestKafkaBus(t, k, events.WithTransactionalID("user-service-0"))
+	publishTestEvent(t, bus, "user-events")
+
+	handler := func(ctx context.Context, event *events.Event) error {
+		publishUserEvent(t, &contextBus{EventBus: bus, ctx: ctx}, "quota-events", "u1")
+		return events.DeadLetter(errors.New("invalid payload"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := bus.Subscribe(ctx, "user-events", handler, events.WithGroupID(testGroup)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the transaction to be committed", func() bool {
+		return k.Committed(testGroup, "user-events") == 1
+	})
+	if dlq := k.Messages(events.DeadLetterTopic("user-events")); len(dlq) != 1 {
+		t.Errorf("dead-letter topic has %d messages, want 1", len(dlq))
+	}
+	if messages := k.Messages("quota-events"); len(messages) != 0 {
+		t.Errorf("failed handler wrote %d events", len(messages))
+	}
+}
+
+func TestKafkaEventBusLeavesOffsetWhenTransactionFails(t *testing.T) {
+	k := eventstest.NewKafka()
+	writer := &txnFailingWriter{Kafka: k}
+	bus := newTestKafkaBus(t, k, events.WithTransactionalID("user-service-0"), events.WithTransactionWriter(writer))
+	publishTestEvent(t, bus, "user-events")
+
+	handler := func(ctx context.Context, event *events.Event) error {
+		publishUserEvent(t, &contextBus{EventBus: bus, ctx: ctx}, "quota-events", "u1")
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := bus.Subscribe(ctx, "user-events", handler, events.WithGroupID(testGroup), events.WithRetryPolicy(fastRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bus retries the transaction, rejoins the group and retries the message until
+	// the subscription is cancelled
+	waitFor(t, "the message to be redelivered", func() bool {
+		return writer.attempts.Load() >= 2*int32(fastRetry.MaxAttempts)
+	})
+	cancel()
+	drain(t, bus)
+
+	if committed := k.Committed(testGroup, "user-events"); committed != -1 {
+		t.Errorf("offset %d committed although the transaction failed", committed)
+	}
+	if messages := k.Messages("quota-events"); len(messages) != 0 {
+		t.Errorf("%d events written although the transaction failed", len(messages))
+	}
+}
+
+// contextBus publishes with a fixed context, like a handler publishing with its own
+type contextBus struct {
+	events.EventBus
+	ctx context.Context
+}
+
+func (b *contextBus) Publish(_ context.Context, topic string, event *events.Event) error {
+	return b.EventBus.Publish(b.ctx, topic, event)
+}
@@ -0,0 +1,310 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"shared/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Expected versions for EventStore.Append besides the version the caller last loaded
+const (
+	// AnyVersion appends without checking the stream's version
+	AnyVersion int64 = -1
+	// NoStream appends only to a stream that has no events yet
+	NoStream int64 = 0
+)
+
+// ErrVersionConflict is returned when a stream was appended to since the caller loaded it
+var ErrVersionConflict = errors.New("event stream version conflict")
+
+// Snapshot is the state of a stream's aggregate as of a version, so loading it only needs
+// the events recorded after that version
+type Snapshot struct {
+	StreamID  string    `db:"stream_id"`
+	Version   int64     `db:"version"`
+	State     []byte    `db:"state"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// EventStore is an append-only store of event streams, e.g. one stream per aggregate.
+// Versions count the events of a stream from 1.
+type EventStore interface {
+	// Append adds events to the end of the stream and returns its new version. It fails
+	// with ErrVersionConflict unless the stream is at expectedVersion.
+	Append(ctx context.Context, streamID string, expectedVersion int64, events []*Event) (int64, error)
+	// Load returns the events of the stream after the given version and the stream's version
+	Load(ctx context.Context, streamID string, afterVersion int64) ([]*Event, int64, error)
+	// SaveSnapshot stores a snapshot unless a newer one is already stored
+	SaveSnapshot(ctx context.Context, snapshot Snapshot) error
+	// LoadSnapshot returns the latest snapshot of the stream, or nil when there is none
+	LoadSnapshot(ctx context.Context, streamID string) (*Snapshot, error)
+	// StreamIDs lists the streams whose ID starts with prefix
+	StreamIDs(ctx context.Context, prefix string) ([]string, error)
+}
+
+// PostgresEventStore keeps streams in the event_store table and snapshots in
+// event_snapshots. Appends join the transaction carried by ctx, so the events commit
+// together with projections and outbox rows written alongside them.
+type PostgresEventStore struct {
+	db        *sqlx.DB
+	txManager *database.TxManager
+}
+
+// NewPostgresEventStore creates a new Postgres-backed event store
+func NewPostgresEventStore(db *sqlx.DB) *PostgresEventStore {
+	return &PostgresEventStore{
+		db:        db,
+		txManager: database.NewTxManager(db),
+	}
+}
+
+// Append stamps metadata onto the events and inserts them after the stream's last version.
+// A concurrent append of the same versions makes the later one fail with ErrVersionConflict.
+func (s *PostgresEventStore) Append(ctx context.Context, streamID string, expectedVersion int64, events []*Event) (int64, error) {
+	var version int64
+	err := s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		exec := database.Executor(ctx, s.db)
+
+		query := `SELECT COALESCE(MAX(version), 0) FROM event_store WHERE stream_id = $1`
+		if err := exec.GetContext(ctx, &version, query, streamID); err != nil {
+			return fmt.Errorf("failed to read version of stream %s: %w", streamID, err)
+		}
+		if expectedVersion != AnyVersion && version != expectedVersion {
+			return fmt.Errorf("%w: stream %s is at version %d, expected %d", ErrVersionConflict, streamID, version, expectedVersion)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		now := time.Now().UTC()
+		values := make([]string, len(events))
+		args := make([]interface{}, 0, 6*len(events))
+		for i, event := range events {
+			injectMetadata(ctx, event)
+
+			payload, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("failed to marshal event: %w", err)
+			}
+
+			n := len(args)
+			values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6)
+			args = append(args, streamID, version+int64(i)+1, event.ID, event.Type, payload, now)
+		}
+
+		query = `
+			INSERT INTO event_store (stream_id, version, event_id, event_type, payload, recorded_at)
+			VALUES ` + strings.Join(values, ", ") + `
+			ON CONFLICT (stream_id, version) DO NOTHING
+		`
+		result, err := exec.ExecContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to append to stream %s: %w", streamID, err)
+		}
+
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if inserted != int64(len(events)) {
+			return fmt.Errorf("%w: stream %s was appended to concurrently", ErrVersionConflict, streamID)
+		}
+
+		version += int64(len(events))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// Load returns the events of the stream after afterVersion in order
+func (s *PostgresEventStore) Load(ctx context.Context, streamID string, afterVersion int64) ([]*Event, int64, error) {
+	query := `
+		SELECT version, payload FROM event_store
+		WHERE stream_id = $1 AND version > $2
+		ORDER BY version
+	`
+
+	var rows []struct {
+		Version int64  `db:"version"`
+		Payload []byte `db:"payload"`
+	}
+	if err := database.Executor(ctx, s.db).SelectContext(ctx, &rows, query, streamID, afterVersion); err != nil {
+		return nil, 0, fmt.Errorf("failed to load stream %s: %w", streamID, err)
+	}
+
+	version := afterVersion
+	events := make([]*Event, len(rows))
+	for i, row := range rows {
+		var event Event
+		if err := json.Unmarshal(row.Payload, &event); err != nil {
+			return nil, 0, fmt.Errorf("failed to unmarshal event %d of stream %s: %w", row.Version, streamID, err)
+		}
+		events[i] = &event
+		version = row.Version
+	}
+
+	return events, version, nil
+}
+
+// SaveSnapshot upserts the stream's snapshot, keeping the newer of the two
+func (s *PostgresEventStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	query := `
+		INSERT INTO event_snapshots (stream_id, version, state, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (stream_id) DO UPDATE
+		SET version = EXCLUDED.version, state = EXCLUDED.state, created_at = EXCLUDED.created_at
+		WHERE event_snapshots.version < EXCLUDED.version
+	`
+
+	_, err := database.Executor(ctx, s.db).ExecContext(ctx, query, snapshot.StreamID, snapshot.Version, snapshot.State, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot of stream %s: %w", snapshot.StreamID, err)
+	}
+	return nil
+}
+
+// LoadSnapshot returns the stream's snapshot, or nil when there is none
+func (s *PostgresEventStore) LoadSnapshot(ctx context.Context, streamID string) (*Snapshot, error) {
+	query := `SELECT stream_id, version, state, created_at FROM event_snapshots WHERE stream_id = $1`
+
+	var snapshot Snapshot
+	if err := database.Executor(ctx, s.db).GetContext(ctx, &snapshot, query, streamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load snapshot of stream %s: %w", streamID, err)
+	}
+	return &snapshot, nil
+}
+
+// StreamIDs lists the streams with events or a snapshot whose ID starts with prefix
+func (s *PostgresEventStore) StreamIDs(ctx context.Context, prefix string) ([]string, error) {
+	query := `
+		SELECT stream_id FROM event_store WHERE starts_with(stream_id, $1)
+		UNION
+		SELECT stream_id FROM event_snapshots WHERE starts_with(stream_id, $1)
+		ORDER BY stream_id
+	`
+
+	var ids []string
+	if err := database.Executor(ctx, s.db).SelectContext(ctx, &ids, query, prefix); err != nil {
+		return nil, fmt.Errorf("failed to list streams: %w", err)
+	}
+	return ids, nil
+}
+
+// MemoryEventStore is an in-memory EventStore for tests and tools that must not touch
+// the service's store
+type MemoryEventStore struct {
+	mu        sync.Mutex
+	streams   map[string][]*Event
+	snapshots map[string]Snapshot
+}
+
+// NewMemoryEventStore creates a new in-memory event store
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{
+		streams:   make(map[string][]*Event),
+		snapshots: make(map[string]Snapshot),
+	}
+}
+
+// Append adds events to the stream if it is at expectedVersion
+func (s *MemoryEventStore) Append(ctx context.Context, streamID string, expectedVersion int64, events []*Event) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version := int64(len(s.streams[streamID]))
+	if expectedVersion != AnyVersion && version != expectedVersion {
+		return 0, fmt.Errorf("%w: stream %s is at version %d, expected %d", ErrVersionConflict, streamID, version, expectedVersion)
+	}
+
+	for _, event := range events {
+		injectMetadata(ctx, event)
+	}
+	s.streams[streamID] = append(s.streams[streamID], events...)
+
+	return version + int64(len(events)), nil
+}
+
+// Load returns the events of the stream after afterVersion
+func (s *MemoryEventStore) Load(ctx context.Context, streamID string, afterVersion int64) ([]*Event, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream := s.streams[streamID]
+	version := int64(len(stream))
+	if afterVersion >= version {
+		return nil, max(afterVersion, version), nil
+	}
+
+	events := make([]*Event, version-afterVersion)
+	copy(events, stream[afterVersion:])
+	return events, version, nil
+}
+
+// SaveSnapshot stores the snapshot unless a newer one is already stored
+func (s *MemoryEventStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.snapshots[snapshot.StreamID]; ok && current.Version >= snapshot.Version {
+		return nil
+	}
+	snapshot.CreatedAt = time.Now().UTC()
+	s.snapshots[snapshot.StreamID] = snapshot
+	return nil
+}
+
+// LoadSnapshot returns the stream's snapshot, or nil when there is none
+func (s *MemoryEventStore) LoadSnapshot(ctx context.Context, streamID string) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot, ok := s.snapshots[streamID]
+	if !ok {
+		return nil, nil
+	}
+	return &snapshot, nil
+}
+
+// StreamIDs lists the streams with events or a snapshot whose ID starts with prefix
+func (s *MemoryEventStore) StreamIDs(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for id := range s.streams {
+		seen[id] = true
+	}
+	for id := range s.snapshots {
+		seen[id] = true
+	}
+
+	var ids []string
+	for id := range seen {
+		if strings.HasPrefix(id, prefix) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+var (
+	_ EventStore = (*PostgresEventStore)(nil)
+	_ EventStore = (*MemoryEventStore)(nil)
+)
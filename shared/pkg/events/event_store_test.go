@@ -0,0 +1,159 @@
+package events_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"shared/pkg/database/databasetest"
+	"shared/pkg/events"
+)
+
+func newStoreEvents(t *testing.T, n int) []*events.Event {
+	t.Helper()
+
+	stream := make([]*events.Event, n)
+	for i := range stream {
+		event, err := events.NewEvent(events.UserQuotasResetEvent, "test", "1.0", events.UserQuotasResetData{UserID: "u1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream[i] = event
+	}
+	return stream
+}
+
+func TestMemoryEventStoreConcurrentAppendsConflict(t *testing.T) {
+	store := events.NewMemoryEventStore()
+	ctx := context.Background()
+	if _, err := store.Append(ctx, "user-u1", events.NoStream, newStoreEvents(t, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two writers loaded the stream at version 1 and append at once
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		appended := newStoreEvents(t, 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = store.Append(ctx, "user-u1", 1, appended)
+		}()
+	}
+	wg.Wait()
+
+	conflicts := 0
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, events.ErrVersionConflict):
+			conflicts++
+		case err != nil:
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	if conflicts != 1 {
+		t.Errorf("%d of two concurrent appends conflicted, want exactly one", conflicts)
+	}
+
+	_, version, err := store.Load(ctx, "user-u1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Errorf("stream at version %d, want 2", version)
+	}
+}
+
+func TestMemoryEventStoreLoadsAfterVersionAndKeepsNewerSnapshot(t *testing.T) {
+	store := events.NewMemoryEventStore()
+	ctx := context.Background()
+
+	stream := newStoreEvents(t, 3)
+	version, err := store.Append(ctx, "user-u1", events.NoStream, stream)
+	if err != nil || version != 3 {
+		t.Fatalf("Append() = %d, %v, want version 3", version, err)
+	}
+	if _, err := store.Append(ctx, "order-o1", events.AnyVersion, newStoreEvents(t, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, version, err := store.Load(ctx, "user-u1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 3 || len(loaded) != 2 || loaded[0].ID != stream[1].ID || loaded[1].ID != stream[2].ID {
+		t.Errorf("Load() after version 1 = %d events at version %d, want events 2 and 3", len(loaded), version)
+	}
+
+	for _, snapshot := range []events.Snapshot{
+		{StreamID: "user-u1", Version: 2, State: []byte(`"v2"`)},
+		{StreamID: "user-u1", Version: 1, State: []byte(`"v1"`)},
+	} {
+		if err := store.SaveSnapshot(ctx, snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot, err := store.LoadSnapshot(ctx, "user-u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot == nil || snapshot.Version != 2 || string(snapshot.State) != `"v2"` {
+		t.Errorf("LoadSnapshot() = %+v, want the snapshot at version 2", snapshot)
+	}
+
+	ids, err := store.StreamIDs(ctx, "user-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "user-u1" {
+		t.Errorf("StreamIDs(user-) = %v, want [user-u1]", ids)
+	}
+}
+
+func TestPostgresEventStoreAppendConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name            string
+		expectedVersion int64
+		inserted        int64
+		wantErr         bool
+		wantInsert      bool
+	}{
+		// The stream is at version 1 in every case
+		{name: "appends at the expected version", expectedVersion: 1, inserted: 2, wantInsert: true},
+		{name: "rejects a stale version", expectedVersion: 0, wantErr: true},
+		// A concurrent append took the versions between the read and the insert
+		{name: "rejects a concurrent append", expectedVersion: 1, inserted: 0, wantErr: true, wantInsert: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &databasetest.DB{
+				Columns:      []string{"coalesce"},
+				Rows:         [][]driver.Value{{int64(1)}},
+				RowsAffected: tt.inserted,
+			}
+			store := events.NewPostgresEventStore(db.Open())
+
+			version, err := store.Append(ctx, "user-u1", tt.expectedVersion, newStoreEvents(t, 2))
+			if tt.wantErr {
+				if !errors.Is(err, events.ErrVersionConflict) {
+					t.Errorf("Append() error = %v, want a version conflict", err)
+				}
+			} else if err != nil || version != 3 {
+				t.Errorf("Append() = %d, %v, want version 3", version, err)
+			}
+
+			execs := db.Executed()
+			inserted := len(execs) == 1 && strings.HasPrefix(execs[0], "INSERT INTO event_store")
+			if inserted != tt.wantInsert {
+				t.Errorf("executed %v, want an insert: %v", execs, tt.wantInsert)
+			}
+		})
+	}
+}
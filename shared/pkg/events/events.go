@@ -1,71 +1,286 @@
 package events
 
 import (
-    "encoding/json"
-    "time"
+	"encoding/json"
+	"time"
 
-    "github.com/google/uuid"
+	"github.com/google/uuid"
 )
 
 type Event struct {
-    ID        string    `json:"id"`
-    Type      string    `json:"type"`
-    Source    string    `json:"source"`
-    Version   string    `json:"version"`
-    Timestamp time.Time `json:"timestamp"`
-    Data      []byte    `json:"data"`
+	ID              string            `json:"id"`
+	Type            string            `json:"type"`
+	Source          string            `json:"source"`
+	Version         string            `json:"version"`
+	Subject         string            `json:"subject,omitempty"`
+	DataSchema      string            `json:"dataschema,omitempty"`
+	DataContentType string            `json:"datacontenttype,omitempty"` // media type of Data, JSON when empty
+	Timestamp       time.Time         `json:"timestamp"`
+	Data            json.RawMessage   `json:"data"`
+	Headers         map[string]string `json:"headers,omitempty"`
 }
 
 func NewEvent(eventType, source, version string, data interface{}) (*Event, error) {
-    dataBytes, err := json.Marshal(data)
-    if err != nil {
-        return nil, err
-    }
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
 
-    return &Event{
-        ID:        uuid.New().String(),
-        Type:      eventType,
-        Source:    source,
-        Version:   version,
-        Timestamp: time.Now().UTC(),
-        Data:      dataBytes,
-    }, nil
+	return &Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		Source:     source,
+		Version:    version,
+		DataSchema: DataSchemaURI(eventType, version),
+		Timestamp:  time.Now().UTC(),
+		Data:       dataBytes,
+		Headers:    make(map[string]string),
+	}, nil
 }
 
+// DataSchemaURI identifies the payload schema of an event type and version
+func DataSchemaURI(eventType, version string) string {
+	return "urn:smm-platform:schema:" + eventType + ":" + version
+}
+
+const (
+	UserRegisteredEvent   = "user.registered"
+	UserTierUpgradedEvent = "user.tier.upgraded"
+	UserQuotaUpdatedEvent = "user.quota.updated"
+
+	// Tier upgrade saga
+	UserQuotaProvisionedEvent        = "user.quota.provisioned"
+	UserQuotaProvisioningFailedEvent = "user.quota.provisioning.failed"
+	UserTierRevertedEvent            = "user.tier.reverted"
+
+	// User stream, recorded in the user service's event store
+	UserQuotaConsumedEvent     = "user.quota.consumed"
+	UserQuotaLimitChangedEvent = "user.quota.limit.changed"
+	UserTierChangedEvent       = "user.tier.changed"
+	UserQuotasResetEvent       = "user.quotas.reset"
+)
+
+// Product catalog, published by the product service on product-events
 const (
-    UserRegisteredEvent    = "user.registered"
-    UserTierUpgradedEvent  = "user.tier.upgraded"
-    UserQuotaUpdatedEvent  = "user.quota.updated"
+	ProductCreatedEvent = "product.created"
+	ProductUpdatedEvent = "product.updated"
+	ProductDeletedEvent = "product.deleted"
+)
+
+// Social media posts, published by the posting service on content-events
+const (
+	ContentDraftedEvent   = "content.drafted"
+	ContentScheduledEvent = "content.scheduled"
+	ContentPublishedEvent = "content.published"
+	ContentFailedEvent    = "content.failed"
+)
+
+// AI content generation on ai-events. The product service requests content for its
+// products and the AI content service replies with the request's ID.
+const (
+	AIDescriptionRequestedEvent = "ai.description.requested"
+	AIDescriptionCompletedEvent = "ai.description.completed"
+	AIDescriptionFailedEvent    = "ai.description.failed"
+	AIVideoRequestedEvent       = "ai.video.requested"
+	AIVideoCompletedEvent       = "ai.video.completed"
+)
+
+// Quota names of the user stream events
+const (
+	QuotaAIDescription = "ai_description"
+	QuotaAIVideo       = "ai_video"
+	QuotaAutoPosting   = "auto_posting"
 )
 
 type UserRegisteredData struct {
-    UserID    string `json:"user_id"`
-    Email     string `json:"email"`
-    FullName  string `json:"full_name"`
-    Tier      string `json:"tier"`
-    CreatedAt string `json:"created_at"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	FullName  string `json:"full_name"`
+	Tier      string `json:"tier"`
+	CreatedAt string `json:"created_at"`
 }
 
 type UserTierUpgradedData struct {
-    UserID    string `json:"user_id"`
-    OldTier   string `json:"old_tier"`
-    NewTier   string `json:"new_tier"`
-    UpgradedAt string `json:"upgraded_at"`
+	UserID     string `json:"user_id"`
+	OldTier    string `json:"old_tier"`
+	NewTier    string `json:"new_tier"`
+	UpgradedAt string `json:"upgraded_at"`
 }
 
 type QuotaData struct {
-    Used  int `json:"used"`
-    Limit int `json:"limit"`
+	Used  int `json:"used"`
+	Limit int `json:"limit"`
 }
 
 type QuotaInfoData struct {
-    AIDescription QuotaData `json:"ai_description"`
-    AIVideo       QuotaData `json:"ai_video"`
-    AutoPosting   QuotaData `json:"auto_posting"`
+	AIDescription QuotaData `json:"ai_description"`
+	AIVideo       QuotaData `json:"ai_video"`
+	AutoPosting   QuotaData `json:"auto_posting"`
 }
 
 type UserQuotaUpdatedData struct {
-    UserID    string       `json:"user_id"`
-    Quotas    QuotaInfoData `json:"quotas"`
-    UpdatedAt string       `json:"updated_at"`
+	UserID    string        `json:"user_id"`
+	Quotas    QuotaInfoData `json:"quotas"`
+	UpdatedAt string        `json:"updated_at"`
+}
+
+type UserQuotaProvisionedData struct {
+	UserID        string `json:"user_id"`
+	Tier          string `json:"tier"`
+	ProvisionedAt string `json:"provisioned_at"`
+}
+
+type UserQuotaProvisioningFailedData struct {
+	UserID   string `json:"user_id"`
+	Reason   string `json:"reason"`
+	FailedAt string `json:"failed_at"`
+}
+
+type UserTierRevertedData struct {
+	UserID     string `json:"user_id"`
+	FromTier   string `json:"from_tier"`
+	ToTier     string `json:"to_tier"`
+	Reason     string `json:"reason"`
+	RevertedAt string `json:"reverted_at"`
+}
+
+type UserQuotaConsumedData struct {
+	UserID     string `json:"user_id"`
+	Quota      string `json:"quota"`
+	Used       int    `json:"used"`
+	ConsumedAt string `json:"consumed_at"`
+}
+
+type UserQuotaLimitChangedData struct {
+	UserID    string `json:"user_id"`
+	Quota     string `json:"quota"`
+	OldLimit  int    `json:"old_limit"`
+	NewLimit  int    `json:"new_limit"`
+	Reason    string `json:"reason"`
+	ChangedAt string `json:"changed_at"`
+}
+
+type UserTierChangedData struct {
+	UserID    string `json:"user_id"`
+	OldTier   string `json:"old_tier"`
+	NewTier   string `json:"new_tier"`
+	ChangedAt string `json:"changed_at"`
+}
+
+type UserQuotasResetData struct {
+	UserID  string `json:"user_id"`
+	ResetAt string `json:"reset_at"`
+}
+
+// ProductCreatedData is a new product. Prices are in the minor unit of the ISO 4217
+// currency, e.g. cents.
+type ProductCreatedData struct {
+	ProductID   string   `json:"product_id"`
+	UserID      string   `json:"user_id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	PriceCents  int64    `json:"price_cents"`
+	Currency    string   `json:"currency"`
+	ImageURLs   []string `json:"image_urls,omitempty"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// ProductUpdatedData is the whole product after an update
+type ProductUpdatedData struct {
+	ProductID   string   `json:"product_id"`
+	UserID      string   `json:"user_id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	PriceCents  int64    `json:"price_cents"`
+	Currency    string   `json:"currency"`
+	ImageURLs   []string `json:"image_urls,omitempty"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+type ProductDeletedData struct {
+	ProductID string `json:"product_id"`
+	UserID    string `json:"user_id"`
+	DeletedAt string `json:"deleted_at"`
+}
+
+// ContentDraftedData is a post written for one platform, e.g. instagram, optionally
+// promoting a product
+type ContentDraftedData struct {
+	ContentID string   `json:"content_id"`
+	UserID    string   `json:"user_id"`
+	ProductID string   `json:"product_id,omitempty"`
+	Platform  string   `json:"platform"`
+	Text      string   `json:"text"`
+	MediaURLs []string `json:"media_urls,omitempty"`
+	DraftedAt string   `json:"drafted_at"`
+}
+
+type ContentScheduledData struct {
+	ContentID   string `json:"content_id"`
+	UserID      string `json:"user_id"`
+	Platform    string `json:"platform"`
+	PublishAt   string `json:"publish_at"`
+	ScheduledAt string `json:"scheduled_at"`
+}
+
+// ContentPublishedData identifies the post on the platform it was published to
+type ContentPublishedData struct {
+	ContentID   string `json:"content_id"`
+	UserID      string `json:"user_id"`
+	Platform    string `json:"platform"`
+	PostID      string `json:"post_id"`
+	PostURL     string `json:"post_url"`
+	PublishedAt string `json:"published_at"`
+}
+
+type ContentFailedData struct {
+	ContentID string `json:"content_id"`
+	UserID    string `json:"user_id"`
+	Platform  string `json:"platform"`
+	Reason    string `json:"reason"`
+	FailedAt  string `json:"failed_at"`
+}
+
+// AIDescriptionRequestedData asks for a product description in a language, e.g. en, and
+// an optional tone of voice
+type AIDescriptionRequestedData struct {
+	RequestID   string `json:"request_id"`
+	UserID      string `json:"user_id"`
+	ProductID   string `json:"product_id"`
+	Language    string `json:"language"`
+	Tone        string `json:"tone,omitempty"`
+	RequestedAt string `json:"requested_at"`
+}
+
+type AIDescriptionCompletedData struct {
+	RequestID   string `json:"request_id"`
+	UserID      string `json:"user_id"`
+	ProductID   string `json:"product_id"`
+	Description string `json:"description"`
+	CompletedAt string `json:"completed_at"`
+}
+
+type AIDescriptionFailedData struct {
+	RequestID string `json:"request_id"`
+	UserID    string `json:"user_id"`
+	ProductID string `json:"product_id"`
+	Reason    string `json:"reason"`
+	FailedAt  string `json:"failed_at"`
+}
+
+type AIVideoRequestedData struct {
+	RequestID       string `json:"request_id"`
+	UserID          string `json:"user_id"`
+	ProductID       string `json:"product_id"`
+	DurationSeconds int    `json:"duration_seconds"`
+	RequestedAt     string `json:"requested_at"`
+}
+
+type AIVideoCompletedData struct {
+	RequestID       string `json:"request_id"`
+	UserID          string `json:"user_id"`
+	ProductID       string `json:"product_id"`
+	VideoURL        string `json:"video_url"`
+	DurationSeconds int    `json:"duration_seconds"`
+	CompletedAt     string `json:"completed_at"`
 }
@@ -0,0 +1,395 @@
+package eventstest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"shared/pkg/events"
+)
+
+// Pact is the set of messages a consumer expects from a provider, in the spirit of Pact
+// message contracts. The consumer declares each message by an example payload and runs its
+// handler against it, then writes the pact to a directory both services can reach. The
+// provider loads the pacts naming it and verifies that the events it actually publishes
+// match them. Neither side needs a broker.
+//
+// Payloads match by type: every field of the example must be present in the published
+// payload with the same JSON type, arrays must hold elements like the example's first one,
+// and fields the consumer did not declare are ignored. MatchRegex tightens a field to a
+// pattern.
+type Pact struct {
+	Consumer string             `json:"consumer"`
+	Provider string             `json:"provider"`
+	Messages []*MessageContract `json:"messages"`
+}
+
+// MessageContract is one message of a pact
+type MessageContract struct {
+	Description string            `json:"description"`
+	Topic       string            `json:"topic"`
+	EventType   string            `json:"event_type"`
+	Version     string            `json:"version"`
+	Example     json.RawMessage   `json:"example"`
+	Regex       map[string]string `json:"regex,omitempty"`
+}
+
+// Producer publishes the message a contract describes, the way the provider's code does in
+// production, e.g. by calling a service with a publisher built on bus
+type Producer func(ctx context.Context, bus events.EventBus) error
+
+// NewPact starts the pact of consumer with provider
+func NewPact(consumer, provider string) *Pact {
+	return &Pact{Consumer: consumer, Provider: provider}
+}
+
+// Expect declares a message of version 1.0 by an example payload. The description names
+// the message and is what the provider maps to a Producer.
+func (p *Pact) Expect(description, topic, eventType string, example interface{}) *MessageContract {
+	payload, err := json.Marshal(example)
+	if err != nil {
+		panic(fmt.Sprintf("eventstest: failed to marshal example of %q: %v", description, err))
+	}
+
+	message := &MessageContract{
+		Description: description,
+		Topic:       topic,
+		EventType:   eventType,
+		Version:     "1.0",
+		Example:     payload,
+	}
+	p.Messages = append(p.Messages, message)
+	return message
+}
+
+// WithVersion sets the payload version the consumer expects
+func (m *MessageContract) WithVersion(version string) *MessageContract {
+	m.Version = version
+	return m
+}
+
+// MatchRegex requires the string at a dotted path of the payload, e.g. user_id or
+// quotas.ai_video, to match pattern
+func (m *MessageContract) MatchRegex(path, pattern string) *MessageContract {
+	if _, err := regexp.Compile(pattern); err != nil {
+		panic(fmt.Sprintf("eventstest: invalid pattern for %s: %v", path, err))
+	}
+	if m.Regex == nil {
+		m.Regex = make(map[string]string)
+	}
+	m.Regex[path] = pattern
+	return m
+}
+
+// VerifyConsumer runs handler on an event built from each example, failing the test for
+// messages the consumer cannot handle or whose example breaks its own regex rules
+func (p *Pact) VerifyConsumer(t testing.TB, handler events.EventHandler) {
+	t.Helper()
+
+	for _, message := range p.Messages {
+		var example interface{}
+		if err := json.Unmarshal(message.Example, &example); err != nil {
+			t.Errorf("%s: invalid example: %v", message.Description, err)
+			continue
+		}
+		if mismatches := message.match(example, example); len(mismatches) > 0 {
+			t.Errorf("%s: example does not match its rules:\n  %s", message.Description, strings.Join(mismatches, "\n  "))
+			continue
+		}
+
+		event, err := events.NewEvent(message.EventType, p.Provider, message.Version, example)
+		if err != nil {
+			t.Errorf("%s: failed to create event: %v", message.Description, err)
+			continue
+		}
+
+		if err := handler(context.Background(), event); err != nil {
+			t.Errorf("%s: consumer failed to handle the example: %v", message.Description, err)
+		}
+	}
+}
+
+// Write stores the pact as <consumer>-<provider>.json in dir
+func (p *Pact) Write(t testing.TB, dir string) {
+	t.Helper()
+
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to marshal pact: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create %s: %v", dir, err)
+	}
+
+	path := filepath.Join(dir, p.Consumer+"-"+p.Provider+".json")
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		t.Fatalf("failed to write pact: %v", err)
+	}
+}
+
+// LoadPacts reads the pacts in dir that name provider
+func LoadPacts(t testing.TB, dir, provider string) []*Pact {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatalf("failed to list pacts: %v", err)
+	}
+	sort.Strings(paths)
+
+	var pacts []*Pact
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read pact: %v", err)
+		}
+
+		var pact Pact
+		if err := json.Unmarshal(b, &pact); err != nil {
+			t.Fatalf("failed to parse pact %s: %v", path, err)
+		}
+		if pact.Provider == provider {
+			pacts = append(pacts, &pact)
+		}
+	}
+	return pacts
+}
+
+// VerifyProvider runs the producer of every message of the pacts and checks that it
+// publishes a matching event. Messages without a producer fail, so a provider cannot
+// silently drop a message a consumer relies on.
+func VerifyProvider(t *testing.T, pacts []*Pact, producers map[string]Producer) {
+	t.Helper()
+
+	for _, pact := range pacts {
+		for _, message := range pact.Messages {
+			t.Run(pact.Consumer+"/"+message.Description, func(t *testing.T) {
+				produce, ok := producers[message.Description]
+				if !ok {
+					t.Fatalf("no producer for %q expected by %s", message.Description, pact.Consumer)
+				}
+
+				recorder := NewRecorder()
+				if err := produce(context.Background(), recorder); err != nil {
+					t.Fatalf("producer failed: %v", err)
+				}
+
+				if err := message.Verify(recorder.Events(message.Topic)); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
+
+// Verify reports whether one of published satisfies the contract
+func (m *MessageContract) Verify(published []*events.Event) error {
+	var example interface{}
+	if err := json.Unmarshal(m.Example, &example); err != nil {
+		return fmt.Errorf("%s: invalid example: %w", m.Description, err)
+	}
+
+	var mismatches []string
+	found := false
+	for _, event := range published {
+		if event.Type != m.EventType {
+			continue
+		}
+		found = true
+
+		if event.Version != m.Version {
+			mismatches = append(mismatches, fmt.Sprintf("version: expected %s, got %s", m.Version, event.Version))
+			continue
+		}
+
+		var actual interface{}
+		if err := json.Unmarshal(event.Data, &actual); err != nil {
+			return fmt.Errorf("%s: invalid payload: %w", m.Description, err)
+		}
+		mismatches = m.match(example, actual)
+		if len(mismatches) == 0 {
+			return nil
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("%s: no %s event published to %s", m.Description, m.EventType, m.Topic)
+	}
+	return fmt.Errorf("%s: published %s does not match the contract:\n  %s", m.Description, m.EventType, strings.Join(mismatches, "\n  "))
+}
+
+// match compares a payload with the example and the regex rules
+func (m *MessageContract) match(example, actual interface{}) []string {
+	mismatches := matchType("", example, actual)
+
+	paths := make([]string, 0, len(m.Regex))
+	for path := range m.Regex {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		value, ok := lookup(actual, strings.Split(path, "."))
+		s, isString := value.(string)
+		if !ok || !isString {
+			mismatches = append(mismatches, fmt.Sprintf("%s: expected a string matching %s", path, m.Regex[path]))
+			continue
+		}
+		if !regexp.MustCompile(m.Regex[path]).MatchString(s) {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %q does not match %s", path, s, m.Regex[path]))
+		}
+	}
+	return mismatches
+}
+
+// matchType checks that actual has the shape of expected
+func matchType(path string, expected, actual interface{}) []string {
+	switch expected := expected.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		object, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %s", displayPath(path), jsonType(actual))}
+		}
+
+		keys := make([]string, 0, len(expected))
+		for key := range expected {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var mismatches []string
+		for _, key := range keys {
+			value, ok := object[key]
+			if !ok {
+				mismatches = append(mismatches, fmt.Sprintf("%s: missing", joinPath(path, key)))
+				continue
+			}
+			mismatches = append(mismatches, matchType(joinPath(path, key), expected[key], value)...)
+		}
+		return mismatches
+	case []interface{}:
+		array, ok := actual.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array, got %s", displayPath(path), jsonType(actual))}
+		}
+		if len(expected) == 0 {
+			return nil
+		}
+
+		var mismatches []string
+		for i, element := range array {
+			mismatches = append(mismatches, matchType(joinPath(path, fmt.Sprint(i)), expected[0], element)...)
+		}
+		return mismatches
+	default:
+		if jsonType(expected) != jsonType(actual) {
+			return []string{fmt.Sprintf("%s: expected a %s, got %s", displayPath(path), jsonType(expected), jsonType(actual))}
+		}
+		return nil
+	}
+}
+
+// lookup follows a dotted path through objects and, for numeric segments, arrays
+func lookup(doc interface{}, path []string) (interface{}, bool) {
+	for _, segment := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			doc = value
+		case []interface{}:
+			var i int
+			if _, err := fmt.Sscan(segment, &i); err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "payload"
+	}
+	return path
+}
+
+// Recorder is an EventBus that keeps what is published instead of delivering it
+type Recorder struct {
+	mu     sync.Mutex
+	events map[string][]*events.Event
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{events: make(map[string][]*events.Event)}
+}
+
+// Publish records the event under its topic
+func (r *Recorder) Publish(ctx context.Context, topic string, event *events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events[topic] = append(r.events[topic], event)
+	return nil
+}
+
+// Subscribe is a no-op; nothing is delivered
+func (r *Recorder) Subscribe(ctx context.Context, topic string, handler events.EventHandler, opts ...events.SubscribeOption) error {
+	return nil
+}
+
+// Close is a no-op
+func (r *Recorder) Close() error {
+	return nil
+}
+
+// Events returns the events published to topic in order
+func (r *Recorder) Events(topic string) []*events.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*events.Event(nil), r.events[topic]...)
+}
+
+var _ events.EventBus = (*Recorder)(nil)
@@ -0,0 +1,162 @@
+package eventstest
+
+import (
+	"sort"
+	"testing"
+
+	"shared/pkg/events"
+)
+
+// IDs used throughout the fixtures, so fixtures of related events refer to each other
+const (
+	FixtureUserID    = "4f1c2a7e-8a9b-4c3d-9e0f-1a2b3c4d5e6f"
+	FixtureProductID = "7b8c9d0e-1f2a-4b3c-8d4e-5f6a7b8c9d0e"
+	FixtureContentID = "a1b2c3d4-e5f6-4a7b-8c9d-0e1f2a3b4c5d"
+	FixtureRequestID = "c9d8e7f6-a5b4-4c3d-9e2f-1a0b9c8d7e6f"
+)
+
+const fixtureTime = "2024-01-15T10:30:00Z"
+
+// Fixture is an example of a catalog event: a version 1.0 payload with the topic and
+// source it is published with. Consumers can feed fixtures to their handlers and to
+// Pact.Expect instead of writing their own examples.
+type Fixture struct {
+	Topic  string
+	Source string
+	Data   interface{}
+}
+
+var fixtures = map[string]Fixture{
+	events.UserRegisteredEvent: {"user-events", "auth-service", events.UserRegisteredData{
+		UserID: FixtureUserID, Email: "jane@example.com", FullName: "Jane Doe", Tier: "free", CreatedAt: fixtureTime,
+	}},
+	events.UserTierUpgradedEvent: {"user-events", "auth-service", events.UserTierUpgradedData{
+		UserID: FixtureUserID, OldTier: "free", NewTier: "pro", UpgradedAt: fixtureTime,
+	}},
+	events.UserQuotaUpdatedEvent: {"user-events", "user-service", events.UserQuotaUpdatedData{
+		UserID: FixtureUserID,
+		Quotas: events.QuotaInfoData{
+			AIDescription: events.QuotaData{Used: 1, Limit: 5},
+			AIVideo:       events.QuotaData{Used: 0, Limit: 0},
+			AutoPosting:   events.QuotaData{Used: 2, Limit: 5},
+		},
+		UpdatedAt: fixtureTime,
+	}},
+	events.UserQuotaProvisionedEvent: {"user-events", "user-service", events.UserQuotaProvisionedData{
+		UserID: FixtureUserID, Tier: "pro", ProvisionedAt: fixtureTime,
+	}},
+	events.UserQuotaProvisioningFailedEvent: {"user-events", "user-service", events.UserQuotaProvisioningFailedData{
+		UserID: FixtureUserID, Reason: "user not found", FailedAt: fixtureTime,
+	}},
+	events.UserTierRevertedEvent: {"user-events", "auth-service", events.UserTierRevertedData{
+		UserID: FixtureUserID, FromTier: "pro", ToTier: "free", Reason: "quota provisioning timed out", RevertedAt: fixtureTime,
+	}},
+	events.UserQuotaConsumedEvent: {"user-events", "user-service", events.UserQuotaConsumedData{
+		UserID: FixtureUserID, Quota: events.QuotaAIDescription, Used: 1, ConsumedAt: fixtureTime,
+	}},
+	events.UserQuotaLimitChangedEvent: {"user-events", "user-service", events.UserQuotaLimitChangedData{
+		UserID: FixtureUserID, Quota: events.QuotaAIVideo, OldLimit: 0, NewLimit: 10, Reason: "tier changed to pro", ChangedAt: fixtureTime,
+	}},
+	events.UserTierChangedEvent: {"user-events", "user-service", events.UserTierChangedData{
+		UserID: FixtureUserID, OldTier: "free", NewTier: "pro", ChangedAt: fixtureTime,
+	}},
+	events.UserQuotasResetEvent: {"user-events", "user-service", events.UserQuotasResetData{
+		UserID: FixtureUserID, ResetAt: fixtureTime,
+	}},
+
+	events.ProductCreatedEvent: {"product-events", "product-service", events.ProductCreatedData{
+		ProductID: FixtureProductID, UserID: FixtureUserID, Name: "Linen Tote Bag", Description: "Hand-stitched tote in natural linen",
+		PriceCents: 2499, Currency: "USD", ImageURLs: []string{"https://cdn.example.com/products/tote.jpg"}, CreatedAt: fixtureTime,
+	}},
+	events.ProductUpdatedEvent: {"product-events", "product-service", events.ProductUpdatedData{
+		ProductID: FixtureProductID, UserID: FixtureUserID, Name: "Linen Tote Bag", Description: "Hand-stitched tote in natural linen",
+		PriceCents: 1999, Currency: "USD", ImageURLs: []string{"https://cdn.example.com/products/tote.jpg"}, UpdatedAt: fixtureTime,
+	}},
+	events.ProductDeletedEvent: {"product-events", "product-service", events.ProductDeletedData{
+		ProductID: FixtureProductID, UserID: FixtureUserID, DeletedAt: fixtureTime,
+	}},
+
+	events.ContentDraftedEvent: {"content-events", "posting-service", events.ContentDraftedData{
+		ContentID: FixtureContentID, UserID: FixtureUserID, ProductID: FixtureProductID, Platform: "instagram",
+		Text: "Meet our new linen tote", MediaURLs: []string{"https://cdn.example.com/products/tote.jpg"}, DraftedAt: fixtureTime,
+	}},
+	events.ContentScheduledEvent: {"content-events", "posting-service", events.ContentScheduledData{
+		ContentID: FixtureContentID, UserID: FixtureUserID, Platform: "instagram", PublishAt: "2024-01-16T09:00:00Z", ScheduledAt: fixtureTime,
+	}},
+	events.ContentPublishedEvent: {"content-events", "posting-service", events.ContentPublishedData{
+		ContentID: FixtureContentID, UserID: FixtureUserID, Platform: "instagram", PostID: "17895695668004550",
+		PostURL: "https://www.instagram.com/p/C2abcDEFghi/", PublishedAt: "2024-01-16T09:00:00Z",
+	}},
+	events.ContentFailedEvent: {"content-events", "posting-service", events.ContentFailedData{
+		ContentID: FixtureContentID, UserID: FixtureUserID, Platform: "instagram", Reason: "access token expired", FailedAt: "2024-01-16T09:00:00Z",
+	}},
+
+	events.AIDescriptionRequestedEvent: {"ai-events", "product-service", events.AIDescriptionRequestedData{
+		RequestID: FixtureRequestID, UserID: FixtureUserID, ProductID: FixtureProductID, Language: "en", Tone: "friendly", RequestedAt: fixtureTime,
+	}},
+	events.AIDescriptionCompletedEvent: {"ai-events", "ai-content-service", events.AIDescriptionCompletedData{
+		RequestID: FixtureRequestID, UserID: FixtureUserID, ProductID: FixtureProductID,
+		Description: "Carry your day in style with this hand-stitched linen tote.", CompletedAt: fixtureTime,
+	}},
+	events.AIDescriptionFailedEvent: {"ai-events", "ai-content-service", events.AIDescriptionFailedData{
+		RequestID: FixtureRequestID, UserID: FixtureUserID, ProductID: FixtureProductID, Reason: "model unavailable", FailedAt: fixtureTime,
+	}},
+	events.AIVideoRequestedEvent: {"ai-events", "product-service", events.AIVideoRequestedData{
+		RequestID: FixtureRequestID, UserID: FixtureUserID, ProductID: FixtureProductID, DurationSeconds: 15, RequestedAt: fixtureTime,
+	}},
+	events.AIVideoCompletedEvent: {"ai-events", "ai-content-service", events.AIVideoCompletedData{
+		RequestID: FixtureRequestID, UserID: FixtureUserID, ProductID: FixtureProductID,
+		VideoURL: "https://cdn.example.com/videos/tote.mp4", DurationSeconds: 15, CompletedAt: fixtureTime,
+	}},
+}
+
+// LookupFixture returns the fixture of an event type
+func LookupFixture(eventType string) (Fixture, bool) {
+	fixture, ok := fixtures[eventType]
+	return fixture, ok
+}
+
+// FixtureTypes lists the event types that have a fixture
+func FixtureTypes() []string {
+	types := make([]string, 0, len(fixtures))
+	for eventType := range fixtures {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewFixtureEvent creates an event from the fixture of eventType, failing the test when
+// there is none
+func NewFixtureEvent(t testing.TB, eventType string) *events.Event {
+	t.Helper()
+
+	fixture, ok := fixtures[eventType]
+	if !ok {
+		t.Fatalf("no fixture for %s", eventType)
+	}
+
+	event, err := events.NewEvent(eventType, fixture.Source, "1.0", fixture.Data)
+	if err != nil {
+		t.Fatalf("failed to create %s event: %v", eventType, err)
+	}
+	return event
+}
+
+// AssertFixturesValid fails the test when an event type of the registry has no fixture or
+// a fixture does not match its schema
+func AssertFixturesValid(t testing.TB, registry *events.Registry) {
+	t.Helper()
+
+	for _, entry := range registry.Entries() {
+		if _, ok := fixtures[entry.Type]; !ok {
+			t.Errorf("%s: no fixture", entry.Type)
+		}
+	}
+
+	for _, eventType := range FixtureTypes() {
+		if err := registry.Validate(NewFixtureEvent(t, eventType)); err != nil {
+			t.Errorf("%s: fixture does not match its schema: %v", eventType, err)
+		}
+	}
+}
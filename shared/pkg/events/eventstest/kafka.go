@@ -0,0 +1,210 @@
+package eventstest
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"shared/pkg/events"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka is an in-process stand-in for a Kafka cluster. Every topic has a single
+// partition and consumer groups track their committed offsets per topic. Transactions
+// append their messages and commit their offset at once.
+// Plug it into a bus with events.NewKafkaEventBus(nil, k.Options()...).
+type Kafka struct {
+	mu        sync.Mutex
+	topics    map[string][]kafka.Message
+	committed map[string]map[string]int64
+	changed   chan struct{}
+}
+
+// NewKafka creates an empty in-process Kafka
+func NewKafka() *Kafka {
+	return &Kafka{
+		topics:    make(map[string][]kafka.Message),
+		committed: make(map[string]map[string]int64),
+		changed:   make(chan struct{}),
+	}
+}
+
+// Options wires a KafkaEventBus to this stand-in
+func (k *Kafka) Options() []events.KafkaOption {
+	return []events.KafkaOption{
+		events.WithMessageWriter(k),
+		events.WithTransactionWriter(k),
+		events.WithReaderFactory(k.NewReader),
+		events.WithTopicLister(k),
+	}
+}
+
+// WriteMessages appends msgs to their topics
+func (k *Kafka) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.append(msgs)
+	return nil
+}
+
+// WriteTransaction appends msgs and commits the offset after consumed for group at once; it
+// is an events.TransactionWriter
+func (k *Kafka) WriteTransaction(ctx context.Context, group string, consumed kafka.Message, msgs ...kafka.Message) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.append(msgs)
+	k.commit(group, consumed)
+	return nil
+}
+
+// append adds msgs to their topics and wakes up readers; callers hold k.mu
+func (k *Kafka) append(msgs []kafka.Message) {
+	for _, msg := range msgs {
+		msg.Partition = 0
+		msg.Offset = int64(len(k.topics[msg.Topic]))
+		msg.Time = time.Now()
+		k.topics[msg.Topic] = append(k.topics[msg.Topic], msg)
+	}
+
+	close(k.changed)
+	k.changed = make(chan struct{})
+}
+
+// commit records the offset after msg for group; callers hold k.mu
+func (k *Kafka) commit(group string, msg kafka.Message) {
+	if k.committed[group] == nil {
+		k.committed[group] = make(map[string]int64)
+	}
+	if next := msg.Offset + 1; next > k.committed[group][msg.Topic] {
+		k.committed[group][msg.Topic] = next
+	}
+}
+
+// Close is a no-op so the stand-in outlives the buses using it
+func (k *Kafka) Close() error {
+	return nil
+}
+
+// Messages returns a copy of the messages written to topic
+func (k *Kafka) Messages(topic string) []kafka.Message {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return append([]kafka.Message(nil), k.topics[topic]...)
+}
+
+// ListTopics returns the names of all topics written to so far; it is an events.TopicLister
+func (k *Kafka) ListTopics(ctx context.Context) ([]string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	topics := make([]string, 0, len(k.topics))
+	for topic := range k.topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+// Committed returns the next offset group will read from topic, or -1 when it committed nothing
+func (k *Kafka) Committed(group, topic string) int64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	offset, ok := k.committed[group][topic]
+	if !ok {
+		return -1
+	}
+	return offset
+}
+
+// NewReader creates a reader that resumes from the committed offsets of its group; it is
+// an events.ReaderFactory. Readers of several GroupTopics take turns between their topics.
+func (k *Kafka) NewReader(config kafka.ReaderConfig) events.MessageReader {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	topics := config.GroupTopics
+	if config.Topic != "" {
+		topics = []string{config.Topic}
+	}
+
+	offsets := make(map[string]int64, len(topics))
+	for _, topic := range topics {
+		offset, ok := k.committed[config.GroupID][topic]
+		if !ok {
+			offset = 0
+			if config.StartOffset == events.StartOffsetLatest {
+				offset = int64(len(k.topics[topic]))
+			}
+		}
+		offsets[topic] = offset
+	}
+
+	return &kafkaReader{
+		kafka:   k,
+		group:   config.GroupID,
+		topics:  topics,
+		offsets: offsets,
+		closed:  make(chan struct{}),
+	}
+}
+
+type kafkaReader struct {
+	kafka   *Kafka
+	group   string
+	topics  []string
+	offsets map[string]int64
+	next    int
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// FetchMessage blocks until the next message is available, ctx is done or the reader is closed
+func (r *kafkaReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		r.kafka.mu.Lock()
+		changed := r.kafka.changed
+		for i := range r.topics {
+			topic := r.topics[(r.next+i)%len(r.topics)]
+			messages := r.kafka.topics[topic]
+			if offset := r.offsets[topic]; offset < int64(len(messages)) {
+				r.offsets[topic]++
+				r.next = (r.next + i + 1) % len(r.topics)
+				r.kafka.mu.Unlock()
+				return messages[offset], nil
+			}
+		}
+		r.kafka.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-r.closed:
+			return kafka.Message{}, io.EOF
+		case <-changed:
+		}
+	}
+}
+
+// CommitMessages records the offset after the latest of msgs for the reader's group
+func (r *kafkaReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.kafka.mu.Lock()
+	defer r.kafka.mu.Unlock()
+
+	for _, msg := range msgs {
+		r.kafka.commit(r.group, msg)
+	}
+	return nil
+}
+
+func (r *kafkaReader) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+	return nil
+}
@@ -0,0 +1,53 @@
+// Package eventstest provides test helpers for code built on shared/pkg/events
+package eventstest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"shared/pkg/events"
+)
+
+// AssertSchemasUnchanged fails the test when a registered payload struct no longer
+// matches the schema committed for its version. Changing a payload means adding a
+// new version, its schema file and an upcaster, never editing an existing version.
+func AssertSchemasUnchanged(t testing.TB, registry *events.Registry) {
+	t.Helper()
+
+	for _, entry := range registry.Entries() {
+		if entry.Payload == nil {
+			continue
+		}
+
+		generated := events.GenerateSchema(entry.Type, entry.Version, entry.Payload)
+
+		var committed interface{}
+		if err := json.Unmarshal(entry.Schema, &committed); err != nil {
+			t.Errorf("%s %s: invalid committed schema: %v", entry.Type, entry.Version, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(normalize(t, generated), committed) {
+			expected, _ := json.MarshalIndent(generated, "", "  ")
+			t.Errorf("%s %s: payload %s changed without a version bump; register it as a new version "+
+				"with an upcaster and commit its schema:\n%s", entry.Type, entry.Version, entry.Payload, expected)
+		}
+	}
+}
+
+// normalize round-trips v through JSON so it compares equal to a decoded schema file
+func normalize(t testing.TB, v interface{}) interface{} {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal schema: %v", err)
+	}
+
+	var normalized interface{}
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		t.Fatalf("failed to unmarshal schema: %v", err)
+	}
+	return normalized
+}
@@ -0,0 +1,589 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"shared/pkg/tracing"
+)
+
+// Defaults of the FileEventBus
+const (
+	DefaultFileSegmentBytes = 16 << 20
+	DefaultFilePollInterval = 100 * time.Millisecond
+)
+
+// FileEventBus implements EventBus on an append-only, segmented log in a local directory.
+// Each topic is a single partition and consumer groups commit their offsets to files next
+// to the log, so services on one machine can exchange events durably without Kafka.
+// Several processes may share the directory; at most one consumer per group and topic
+// reads at a time, the others wait for its lock like idle members of a consumer group.
+type FileEventBus struct {
+	dir          string
+	segmentBytes int64
+	pollInterval time.Duration
+	sync         bool
+	groupID      string
+	middleware   []EventMiddleware
+
+	logs    map[string]*topicLog
+	offsets *offsetStore
+
+	state        busState
+	pending      []subscription
+	stopFetching []context.CancelFunc
+	consumers    sync.WaitGroup
+	mu           sync.RWMutex
+}
+
+// FileOption configures a FileEventBus
+type FileOption func(*FileEventBus)
+
+// WithSegmentBytes sets the size after which a new segment file is started
+func WithSegmentBytes(n int64) FileOption {
+	return func(f *FileEventBus) {
+		f.segmentBytes = n
+	}
+}
+
+// WithPollInterval sets how often caught-up consumers look for new records
+func WithPollInterval(d time.Duration) FileOption {
+	return func(f *FileEventBus) {
+		f.pollInterval = d
+	}
+}
+
+// WithFileSync enables or disables fsync after every append; it is enabled by default
+func WithFileSync(enabled bool) FileOption {
+	return func(f *FileEventBus) {
+		f.sync = enabled
+	}
+}
+
+// WithFileConsumerGroup sets the default consumer group for subscriptions, usually the service name
+func WithFileConsumerGroup(groupID string) FileOption {
+	return func(f *FileEventBus) {
+		f.groupID = groupID
+	}
+}
+
+// NewFileEventBus creates a file-backed event bus storing its log under dir
+func NewFileEventBus(dir string, opts ...FileOption) (*FileEventBus, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create event log directory: %w", err)
+	}
+
+	bus := &FileEventBus{
+		dir:          dir,
+		segmentBytes: DefaultFileSegmentBytes,
+		pollInterval: DefaultFilePollInterval,
+		sync:         true,
+		groupID:      DefaultConsumerGroup,
+		logs:         make(map[string]*topicLog),
+		offsets:      newOffsetStore(dir),
+	}
+
+	for _, opt := range opts {
+		opt(bus)
+	}
+
+	return bus, nil
+}
+
+// topicLog returns the log of topic, creating it on first use
+func (f *FileEventBus) topicLog(topic string) (*topicLog, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if l, exists := f.logs[topic]; exists {
+		return l, nil
+	}
+
+	l, err := newTopicLog(f.dir, topic, f.segmentBytes, f.sync)
+	if err != nil {
+		return nil, err
+	}
+	f.logs[topic] = l
+	return l, nil
+}
+
+// Publish appends the event to the topic's log, keyed by its aggregate ID
+func (f *FileEventBus) Publish(ctx context.Context, topic string, event *Event) error {
+	f.mu.RLock()
+	closed := f.state == busClosed
+	f.mu.RUnlock()
+	if closed {
+		return ErrBusClosed
+	}
+
+	ctx, span := tracing.StartProducerSpan(ctx, "file", topic)
+	defer span.End()
+
+	injectMetadata(ctx, event)
+
+	l, err := f.topicLog(topic)
+	if err != nil {
+		return err
+	}
+
+	if _, err := l.append([]string{partitionKey(event)}, []*Event{event}); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	log.Printf("Event published: %s to topic: %s", event.Type, topic)
+	return nil
+}
+
+// Use appends middleware applied to every handler invocation, after built-in panic recovery
+func (f *FileEventBus) Use(middleware ...EventMiddleware) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.middleware = append(f.middleware, middleware...)
+}
+
+func (f *FileEventBus) wrap(handler EventHandler) EventHandler {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return Chain(Chain(handler, f.middleware...), Recover())
+}
+
+// Subscribe registers a consumer for topic, which starts reading once the bus is started.
+// topic may be a pattern, see MemoryEventBus.Subscribe; it covers the topics that exist
+// when the consumer starts.
+func (f *FileEventBus) Subscribe(ctx context.Context, topic string, handler EventHandler, opts ...SubscribeOption) error {
+	sub := subscription{
+		ctx:     ctx,
+		topic:   topic,
+		handler: handler,
+		config:  newSubscribeConfig(opts...),
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch f.state {
+	case busDraining, busClosed:
+		return ErrBusClosed
+	case busRunning:
+		f.startConsumers(sub)
+	default:
+		f.pending = append(f.pending, sub)
+	}
+	return nil
+}
+
+// Start starts the consumers of all subscriptions registered so far
+func (f *FileEventBus) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch f.state {
+	case busDraining, busClosed:
+		return ErrBusClosed
+	case busRunning:
+		return nil
+	}
+
+	f.state = busRunning
+	for _, sub := range f.pending {
+		f.startConsumers(sub)
+	}
+	f.pending = nil
+	return nil
+}
+
+// startConsumers starts one consumer per topic of a subscription; callers hold f.mu
+func (f *FileEventBus) startConsumers(sub subscription) {
+	topics := []string{sub.topic}
+	if IsTopicPattern(sub.topic) {
+		all, err := f.listTopics()
+		if err != nil {
+			log.Printf("Failed to list topics for pattern %s: %v", sub.topic, err)
+		}
+		topics = patternTopics(sub.topic, all)
+	}
+
+	groupID := sub.config.GroupID
+	if groupID == "" {
+		groupID = f.groupID
+	}
+
+	// Draining only stops reading; in-flight handlers keep the subscription's context
+	fetchCtx, stopFetching := context.WithCancel(sub.ctx)
+	f.stopFetching = append(f.stopFetching, stopFetching)
+
+	for _, topic := range topics {
+		f.consumers.Add(1)
+		go func(topic string) {
+			defer f.consumers.Done()
+			f.consume(fetchCtx, sub, groupID, topic)
+		}(topic)
+	}
+}
+
+// listTopics returns the topics that have a log in the bus directory
+func (f *FileEventBus) listTopics() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(f.dir, "topics"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var topics []string
+	for _, entry := range entries {
+		if topic, err := url.PathUnescape(entry.Name()); err == nil && entry.IsDir() {
+			topics = append(topics, topic)
+		}
+	}
+	return topics, nil
+}
+
+// consume waits for the group's lock on topic and then delivers its records at least once:
+// an offset is only committed after the handler succeeded or the event was dead-lettered
+func (f *FileEventBus) consume(fetchCtx context.Context, sub subscription, groupID, topic string) {
+	l, err := f.topicLog(topic)
+	if err != nil {
+		log.Printf("Failed to open log of %s: %v", topic, err)
+		return
+	}
+
+	unlock, err := f.acquireGroup(fetchCtx, groupID, topic)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	offset, err := f.startOffset(l, groupID, topic, sub.config.StartOffset)
+	if err != nil {
+		log.Printf("Failed to load offset of group %s on %s: %v", groupID, topic, err)
+		return
+	}
+
+	reader := newLogReader(l, offset)
+	defer reader.close()
+
+	committer := newFileCommitter(f.offsets, groupID, topic, sub.config.commitInterval())
+	go committer.run(fetchCtx)
+	defer committer.flush()
+
+	failures := 0
+	for fetchCtx.Err() == nil {
+		record, err := reader.read()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				failures++
+				log.Printf("Error reading %s: %v", topic, err)
+			}
+
+			wait := f.pollInterval
+			if failures > 0 {
+				wait = sub.config.Retry.Backoff(failures)
+			}
+			select {
+			case <-fetchCtx.Done():
+				return
+			case <-time.After(wait):
+			}
+			continue
+		}
+		failures = 0
+
+		if err := f.processRecord(sub.ctx, topic, record, sub); err != nil {
+			log.Printf("Leaving record %s@%d uncommitted: %v", topic, record.Offset, err)
+			return
+		}
+
+		if err := committer.commit(record.Offset + 1); err != nil {
+			log.Printf("Failed to commit offset %s@%d: %v", topic, record.Offset, err)
+		}
+	}
+}
+
+// acquireGroup blocks until this process is the group's active consumer of topic or ctx is done
+func (f *FileEventBus) acquireGroup(ctx context.Context, groupID, topic string) (func(), error) {
+	path, err := f.offsets.lockPath(groupID, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	for waiting := false; ; waiting = true {
+		unlock, err := tryLockFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if unlock != nil {
+			return unlock, nil
+		}
+		if !waiting {
+			log.Printf("Waiting for another consumer of group %s on %s", groupID, topic)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(f.pollInterval):
+		}
+	}
+}
+
+// startOffset returns the committed offset of the group, or where a new group starts
+func (f *FileEventBus) startOffset(l *topicLog, groupID, topic string, start int64) (int64, error) {
+	committed, err := f.offsets.load(groupID, topic)
+	if err != nil || committed >= 0 {
+		return committed, err
+	}
+	if start == StartOffsetLatest {
+		return l.nextOffset()
+	}
+	return 0, nil
+}
+
+// processRecord handles a record, appending it to the dead-letter topic when handling fails.
+// It only returns an error when the record was neither handled nor dead-lettered.
+func (f *FileEventBus) processRecord(ctx context.Context, topic string, record fileRecord, sub subscription) error {
+	event, err := record.decode()
+	if err != nil {
+		log.Printf("Error decoding event: %v", err)
+		return f.deadLetter(ctx, topic, record, nil, sub.config, 0, err)
+	}
+
+	if !subscriptionMatches(sub.topic, topic, event) {
+		return nil
+	}
+
+	handlerCtx, span := tracing.StartConsumerSpan(extractMetadata(ctx, event), "file", topic)
+	attempts, err := handleWithRetry(handlerCtx, sub.config.Retry, f.wrap(sub.handler), event)
+	endSpan(span, err)
+
+	if err != nil {
+		log.Printf("Error handling event %s after %d attempts: %v", event.ID, attempts, err)
+		return f.deadLetter(ctx, topic, record, event, sub.config, attempts, err)
+	}
+
+	return nil
+}
+
+// deadLetter appends a record that could not be handled to its dead-letter topic with the
+// same x-dlq-* headers the Kafka bus uses. Without dead-lettering the record is dropped.
+func (f *FileEventBus) deadLetter(ctx context.Context, topic string, record fileRecord, event *Event, config SubscribeConfig, attempts int, cause error) error {
+	if !config.DeadLetter {
+		log.Printf("Dropping record %s@%d: %v", topic, record.Offset, cause)
+		return nil
+	}
+
+	if event == nil {
+		event = undecodableEvent(fmt.Sprintf("%s@%d", topic, record.Offset), record.Key, record.Event)
+	}
+	dlqEvent := newDeadLetterEvent(event, topic, 0, strconv.FormatInt(record.Offset, 10), attempts, cause)
+
+	l, err := f.topicLog(DeadLetterTopic(topic))
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		_, err := l.append([]string{record.Key}, []*Event{dlqEvent})
+		if err == nil {
+			break
+		}
+
+		log.Printf("Failed to route record to dead-letter topic %s (attempt %d): %v", DeadLetterTopic(topic), attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to route record to dead-letter topic: %w", err)
+		case <-time.After(config.Retry.Backoff(attempt)):
+		}
+	}
+
+	log.Printf("Record %s@%d routed to %s", topic, record.Offset, DeadLetterTopic(topic))
+	return nil
+}
+
+// Replay calls handler for every event of topic from the given offset or time up to the
+// current end of the log, without touching any consumer group's offset. It stops at the
+// first handler error.
+func (f *FileEventBus) Replay(ctx context.Context, topic string, from ReplayFrom, handler EventHandler) error {
+	l, err := f.topicLog(topic)
+	if err != nil {
+		return err
+	}
+
+	end, err := l.nextOffset()
+	if err != nil {
+		return err
+	}
+
+	reader := newLogReader(l, from.Offset)
+	defer reader.close()
+
+	for ctx.Err() == nil {
+		record, err := reader.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if record.Offset >= end {
+			return nil
+		}
+		if record.Timestamp.Before(from.Time) {
+			continue
+		}
+
+		event, err := record.decode()
+		if err != nil {
+			return fmt.Errorf("failed to decode event at %s@%d: %w", topic, record.Offset, err)
+		}
+		if err := handler(ContextWithEvent(ctx, event), event); err != nil {
+			return fmt.Errorf("failed to replay event at %s@%d: %w", topic, record.Offset, err)
+		}
+	}
+	return ctx.Err()
+}
+
+// ResetOffset moves the committed offset of a consumer group, e.g. to reprocess a topic
+// from the start. It takes effect when the group's consumer next starts.
+func (f *FileEventBus) ResetOffset(groupID, topic string, offset int64) error {
+	return f.offsets.commit(groupID, topic, offset)
+}
+
+// Compact drops every record of topic that has a later record with the same key, except
+// in the active segment, and returns how many records were dropped. Offsets of the
+// remaining records do not change.
+func (f *FileEventBus) Compact(topic string) (int, error) {
+	l, err := f.topicLog(topic)
+	if err != nil {
+		return 0, err
+	}
+	return l.compact()
+}
+
+// Drain stops reading and waits until in-flight records are handled and committed or
+// ctx is done. Publishing keeps working until Close, so handlers can still emit events.
+func (f *FileEventBus) Drain(ctx context.Context) error {
+	f.mu.Lock()
+	if f.state == busClosed {
+		f.mu.Unlock()
+		return ErrBusClosed
+	}
+	f.state = busDraining
+	f.pending = nil
+	for _, stop := range f.stopFetching {
+		stop()
+	}
+	f.mu.Unlock()
+
+	if err := waitGroupDone(ctx, &f.consumers); err != nil {
+		return fmt.Errorf("failed to drain file event bus: %w", err)
+	}
+	return nil
+}
+
+// Close stops all consumers without waiting for them and rejects further publishes.
+// Call Drain first for a graceful shutdown.
+func (f *FileEventBus) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.state = busClosed
+	for _, stop := range f.stopFetching {
+		stop()
+	}
+	return nil
+}
+
+// fileCommitter writes a consumer's offset file either after every record or, with an
+// interval, in the background and when the consumer stops
+type fileCommitter struct {
+	offsets  *offsetStore
+	group    string
+	topic    string
+	interval time.Duration
+
+	mu        sync.Mutex
+	pending   int64
+	committed int64
+}
+
+func newFileCommitter(offsets *offsetStore, group, topic string, interval time.Duration) *fileCommitter {
+	return &fileCommitter{
+		offsets:   offsets,
+		group:     group,
+		topic:     topic,
+		interval:  interval,
+		pending:   -1,
+		committed: -1,
+	}
+}
+
+// commit records next as the offset to resume from
+func (c *fileCommitter) commit(next int64) error {
+	c.mu.Lock()
+	c.pending = next
+	c.mu.Unlock()
+
+	if c.interval > 0 {
+		return nil
+	}
+	return c.commitPending()
+}
+
+func (c *fileCommitter) run(ctx context.Context) {
+	if c.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.commitPending(); err != nil {
+				log.Printf("Failed to commit offsets: %v", err)
+			}
+		}
+	}
+}
+
+func (c *fileCommitter) flush() {
+	if err := c.commitPending(); err != nil {
+		log.Printf("Failed to commit offsets: %v", err)
+	}
+}
+
+func (c *fileCommitter) commitPending() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending < 0 || c.pending == c.committed {
+		return nil
+	}
+	if err := c.offsets.commit(c.group, c.topic, c.pending); err != nil {
+		return err
+	}
+	c.committed = c.pending
+	return nil
+}
@@ -0,0 +1,265 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"shared/pkg/events"
+)
+
+func newTestFileBus(t *testing.T, dir string, opts ...events.FileOption) *events.FileEventBus {
+	t.Helper()
+
+	opts = append([]events.FileOption{events.WithPollInterval(5 * time.Millisecond), events.WithFileSync(false)}, opts...)
+	bus, err := events.NewFileEventBus(dir, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Consumers still write offsets after Close, so drain them before the directory goes
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		bus.Drain(ctx)
+		bus.Close()
+	})
+	return bus
+}
+
+// replayIDs returns the IDs of the events Replay hands out
+func replayIDs(t *testing.T, bus *events.FileEventBus, topic string, from events.ReplayFrom) []string {
+	t.Helper()
+
+	var ids []string
+	err := bus.Replay(context.Background(), topic, from, func(ctx context.Context, event *events.Event) error {
+		ids = append(ids, event.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	return ids
+}
+
+// idRecorder is a handler collecting the IDs of the events it handled
+type idRecorder struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (r *idRecorder) handle(ctx context.Context, event *events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ids = append(r.ids, event.ID)
+	return nil
+}
+
+func (r *idRecorder) handled() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.ids...)
+}
+
+func assertIDs(t *testing.T, what string, got []string, want ...string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v, want %v", what, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s = %v, want %v", what, got, want)
+		}
+	}
+}
+
+func drain(t *testing.T, bus events.Lifecycle) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bus.Drain(ctx); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+}
+
+func TestFileEventBusCompactKeepsLatestRecordPerKey(t *testing.T) {
+	dir := t.TempDir()
+	// Every record gets a segment of its own, so all but the last can be compacted
+	bus := newTestFileBus(t, dir, events.WithSegmentBytes(1))
+
+	var published []*events.Event
+	for _, userID := range []string{"a", "b", "a", "c", "a"} {
+		published = append(published, publishUserEvent(t, bus, "user-events", userID))
+	}
+	id := func(offset int) string { return published[offset].ID }
+
+	if err := bus.ResetOffset(testGroup, "user-events", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := bus.Compact("user-events")
+	if err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Compact() removed %d records, want 2", removed)
+	}
+
+	assertIDs(t, "events after compaction", replayIDs(t, bus, "user-events", events.ReplayFrom{}), id(1), id(3), id(4))
+	assertIDs(t, "events from offset 3", replayIDs(t, bus, "user-events", events.ReplayFrom{Offset: 3}), id(3), id(4))
+	assertIDs(t, "events from offset 2", replayIDs(t, bus, "user-events", events.ReplayFrom{Offset: 2}), id(3), id(4))
+
+	// The group resumes at its committed offset, which still points at the same record
+	recorder := &idRecorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := bus.Subscribe(ctx, "user-events", recorder.handle, events.WithGroupID(testGroup)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the group to catch up", func() bool { return len(recorder.handled()) >= 2 })
+	drain(t, bus)
+	assertIDs(t, "events handled by the group", recorder.handled(), id(3), id(4))
+}
+
+func TestFileEventBusReplay(t *testing.T) {
+	bus := newTestFileBus(t, t.TempDir())
+
+	first := publishUserEvent(t, bus, "user-events", "a")
+	time.Sleep(10 * time.Millisecond)
+	since := time.Now()
+	second := publishUserEvent(t, bus, "user-events", "b")
+	third := publishUserEvent(t, bus, "user-events", "c")
+
+	assertIDs(t, "events from the start", replayIDs(t, bus, "user-events", events.ReplayFrom{}), first.ID, second.ID, third.ID)
+	assertIDs(t, "events from offset 2", replayIDs(t, bus, "user-events", events.ReplayFrom{Offset: 2}), third.ID)
+	assertIDs(t, "events since a time", replayIDs(t, bus, "user-events", events.ReplayFrom{Time: since}), second.ID, third.ID)
+
+	failure := errors.New("projection unavailable")
+	calls := 0
+	err := bus.Replay(context.Background(), "user-events", events.ReplayFrom{}, func(ctx context.Context, event *events.Event) error {
+		calls++
+		return failure
+	})
+	if !errors.Is(err, failure) || calls != 1 {
+		t.Errorf("Replay() = %v after %d calls, want the handler error after 1 call", err, calls)
+	}
+
+	// Replaying does not move any consumer group
+	recorder := &idRecorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := bus.Subscribe(ctx, "user-events", recorder.handle, events.WithGroupID(testGroup)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the group to read the topic", func() bool { return len(recorder.handled()) >= 3 })
+	drain(t, bus)
+	assertIDs(t, "events handled by the group", recorder.handled(), first.ID, second.ID, third.ID)
+}
+
+func TestFileEventBusRedeliversUncommittedRecordsAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	bus := newTestFileBus(t, dir)
+
+	publishUserEvent(t, bus, "user-events", "a")
+	second := publishUserEvent(t, bus, "user-events", "b")
+	third := publishUserEvent(t, bus, "user-events", "c")
+
+	// A file in place of the dead-letter log makes dead-lettering fail
+	blocker := filepath.Join(dir, "topics", events.DeadLetterTopic("user-events"))
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	failed := make(chan struct{})
+	handler := func(ctx context.Context, event *events.Event) error {
+		if event.ID == second.ID {
+			close(failed)
+			return events.DeadLetter(errors.New("quota service unavailable"))
+		}
+		return nil
+	}
+	// Offsets committed in the background must still be written when the consumer stops
+	opts := []events.SubscribeOption{events.WithGroupID(testGroup), events.WithCommitMode(events.CommitModeInterval, time.Hour)}
+	if err := bus.Subscribe(ctx, "user-events", handler, opts...); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The consumer stops at the record it could neither handle nor dead-letter
+	<-failed
+	drain(t, bus)
+	if err := os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newTestFileBus(t, dir)
+	recorder := &idRecorder{}
+	if err := restarted.Subscribe(ctx, "user-events", recorder.handle, opts...); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the restarted consumer to catch up", func() bool { return len(recorder.handled()) >= 2 })
+	drain(t, restarted)
+	assertIDs(t, "events handled after the restart", recorder.handled(), second.ID, third.ID)
+}
+
+func TestFileEventBusDeadLetterKeepsHeaders(t *testing.T) {
+	bus := newTestFileBus(t, t.TempDir())
+	event := publishTestEvent(t, bus, "user-events")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := func(ctx context.Context, event *events.Event) error {
+		return events.DeadLetter(errors.New("invalid payload"))
+	}
+	if err := bus.Subscribe(ctx, "user-events", handler, events.WithGroupID(testGroup)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var dead []*events.Event
+	waitFor(t, "the event to be dead-lettered", func() bool {
+		dead = nil
+		err := bus.Replay(ctx, events.DeadLetterTopic("user-events"), events.ReplayFrom{}, func(ctx context.Context, event *events.Event) error {
+			dead = append(dead, event)
+			return nil
+		})
+		return err == nil && len(dead) == 1
+	})
+
+	if dead[0].ID != event.ID {
+		t.Errorf("dead-lettered event %s, want %s", dead[0].ID, event.ID)
+	}
+	want := map[string]string{
+		events.DeadLetterErrorHeader:           "invalid payload",
+		events.DeadLetterAttemptsHeader:        "1",
+		events.DeadLetterSourceTopicHeader:     "user-events",
+		events.DeadLetterSourcePartitionHeader: "0",
+		events.DeadLetterSourceOffsetHeader:    "0",
+	}
+	for key, value := range want {
+		if got := dead[0].Header(key); got != value {
+			t.Errorf("header %s = %q, want %q", key, got, value)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+//go:build !unix
+
+package events
+
+import (
+	"sync"
+)
+
+// Without flock the file bus only coordinates goroutines of a single process
+
+var (
+	fileLocksMu sync.Mutex
+	fileLocks   = make(map[string]*sync.Mutex)
+)
+
+func fileMutex(path string) *sync.Mutex {
+	fileLocksMu.Lock()
+	defer fileLocksMu.Unlock()
+
+	lock, exists := fileLocks[path]
+	if !exists {
+		lock = &sync.Mutex{}
+		fileLocks[path] = lock
+	}
+	return lock
+}
+
+func lockFile(path string) (func(), error) {
+	lock := fileMutex(path)
+	lock.Lock()
+	return lock.Unlock, nil
+}
+
+func tryLockFile(path string) (func(), error) {
+	lock := fileMutex(path)
+	if !lock.TryLock() {
+		return nil, nil
+	}
+	return lock.Unlock, nil
+}
@@ -0,0 +1,558 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentSuffix  = ".log"
+	topicLockName  = "topic.lock"
+	segmentNameLen = 20
+)
+
+// fileRecord is one line of a segment file. Headers holds the event headers its
+// CloudEvents JSON cannot carry, such as the x-dlq-* headers of dead letters.
+type fileRecord struct {
+	Offset    int64             `json:"offset"`
+	Key       string            `json:"key,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Event     json.RawMessage   `json:"event"`
+}
+
+// decode returns the event of the record with its headers
+func (r fileRecord) decode() (*Event, error) {
+	var event Event
+	if err := json.Unmarshal(r.Event, &event); err != nil {
+		return nil, err
+	}
+	mergeHeaders(&event, r.Headers)
+	return &event, nil
+}
+
+// topicLog is the append-only log of one topic: a directory of newline-delimited JSON
+// segment files named after the offset of their first record. Appends and compaction
+// take an advisory lock on the directory, so several processes can share a log.
+type topicLog struct {
+	dir          string
+	segmentBytes int64
+	sync         bool
+
+	// Cached end of the active segment, valid while its size is unchanged
+	mu         sync.Mutex
+	cachedPath string
+	cachedSize int64
+	cachedNext int64
+}
+
+func newTopicLog(root, topic string, segmentBytes int64, sync bool) (*topicLog, error) {
+	dir := filepath.Join(root, "topics", url.PathEscape(topic))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory for %s: %w", topic, err)
+	}
+
+	return &topicLog{
+		dir:          dir,
+		segmentBytes: segmentBytes,
+		sync:         sync,
+	}, nil
+}
+
+// append writes events to the end of the log and returns the offset of the first one
+func (l *topicLog) append(keys []string, events []*Event) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	unlock, err := lockFile(filepath.Join(l.dir, topicLockName))
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	path, size, next, err := l.end()
+	if err != nil {
+		return 0, err
+	}
+	if size >= l.segmentBytes {
+		path, size = l.segmentPath(next), 0
+	}
+
+	var buf bytes.Buffer
+	now := time.Now().UTC()
+	for i, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal event: %w", err)
+		}
+
+		record := fileRecord{Offset: next + int64(i), Key: keys[i], Timestamp: now, Headers: event.plainHeaders(), Event: payload}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal record: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open segment: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		l.cachedPath = ""
+		return 0, fmt.Errorf("failed to append to segment: %w", err)
+	}
+	if l.sync {
+		if err := f.Sync(); err != nil {
+			l.cachedPath = ""
+			return 0, fmt.Errorf("failed to sync segment: %w", err)
+		}
+	}
+
+	l.cachedPath = path
+	l.cachedSize = size + int64(buf.Len())
+	l.cachedNext = next + int64(len(events))
+	return next, nil
+}
+
+// end returns the active segment, its size and the next offset; callers hold the topic lock
+func (l *topicLog) end() (string, int64, int64, error) {
+	segments, err := l.segments()
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if len(segments) == 0 {
+		return l.segmentPath(0), 0, 0, nil
+	}
+
+	base := segments[len(segments)-1]
+	path := l.segmentPath(base)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("failed to stat segment: %w", err)
+	}
+
+	// Another process may have appended since our last write
+	if path == l.cachedPath && info.Size() == l.cachedSize {
+		return path, l.cachedSize, l.cachedNext, nil
+	}
+
+	next := base
+	err = scanSegment(path, func(record fileRecord) error {
+		next = record.Offset + 1
+		return nil
+	})
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	l.cachedPath, l.cachedSize, l.cachedNext = path, info.Size(), next
+	return path, info.Size(), next, nil
+}
+
+// nextOffset returns the offset the next appended record will get
+func (l *topicLog) nextOffset() (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	unlock, err := lockFile(filepath.Join(l.dir, topicLockName))
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	_, _, next, err := l.end()
+	return next, err
+}
+
+// segments returns the base offsets of all segments in ascending order
+func (l *topicLog) segments() ([]int64, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list segments: %w", err)
+	}
+
+	var bases []int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		base, err := strconv.ParseInt(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		bases = append(bases, base)
+	}
+
+	sort.Slice(bases, func(i, j int) bool { return bases[i] < bases[j] })
+	return bases, nil
+}
+
+func (l *topicLog) segmentPath(base int64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%0*d%s", segmentNameLen, base, segmentSuffix))
+}
+
+// compact rewrites every segment but the active one, keeping only the latest record of
+// each key; records without a key are kept. Offsets are preserved, so consumer offsets
+// stay valid, and readers of a replaced segment keep reading the file they opened.
+func (l *topicLog) compact() (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	unlock, err := lockFile(filepath.Join(l.dir, topicLockName))
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	segments, err := l.segments()
+	if err != nil {
+		return 0, err
+	}
+	if len(segments) < 2 {
+		return 0, nil
+	}
+
+	latest := make(map[string]int64)
+	for _, base := range segments {
+		err := scanSegment(l.segmentPath(base), func(record fileRecord) error {
+			if record.Key != "" {
+				latest[record.Key] = record.Offset
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	removed := 0
+	for _, base := range segments[:len(segments)-1] {
+		n, err := l.compactSegment(l.segmentPath(base), latest)
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+// compactSegment rewrites one closed segment and returns how many records it dropped;
+// a segment left without records is deleted
+func (l *topicLog) compactSegment(path string, latest map[string]int64) (int, error) {
+	var kept bytes.Buffer
+	removed, total := 0, 0
+
+	err := scanSegmentLines(path, func(record fileRecord, line []byte) error {
+		total++
+		if record.Key != "" && latest[record.Key] != record.Offset {
+			removed++
+			return nil
+		}
+		kept.Write(line)
+		kept.WriteByte('\n')
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	if removed == total {
+		if err := os.Remove(path); err != nil {
+			return 0, fmt.Errorf("failed to remove compacted segment: %w", err)
+		}
+		return removed, nil
+	}
+
+	tmp := path + ".compact"
+	if err := writeFileSync(tmp, kept.Bytes()); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("failed to replace compacted segment: %w", err)
+	}
+	return removed, nil
+}
+
+// scanSegment calls fn for every complete record of a segment file
+func scanSegment(path string, fn func(record fileRecord) error) error {
+	return scanSegmentLines(path, func(record fileRecord, _ []byte) error {
+		return fn(record)
+	})
+}
+
+func scanSegmentLines(path string, fn func(record fileRecord, line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A trailing line without newline is still being written
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read segment: %w", err)
+		}
+
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		var record fileRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("corrupt record in %s: %w", path, err)
+		}
+		if err := fn(record, line); err != nil {
+			return err
+		}
+	}
+}
+
+// logReader reads a topicLog from an offset onwards, following segment rollovers
+type logReader struct {
+	log  *topicLog
+	next int64
+
+	file     *os.File
+	reader   *bufio.Reader
+	base     int64
+	pos      int64
+	complete bool
+}
+
+func newLogReader(log *topicLog, offset int64) *logReader {
+	return &logReader{log: log, next: offset}
+}
+
+// read returns the next record, or io.EOF when the reader has caught up with the log
+func (r *logReader) read() (fileRecord, error) {
+	for {
+		if r.file == nil {
+			opened, err := r.open()
+			if err != nil {
+				return fileRecord{}, err
+			}
+			if !opened {
+				return fileRecord{}, io.EOF
+			}
+			if r.file == nil {
+				continue
+			}
+		}
+
+		line, err := r.reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Rewind any partial line; it is read again once the writer finished it
+			if _, err := r.file.Seek(r.pos, io.SeekStart); err != nil {
+				return fileRecord{}, fmt.Errorf("failed to seek segment: %w", err)
+			}
+			r.reader.Reset(r.file)
+
+			if len(line) == 0 {
+				// Segments roll over under the topic lock, so once a later segment exists
+				// one more read of the current segment sees everything ever written to it
+				if r.complete {
+					later, err := r.laterSegment()
+					if err != nil {
+						return fileRecord{}, err
+					}
+					r.switchTo(later)
+					continue
+				}
+
+				later, err := r.laterSegment()
+				if err != nil {
+					return fileRecord{}, err
+				}
+				if later >= 0 {
+					r.complete = true
+					continue
+				}
+			}
+			return fileRecord{}, io.EOF
+		}
+		if err != nil {
+			return fileRecord{}, fmt.Errorf("failed to read segment: %w", err)
+		}
+		r.pos += int64(len(line))
+
+		var record fileRecord
+		if err := json.Unmarshal(bytes.TrimSuffix(line, []byte("\n")), &record); err != nil {
+			return fileRecord{}, fmt.Errorf("corrupt record at offset %d: %w", r.next, err)
+		}
+		if record.Offset < r.next {
+			continue
+		}
+
+		r.next = record.Offset + 1
+		return record, nil
+	}
+}
+
+// open opens the segment holding the next offset; it reports false when there is none yet
+func (r *logReader) open() (bool, error) {
+	segments, err := r.log.segments()
+	if err != nil {
+		return false, err
+	}
+
+	base := int64(-1)
+	for _, b := range segments {
+		if b <= r.next || base < 0 {
+			base = b
+		}
+		if b > r.next {
+			break
+		}
+	}
+	if base < 0 {
+		return false, nil
+	}
+
+	return true, r.openSegment(base)
+}
+
+func (r *logReader) openSegment(base int64) error {
+	f, err := os.Open(r.log.segmentPath(base))
+	if errors.Is(err, os.ErrNotExist) {
+		// Compacted away in the meantime; pick again
+		r.file = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+
+	r.file = f
+	r.reader = bufio.NewReader(f)
+	r.base = base
+	r.pos = 0
+	r.complete = false
+	return nil
+}
+
+// laterSegment returns the first segment after the current one, or -1
+func (r *logReader) laterSegment() (int64, error) {
+	segments, err := r.log.segments()
+	if err != nil {
+		return -1, err
+	}
+	for _, base := range segments {
+		if base > r.base {
+			return base, nil
+		}
+	}
+	return -1, nil
+}
+
+func (r *logReader) switchTo(base int64) {
+	r.close()
+	if err := r.openSegment(base); err != nil {
+		r.file = nil
+	}
+}
+
+func (r *logReader) close() {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+// offsetStore keeps the committed offset of each consumer group and topic in its own file
+type offsetStore struct {
+	dir string
+}
+
+func newOffsetStore(root string) *offsetStore {
+	return &offsetStore{dir: filepath.Join(root, "offsets")}
+}
+
+func (s *offsetStore) path(group, topic, suffix string) (string, error) {
+	dir := filepath.Join(s.dir, url.PathEscape(group))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create offsets directory: %w", err)
+	}
+	return filepath.Join(dir, url.PathEscape(topic)+suffix), nil
+}
+
+// load returns the next offset group reads from topic, or -1 when it committed nothing
+func (s *offsetStore) load(group, topic string) (int64, error) {
+	path, err := s.path(group, topic, ".offset")
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return -1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read committed offset: %w", err)
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid committed offset in %s: %w", path, err)
+	}
+	return offset, nil
+}
+
+// commit records next as the offset group resumes topic from
+func (s *offsetStore) commit(group, topic string, next int64) error {
+	path, err := s.path(group, topic, ".offset")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, []byte(strconv.FormatInt(next, 10))); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to commit offset: %w", err)
+	}
+	return nil
+}
+
+// lockPath is the file the active consumer of group holds while reading topic
+func (s *offsetStore) lockPath(group, topic string) (string, error) {
+	return s.path(group, topic, ".lock")
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	return f.Close()
+}
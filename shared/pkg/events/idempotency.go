@@ -0,0 +1,161 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"shared/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ProcessedEventStore records which events each consumer has already handled
+type ProcessedEventStore interface {
+	// Process runs fn unless consumer has already handled eventID. Recording the
+	// event and running fn are atomic: when fn fails the event is not recorded.
+	// It reports whether fn was run.
+	Process(ctx context.Context, consumer, eventID string, fn func(ctx context.Context) error) (bool, error)
+}
+
+// Idempotent wraps handler so each event ID is handled at most once per consumer
+func Idempotent(store ProcessedEventStore, consumer string, handler EventHandler) EventHandler {
+	return func(ctx context.Context, event *Event) error {
+		processed, err := store.Process(ctx, consumer, event.ID, func(ctx context.Context) error {
+			return handler(ctx, event)
+		})
+		if err != nil {
+			return err
+		}
+
+		if !processed {
+			log.Printf("Skipping duplicate event %s (%s) for consumer %s", event.ID, event.Type, consumer)
+		}
+		return nil
+	}
+}
+
+// PostgresProcessedEventStore keeps processed event IDs in the processed_events table.
+// The handler runs in the same transaction as the insert, so repositories that use
+// database.Executor commit their changes together with the processed marker.
+type PostgresProcessedEventStore struct {
+	db        *sqlx.DB
+	txManager *database.TxManager
+}
+
+// NewPostgresProcessedEventStore creates a new Postgres-backed processed events store
+func NewPostgresProcessedEventStore(db *sqlx.DB) *PostgresProcessedEventStore {
+	return &PostgresProcessedEventStore{
+		db:        db,
+		txManager: database.NewTxManager(db),
+	}
+}
+
+// Process records eventID for consumer and runs fn in the same transaction
+func (s *PostgresProcessedEventStore) Process(ctx context.Context, consumer, eventID string, fn func(ctx context.Context) error) (bool, error) {
+	processed := false
+
+	err := s.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		query := `
+			INSERT INTO processed_events (consumer, event_id, processed_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (consumer, event_id) DO NOTHING
+		`
+
+		result, err := database.Executor(ctx, s.db).ExecContext(ctx, query, consumer, eventID, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("failed to record processed event: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to record processed event: %w", err)
+		}
+		if rows == 0 {
+			return nil
+		}
+
+		processed = true
+		return fn(ctx)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return processed, nil
+}
+
+// MemoryProcessedEventStore is an in-memory ProcessedEventStore for tests and the memory bus
+type MemoryProcessedEventStore struct {
+	mu        sync.Mutex
+	processed map[string]bool
+	locks     map[string]*eventLock
+}
+
+// eventLock serializes the deliveries of one event; it is dropped once none holds or waits for it
+type eventLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewMemoryProcessedEventStore creates a new in-memory processed events store
+func NewMemoryProcessedEventStore() *MemoryProcessedEventStore {
+	return &MemoryProcessedEventStore{
+		processed: make(map[string]bool),
+		locks:     make(map[string]*eventLock),
+	}
+}
+
+// Process runs fn unless eventID was already processed by consumer
+func (s *MemoryProcessedEventStore) Process(ctx context.Context, consumer, eventID string, fn func(ctx context.Context) error) (bool, error) {
+	key := consumer + "/" + eventID
+
+	// Serialize concurrent deliveries of the same event
+	lock := s.lock(key)
+	lock.Lock()
+	defer s.unlock(key, lock)
+
+	s.mu.Lock()
+	done := s.processed[key]
+	s.mu.Unlock()
+	if done {
+		return false, nil
+	}
+
+	if err := fn(ctx); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	s.processed[key] = true
+	s.mu.Unlock()
+
+	return true, nil
+}
+
+func (s *MemoryProcessedEventStore) lock(key string) *eventLock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, exists := s.locks[key]
+	if !exists {
+		lock = &eventLock{}
+		s.locks[key] = lock
+	}
+	lock.refs++
+	return lock
+}
+
+// unlock releases the lock of key and forgets it when no other delivery waits for it
+func (s *MemoryProcessedEventStore) unlock(key string, lock *eventLock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock.refs--
+	if lock.refs == 0 {
+		delete(s.locks, key)
+	}
+	lock.Unlock()
+}
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMemoryProcessedEventStoreForgetsLocks(t *testing.T) {
+	store := NewMemoryProcessedEventStore()
+	ctx := context.Background()
+
+	// Every event is delivered several times at once and handled once
+	var handled [20]atomic.Int32
+	var wg sync.WaitGroup
+	for delivery := 0; delivery < 5; delivery++ {
+		for i := range handled {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := store.Process(ctx, "user-service", fmt.Sprintf("e%d", i), func(ctx context.Context) error {
+					handled[i].Add(1)
+					return nil
+				})
+				if err != nil {
+					t.Errorf("Process() error = %v", err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for i := range handled {
+		if got := handled[i].Load(); got != 1 {
+			t.Errorf("event e%d handled %d times, want once", i, got)
+		}
+	}
+	if len(store.locks) != 0 {
+		t.Errorf("%d locks left after every delivery finished", len(store.locks))
+	}
+}
+
+func TestMemoryProcessedEventStoreReleasesFailedEvents(t *testing.T) {
+	store := NewMemoryProcessedEventStore()
+	ctx := context.Background()
+
+	failure := errors.New("quota service unavailable")
+	if _, err := store.Process(ctx, "user-service", "e1", func(ctx context.Context) error { return failure }); !errors.Is(err, failure) {
+		t.Fatalf("Process() error = %v, want the handler's", err)
+	}
+	if len(store.locks) != 0 {
+		t.Errorf("%d locks left after the handler failed", len(store.locks))
+	}
+
+	// The failed event was not recorded, so its redelivery is handled
+	processed, err := store.Process(ctx, "user-service", "e1", func(ctx context.Context) error { return nil })
+	if err != nil || !processed {
+		t.Errorf("Process() of the redelivery = %v, %v, want it handled", processed, err)
+	}
+}
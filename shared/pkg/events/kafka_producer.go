@@ -0,0 +1,178 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"shared/pkg/tracing"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// KafkaProducerConfig tunes the writer of a KafkaEventBus. Zero fields keep kafka-go's
+// defaults.
+//
+// Events published by handlers are committed together with the consumed offset only with
+// WithTransactionalID. Without it, and on the other backends, consume-transform-produce
+// handlers get that guarantee from the database: Idempotent with a
+// PostgresProcessedEventStore runs the handler in the transaction that records the event as
+// processed, and a publisher on an OutboxEventBus writes its events in that same
+// transaction. A redelivery after a rebalance finds the marker and publishes nothing, and
+// an event the relay sends twice keeps its ID.
+type KafkaProducerConfig struct {
+	// BatchSize is the number of messages buffered per partition before a batch is sent
+	BatchSize int
+	// BatchBytes caps the size of a request
+	BatchBytes int64
+	// Linger is how long an incomplete batch waits for more messages before it is sent
+	Linger time.Duration
+	// Compression of message batches, e.g. kafka.Snappy or kafka.Zstd
+	Compression kafka.Compression
+	// RequiredAcks is how many replicas acknowledge a batch: kafka.RequireNone,
+	// kafka.RequireOne or kafka.RequireAll
+	RequiredAcks kafka.RequiredAcks
+	// Async makes Publish and PublishBatch return once messages are buffered. Delivery
+	// results are reported to OnDelivery, or logged when it is nil. Dead letters and
+	// events of the outbox relay are still written synchronously by a second writer, as
+	// offsets and outbox rows must only move on once Kafka acknowledged them.
+	Async      bool
+	OnDelivery func(deliveries []KafkaDelivery)
+	// TransactionTimeout is how long the broker lets a transaction of WithTransactionalID
+	// stay open before aborting it; zero means DefaultTransactionTimeout
+	TransactionTimeout time.Duration
+}
+
+// KafkaDelivery is the outcome of writing one event in async mode
+type KafkaDelivery struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	EventID   string
+	Err       error
+}
+
+// DefaultKafkaProducerConfig returns the producer settings of NewKafkaEventBus: synchronous
+// writes acknowledged by all in-sync replicas, sent after at most 10ms
+func DefaultKafkaProducerConfig() KafkaProducerConfig {
+	return KafkaProducerConfig{
+		BatchSize:    100,
+		Linger:       10 * time.Millisecond,
+		RequiredAcks: kafka.RequireAll,
+	}
+}
+
+// WithProducerConfig replaces the producer settings. It has no effect together with
+// WithMessageWriter.
+func WithProducerConfig(config KafkaProducerConfig) KafkaOption {
+	return func(k *KafkaEventBus) {
+		k.producer = config
+	}
+}
+
+// newWriter creates a Kafka writer from the producer settings. Message keys are hashed
+// like the Java client does so one aggregate always maps to one partition.
+func (k *KafkaEventBus) newWriter(async bool) *kafka.Writer {
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(k.brokers...),
+		Balancer:               &kafka.Murmur2Balancer{},
+		AllowAutoTopicCreation: true,
+		BatchSize:              k.producer.BatchSize,
+		BatchBytes:             k.producer.BatchBytes,
+		BatchTimeout:           k.producer.Linger,
+		Compression:            k.producer.Compression,
+		RequiredAcks:           k.producer.RequiredAcks,
+		Async:                  async,
+	}
+	if async {
+		writer.Completion = k.delivered
+	}
+	return writer
+}
+
+// acknowledgedWritesKey marks a context whose publishes must not return before Kafka
+// acknowledged them
+type acknowledgedWritesKey struct{}
+
+// withAcknowledgedWrites makes publishes with ctx synchronous even for an async producer
+func withAcknowledgedWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, acknowledgedWritesKey{}, true)
+}
+
+// writerFor returns the writer for a publish with ctx. Handlers in transactional mode
+// publish into the transaction of the message they handle.
+func (k *KafkaEventBus) writerFor(ctx context.Context) MessageWriter {
+	if txn, ok := k.transactionFrom(ctx); ok {
+		return txn
+	}
+	if acknowledged, _ := ctx.Value(acknowledgedWritesKey{}).(bool); acknowledged {
+		return k.ackWriter
+	}
+	return k.writer
+}
+
+// delivered reports the outcome of an async write
+func (k *KafkaEventBus) delivered(messages []kafka.Message, err error) {
+	if k.producer.OnDelivery == nil {
+		if err != nil {
+			log.Printf("Failed to deliver %d events: %v", len(messages), err)
+		}
+		return
+	}
+
+	deliveries := make([]KafkaDelivery, len(messages))
+	for i, msg := range messages {
+		deliveries[i] = KafkaDelivery{
+			Topic:     msg.Topic,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+			Err:       err,
+		}
+		if event, decodeErr := decodeKafkaMessage(msg); decodeErr == nil {
+			deliveries[i].EventID = event.ID
+		}
+	}
+	k.producer.OnDelivery(deliveries)
+}
+
+// PublishBatch writes all events with one WriteMessages call, each keyed by its aggregate ID
+// like Publish. Events that already carry a trace, e.g. from the outbox, are published as
+// part of it. A failed batch may have been written in part.
+func (k *KafkaEventBus) PublishBatch(ctx context.Context, topic string, events []*Event) error {
+	k.mu.RLock()
+	closed := k.state == busClosed
+	k.mu.RUnlock()
+	if closed {
+		return ErrBusClosed
+	}
+
+	msgs := make([]kafka.Message, len(events))
+	spans := make([]trace.Span, 0, len(events))
+	var err error
+	defer func() {
+		for _, span := range spans {
+			endSpan(span, err)
+		}
+	}()
+
+	for i, event := range events {
+		key := partitionKey(event)
+
+		eventCtx, span := tracing.StartKafkaProducerSpan(tracing.ExtractKafkaTrace(ctx, event.Headers), topic, key)
+		spans = append(spans, span)
+		injectMetadata(eventCtx, event)
+
+		if msgs[i], err = encodeKafkaMessage(topic, []byte(key), event, k.contentMode); err != nil {
+			return err
+		}
+	}
+
+	if err = k.writerFor(ctx).WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("failed to publish %d events: %w", len(events), err)
+	}
+
+	log.Printf("Events published: %d to topic: %s", len(events), topic)
+	return nil
+}
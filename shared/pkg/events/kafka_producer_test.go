@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestAsyncProducerAcknowledgesDeadLettersAndRelayWrites(t *testing.T) {
+	config := DefaultKafkaProducerConfig()
+	config.Async = true
+	bus := NewKafkaEventBus([]string{"localhost:9092"}, WithProducerConfig(config))
+	defer bus.Close()
+
+	writer, ok := bus.writer.(*kafka.Writer)
+	if !ok || !writer.Async {
+		t.Fatalf("publish writer %T is not async", bus.writer)
+	}
+	ackWriter, ok := bus.ackWriter.(*kafka.Writer)
+	if !ok || ackWriter.Async {
+		t.Fatalf("writer for dead letters and the relay %T is async", bus.ackWriter)
+	}
+
+	ctx := context.Background()
+	if bus.writerFor(ctx) != bus.writer {
+		t.Error("plain publishes do not use the async writer")
+	}
+	if bus.writerFor(withAcknowledgedWrites(ctx)) != bus.ackWriter {
+		t.Error("acknowledged publishes do not use the synchronous writer")
+	}
+}
+
+func TestSyncProducerSharesItsWriter(t *testing.T) {
+	bus := NewKafkaEventBus([]string{"localhost:9092"})
+	defer bus.Close()
+
+	if bus.ackWriter != bus.writer {
+		t.Error("a synchronous producer has a second writer")
+	}
+}
@@ -0,0 +1,464 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	metadataAPI "github.com/segmentio/kafka-go/protocol/metadata"
+)
+
+// DefaultTransactionTimeout is how long the transaction coordinator waits for a transaction
+// to end before aborting it, unless KafkaProducerConfig.TransactionTimeout sets it
+const DefaultTransactionTimeout = time.Minute
+
+// errTransactionEnded is returned for events published with a handler's context after the
+// handler returned
+var errTransactionEnded = errors.New("kafka transaction already ended")
+
+// TransactionWriter writes the messages a handler published and commits the offset of the
+// message it consumed in one Kafka transaction; tests can supply a fake
+type TransactionWriter interface {
+	// WriteTransaction writes msgs and commits the offset after consumed for group, so
+	// read-committed consumers see either both or neither
+	WriteTransaction(ctx context.Context, group string, consumed kafka.Message, msgs ...kafka.Message) error
+	Close() error
+}
+
+// WithTransactionalID makes subscriptions consume, transform and produce exactly once.
+// Events a handler publishes with its context are held back until it returns, then written
+// in one Kafka transaction together with the offset of the message it handled, or with the
+// message's dead letter when handling failed. A message redelivered after a crash or a
+// rebalance has therefore not published its events yet. Offsets are committed per message;
+// WithCommitMode has no effect.
+//
+// The ID identifies the producer to the transaction coordinator and must be stable across
+// restarts and unique per service instance, e.g. the pod name; a second producer with the
+// same ID fences off the first. kafka-go's reader does not expose the group generation, so
+// a consumer that lost its partitions is not fenced by the offset commit itself. Handlers
+// that must never act twice keep using Idempotent with a PostgresProcessedEventStore, which
+// is also the fallback on the other backends.
+func WithTransactionalID(id string) KafkaOption {
+	return func(k *KafkaEventBus) {
+		k.transactionalID = id
+	}
+}
+
+// WithTransactionWriter replaces the transactional writer, e.g. with an in-process stand-in.
+// It only takes effect together with WithTransactionalID.
+func WithTransactionWriter(writer TransactionWriter) KafkaOption {
+	return func(k *KafkaEventBus) {
+		k.transactions = writer
+	}
+}
+
+// transactionKey carries the transaction of the message a handler is handling
+type transactionKey struct{}
+
+// kafkaTransaction collects the messages published while a handler handles a message in
+// transactional mode. It is a MessageWriter, so Publish and PublishBatch write into it.
+type kafkaTransaction struct {
+	bus *KafkaEventBus
+
+	mu    sync.Mutex
+	msgs  []kafka.Message
+	ended bool
+}
+
+func withTransaction(ctx context.Context, txn *kafkaTransaction) context.Context {
+	return context.WithValue(ctx, transactionKey{}, txn)
+}
+
+// transactionFrom returns the transaction of k that ctx belongs to, if any
+func (k *KafkaEventBus) transactionFrom(ctx context.Context) (*kafkaTransaction, bool) {
+	txn, ok := ctx.Value(transactionKey{}).(*kafkaTransaction)
+	return txn, ok && txn.bus == k
+}
+
+// WriteMessages holds msgs back until the transaction is committed
+func (t *kafkaTransaction) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ended {
+		return errTransactionEnded
+	}
+	t.msgs = append(t.msgs, msgs...)
+	return nil
+}
+
+func (t *kafkaTransaction) Close() error {
+	return nil
+}
+
+// end stops taking messages and returns the ones collected
+func (t *kafkaTransaction) end() []kafka.Message {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.ended = true
+	return t.msgs
+}
+
+// transactional wraps handler so every attempt publishes into a transaction of its own,
+// discarding what failed attempts published. current returns the latest attempt's.
+func (k *KafkaEventBus) transactional(handler EventHandler) (wrapped EventHandler, current func() *kafkaTransaction) {
+	var txn *kafkaTransaction
+	wrapped = func(ctx context.Context, event *Event) error {
+		txn.end()
+		txn = &kafkaTransaction{bus: k}
+		return handler(withTransaction(ctx, txn), event)
+	}
+	return wrapped, func() *kafkaTransaction { return txn }
+}
+
+// commitTransaction writes the messages published while handling msg and commits its
+// offset in one transaction, retrying until it succeeds, the retry policy is exhausted or
+// ctx is done. Retries write the same messages, so an event keeps its ID however often it
+// is attempted.
+func (k *KafkaEventBus) commitTransaction(ctx context.Context, msg kafka.Message, published []kafka.Message, config SubscribeConfig) error {
+	for attempt := 1; ; attempt++ {
+		err := k.transactions.WriteTransaction(ctx, config.GroupID, msg, published...)
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("Failed to commit transaction of %s[%d]@%d (attempt %d): %v", msg.Topic, msg.Partition, msg.Offset, attempt, err)
+		if config.Retry.Exhausted(attempt) {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		case <-time.After(config.Retry.Backoff(attempt)):
+		}
+	}
+}
+
+// kafkaTransactionWriter is a transactional producer on kafka-go's protocol client.
+// kafka.Writer only writes batches without a producer ID, so it encodes the record
+// batches itself and runs one transaction at a time.
+type kafkaTransactionWriter struct {
+	addr            net.Addr
+	transport       *kafka.Transport
+	client          *kafka.Client
+	transactionalID string
+	timeout         time.Duration
+	compression     kafka.Compression
+	balancer        kafka.Balancer
+
+	mu         sync.Mutex
+	producer   *kafka.ProducerSession
+	sequences  map[topicPartition]int32
+	partitions map[string][]int
+}
+
+// newTransactionWriter creates the transactional producer from the producer settings
+func (k *KafkaEventBus) newTransactionWriter() *kafkaTransactionWriter {
+	timeout := k.producer.TransactionTimeout
+	if timeout <= 0 {
+		timeout = DefaultTransactionTimeout
+	}
+
+	addr := kafka.TCP(k.brokers...)
+	transport := &kafka.Transport{}
+	return &kafkaTransactionWriter{
+		addr:            addr,
+		transport:       transport,
+		client:          &kafka.Client{Addr: addr, Transport: transport},
+		transactionalID: k.transactionalID,
+		timeout:         timeout,
+		compression:     k.producer.Compression,
+		balancer:        &kafka.Murmur2Balancer{},
+	}
+}
+
+// WriteTransaction runs a transaction, aborting it when any step fails
+func (w *kafkaTransactionWriter) WriteTransaction(ctx context.Context, group string, consumed kafka.Message, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.producer == nil {
+		if err := w.initProducer(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := w.write(ctx, group, consumed, msgs); err != nil {
+		w.abort(ctx)
+		return err
+	}
+	return nil
+}
+
+// initProducer gets the producer ID and a new epoch for the transactional ID, which
+// aborts a transaction a previous session left open
+func (w *kafkaTransactionWriter) initProducer(ctx context.Context) error {
+	res, err := w.client.InitProducerID(ctx, &kafka.InitProducerIDRequest{
+		TransactionalID:      w.transactionalID,
+		TransactionTimeoutMs: int(w.timeout.Milliseconds()),
+	})
+	if err == nil {
+		err = res.Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to init producer ID: %w", err)
+	}
+
+	w.producer = res.Producer
+	w.sequences = make(map[topicPartition]int32)
+	w.partitions = make(map[string][]int)
+	return nil
+}
+
+// abort ends the open transaction and drops the producer session, so the next transaction
+// starts with a new epoch and sequence numbers the broker expects
+func (w *kafkaTransactionWriter) abort(ctx context.Context) {
+	_, err := w.client.EndTxn(ctx, &kafka.EndTxnRequest{
+		TransactionalID: w.transactionalID,
+		ProducerID:      w.producer.ProducerID,
+		ProducerEpoch:   w.producer.ProducerEpoch,
+		Committed:       false,
+	})
+	if err != nil {
+		log.Printf("Failed to abort transaction of %s, it is aborted with the next one: %v", w.transactionalID, err)
+	}
+	w.producer = nil
+}
+
+func (w *kafkaTransactionWriter) write(ctx context.Context, group string, consumed kafka.Message, msgs []kafka.Message) error {
+	batches, err := w.partition(ctx, msgs)
+	if err != nil {
+		return err
+	}
+
+	if len(batches) > 0 {
+		topics := make(map[string][]kafka.AddPartitionToTxn)
+		for tp := range batches {
+			topics[tp.topic] = append(topics[tp.topic], kafka.AddPartitionToTxn{Partition: tp.partition})
+		}
+
+		res, err := w.client.AddPartitionsToTxn(ctx, &kafka.AddPartitionsToTxnRequest{
+			TransactionalID: w.transactionalID,
+			ProducerID:      w.producer.ProducerID,
+			ProducerEpoch:   w.producer.ProducerEpoch,
+			Topics:          topics,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add partitions to transaction: %w", err)
+		}
+		for topic, partitions := range res.Topics {
+			for _, p := range partitions {
+				if p.Error != nil {
+					return fmt.Errorf("failed to add %s[%d] to transaction: %w", topic, p.Partition, p.Error)
+				}
+			}
+		}
+
+		for tp, batch := range batches {
+			if err := w.produce(ctx, tp, batch); err != nil {
+				return err
+			}
+		}
+	}
+
+	offsets, err := w.client.AddOffsetsToTxn(ctx, &kafka.AddOffsetsToTxnRequest{
+		TransactionalID: w.transactionalID,
+		ProducerID:      w.producer.ProducerID,
+		ProducerEpoch:   w.producer.ProducerEpoch,
+		GroupID:         group,
+	})
+	if err == nil {
+		err = offsets.Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add offsets to transaction: %w", err)
+	}
+
+	// Without a generation the coordinator accepts the commit from outside the group's
+	// membership, which is kept by the reader
+	commit, err := w.client.TxnOffsetCommit(ctx, &kafka.TxnOffsetCommitRequest{
+		TransactionalID: w.transactionalID,
+		GroupID:         group,
+		ProducerID:      w.producer.ProducerID,
+		ProducerEpoch:   w.producer.ProducerEpoch,
+		GenerationID:    -1,
+		Topics: map[string][]kafka.TxnOffsetCommit{
+			consumed.Topic: {{Partition: consumed.Partition, Offset: consumed.Offset + 1}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit offset in transaction: %w", err)
+	}
+	for topic, partitions := range commit.Topics {
+		for _, p := range partitions {
+			if p.Error != nil {
+				return fmt.Errorf("failed to commit offset of %s[%d] in transaction: %w", topic, p.Partition, p.Error)
+			}
+		}
+	}
+
+	end, err := w.client.EndTxn(ctx, &kafka.EndTxnRequest{
+		TransactionalID: w.transactionalID,
+		ProducerID:      w.producer.ProducerID,
+		ProducerEpoch:   w.producer.ProducerEpoch,
+		Committed:       true,
+	})
+	if err == nil {
+		err = end.Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// partition assigns msgs to partitions the way the writer of Publish does
+func (w *kafkaTransactionWriter) partition(ctx context.Context, msgs []kafka.Message) (map[topicPartition][]kafka.Message, error) {
+	batches := make(map[topicPartition][]kafka.Message)
+	for _, msg := range msgs {
+		partitions, err := w.partitionsOf(ctx, msg.Topic)
+		if err != nil {
+			return nil, err
+		}
+
+		tp := topicPartition{topic: msg.Topic, partition: w.balancer.Balance(msg, partitions...)}
+		batches[tp] = append(batches[tp], msg)
+	}
+	return batches, nil
+}
+
+// partitionsOf returns the partitions of topic, creating it like the writer of Publish does
+func (w *kafkaTransactionWriter) partitionsOf(ctx context.Context, topic string) ([]int, error) {
+	if partitions, ok := w.partitions[topic]; ok {
+		return partitions, nil
+	}
+
+	res, err := w.transport.RoundTrip(ctx, w.addr, &metadataAPI.Request{
+		TopicNames:             []string{topic},
+		AllowAutoTopicCreation: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partitions of %s: %w", topic, err)
+	}
+
+	for _, t := range res.(*metadataAPI.Response).Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.ErrorCode != 0 {
+			return nil, fmt.Errorf("failed to read partitions of %s: %w", topic, kafka.Error(t.ErrorCode))
+		}
+
+		partitions := make([]int, len(t.Partitions))
+		for i, p := range t.Partitions {
+			partitions[i] = int(p.PartitionIndex)
+		}
+		sort.Ints(partitions)
+
+		if len(partitions) > 0 {
+			w.partitions[topic] = partitions
+			return partitions, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to read partitions of %s: %w", topic, kafka.UnknownTopicOrPartition)
+}
+
+// produce writes a batch to its partition as part of the transaction
+func (w *kafkaTransactionWriter) produce(ctx context.Context, tp topicPartition, batch []kafka.Message) error {
+	records := make([]kafka.Record, len(batch))
+	for i, msg := range batch {
+		records[i] = kafka.Record{
+			Time:    msg.Time,
+			Key:     kafka.NewBytes(msg.Key),
+			Value:   kafka.NewBytes(msg.Value),
+			Headers: msg.Headers,
+		}
+	}
+
+	sequence := w.sequences[tp]
+	set, err := transactionalRecordSet(records, w.compression, w.producer, sequence)
+	if err != nil {
+		return fmt.Errorf("failed to encode messages for %s[%d]: %w", tp.topic, tp.partition, err)
+	}
+
+	res, err := w.client.RawProduce(ctx, &kafka.RawProduceRequest{
+		Topic:           tp.topic,
+		Partition:       tp.partition,
+		RequiredAcks:    kafka.RequireAll,
+		TransactionalID: w.transactionalID,
+		RawRecords:      set,
+	})
+	if err == nil {
+		err = res.Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %d messages to %s[%d]: %w", len(batch), tp.topic, tp.partition, err)
+	}
+
+	w.sequences[tp] = sequence + int32(len(batch))
+	return nil
+}
+
+// Close closes the connections of the writer
+func (w *kafkaTransactionWriter) Close() error {
+	w.transport.CloseIdleConnections()
+	return nil
+}
+
+// Offsets into an encoded v2 record set: a 4 byte size, then the record batch header
+const (
+	recordBatchCRC           = 4 + 17
+	recordBatchAttributes    = 4 + 21
+	recordBatchProducerID    = 4 + 43
+	recordBatchProducerEpoch = 4 + 51
+	recordBatchBaseSequence  = 4 + 53
+	recordBatchHeaderEnd     = 4 + 61
+)
+
+// transactionalRecordSet encodes records as one transactional v2 record batch of producer,
+// starting at sequence. kafka-go always writes batches without a producer, so the batch is
+// encoded as usual and its producer fields and checksum are filled in afterwards.
+func transactionalRecordSet(records []kafka.Record, compression kafka.Compression, producer *kafka.ProducerSession, sequence int32) (protocol.RawRecordSet, error) {
+	set := protocol.RecordSet{
+		Version:    2,
+		Attributes: protocol.Attributes(compression) | protocol.Transactional,
+		Records:    kafka.NewRecordReader(records...),
+	}
+
+	var buf bytes.Buffer
+	if _, err := set.WriteTo(&buf); err != nil {
+		return protocol.RawRecordSet{}, err
+	}
+
+	b := buf.Bytes()
+	if len(b) < recordBatchHeaderEnd {
+		return protocol.RawRecordSet{}, fmt.Errorf("record batch of %d bytes is too short", len(b))
+	}
+	binary.BigEndian.PutUint64(b[recordBatchProducerID:], uint64(producer.ProducerID))
+	binary.BigEndian.PutUint16(b[recordBatchProducerEpoch:], uint16(producer.ProducerEpoch))
+	binary.BigEndian.PutUint32(b[recordBatchBaseSequence:], uint32(sequence))
+	binary.BigEndian.PutUint32(b[recordBatchCRC:], crc32.Checksum(b[recordBatchAttributes:], crc32.MakeTable(crc32.Castagnoli)))
+
+	return protocol.RawRecordSet{Reader: bytes.NewReader(b)}, nil
+}
+
+var (
+	_ TransactionWriter = (*kafkaTransactionWriter)(nil)
+	_ MessageWriter     = (*kafkaTransaction)(nil)
+)
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+)
+
+func TestTransactionalRecordSet(t *testing.T) {
+	producer := &kafka.ProducerSession{ProducerID: 4242, ProducerEpoch: 7}
+	at := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	records := func() []kafka.Record {
+		return []kafka.Record{
+			{Time: at, Key: kafka.NewBytes([]byte("u1")), Value: kafka.NewBytes([]byte(`{"a":1}`)), Headers: []kafka.Header{{Key: "content-type", Value: []byte("application/json")}}},
+			{Time: at, Key: kafka.NewBytes([]byte("u2")), Value: kafka.NewBytes([]byte(`{"a":2}`))},
+		}
+	}
+
+	for _, compression := range []kafka.Compression{0, kafka.Snappy} {
+		raw, err := transactionalRecordSet(records(), compression, producer, 12)
+		if err != nil {
+			t.Fatalf("transactionalRecordSet() error = %v", err)
+		}
+		encoded, err := io.ReadAll(raw.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Decoding verifies the checksum
+		var set protocol.RecordSet
+		if _, err := set.ReadFrom(bytes.NewReader(encoded)); err != nil {
+			t.Fatalf("compression %v: decoding the batch failed: %v", compression, err)
+		}
+		stream, ok := set.Records.(*protocol.RecordStream)
+		if !ok || len(stream.Records) != 1 {
+			t.Fatalf("compression %v: decoded %T, want one record batch", compression, set.Records)
+		}
+		batch, ok := stream.Records[0].(*protocol.RecordBatch)
+		if !ok {
+			t.Fatalf("compression %v: decoded %T, want a record batch", compression, stream.Records[0])
+		}
+
+		if batch.ProducerID != 4242 || batch.ProducerEpoch != 7 || batch.BaseSequence != 12 {
+			t.Errorf("compression %v: producer %d, epoch %d, sequence %d, want 4242, 7, 12", compression, batch.ProducerID, batch.ProducerEpoch, batch.BaseSequence)
+		}
+		if !batch.Attributes.Transactional() {
+			t.Errorf("compression %v: batch is not transactional", compression)
+		}
+		if batch.Attributes.Compression() != compression {
+			t.Errorf("compression %v: batch compression = %v", compression, batch.Attributes.Compression())
+		}
+
+		var keys []string
+		for {
+			record, err := batch.ReadRecord()
+			if err != nil {
+				break
+			}
+			key, _ := kafka.ReadAll(record.Key)
+			keys = append(keys, string(key))
+		}
+		if len(keys) != 2 || keys[0] != "u1" || keys[1] != "u2" {
+			t.Errorf("compression %v: records with keys %v, want [u1 u2]", compression, keys)
+		}
+	}
+}
+
+func TestTransactionalIDEnablesTransactions(t *testing.T) {
+	bus := NewKafkaEventBus([]string{"localhost:9092"}, WithTransactionalID("user-service-0"))
+	defer bus.Close()
+
+	writer, ok := bus.transactions.(*kafkaTransactionWriter)
+	if !ok {
+		t.Fatalf("transactional writer is %T", bus.transactions)
+	}
+	if writer.transactionalID != "user-service-0" || writer.timeout != DefaultTransactionTimeout {
+		t.Errorf("writer has transactional ID %q and timeout %v", writer.transactionalID, writer.timeout)
+	}
+
+	plain := NewKafkaEventBus([]string{"localhost:9092"}, WithTransactionWriter(writer))
+	defer plain.Close()
+	if plain.transactions != nil {
+		t.Error("a transaction writer without a transactional ID enables transactions")
+	}
+}
+
+func TestBusConfigTransactionalID(t *testing.T) {
+	t.Setenv("EVENT_BUS", BackendKafka)
+	t.Setenv("KAFKA_TRANSACTIONAL_ID", "user-service-0")
+
+	bus, err := NewEventBus(context.Background(), BusConfigFromEnv("user-service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bus.Close()
+
+	writer, ok := bus.(*KafkaEventBus).transactions.(*kafkaTransactionWriter)
+	if !ok || writer.transactionalID != "user-service-0" {
+		t.Errorf("KAFKA_TRANSACTIONAL_ID did not enable transactions")
+	}
+}
@@ -0,0 +1,143 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// LagReporter reports how many events of a topic a consumer group has not handled yet,
+// counting events that were delivered but not yet committed or acknowledged
+type LagReporter interface {
+	Lag(ctx context.Context, groupID, topic string) (int64, error)
+}
+
+// Lag sums the lag of the group over all partitions; partitions without a committed
+// offset count from their first offset
+func (k *KafkaEventBus) Lag(ctx context.Context, groupID, topic string) (int64, error) {
+	partitions, err := k.partitions(ctx, topic)
+	if err != nil {
+		return 0, err
+	}
+
+	client := &kafka.Client{Addr: kafka.TCP(k.brokers...)}
+
+	committed, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: groupID,
+		Topics:  map[string][]int{topic: partitions},
+	})
+	if err == nil {
+		err = committed.Error
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch offsets of group %s: %w", groupID, err)
+	}
+
+	requests := make([]kafka.OffsetRequest, 0, 2*len(partitions))
+	for _, p := range partitions {
+		requests = append(requests, kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p))
+	}
+	offsets, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{topic: requests},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list offsets of %s: %w", topic, err)
+	}
+
+	next := make(map[int]int64)
+	for _, p := range committed.Topics[topic] {
+		if p.Error != nil {
+			return 0, fmt.Errorf("failed to fetch offset of %s[%d]: %w", topic, p.Partition, p.Error)
+		}
+		next[p.Partition] = p.CommittedOffset
+	}
+
+	var lag int64
+	for _, p := range offsets.Topics[topic] {
+		if p.Error != nil {
+			return 0, fmt.Errorf("failed to list offsets of %s[%d]: %w", topic, p.Partition, p.Error)
+		}
+		start, ok := next[p.Partition]
+		if !ok || start < p.FirstOffset {
+			start = p.FirstOffset
+		}
+		lag += max(p.LastOffset-start, 0)
+	}
+	return lag, nil
+}
+
+// partitions returns the partition IDs of topic
+func (k *KafkaEventBus) partitions(ctx context.Context, topic string) ([]int, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", k.brokers[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to broker: %w", err)
+	}
+	defer conn.Close()
+
+	partitions, err := conn.ReadPartitions(topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partitions of %s: %w", topic, err)
+	}
+
+	ids := make([]int, len(partitions))
+	for i, p := range partitions {
+		ids[i] = p.ID
+	}
+	return ids, nil
+}
+
+// Lag adds the entries the group has not read yet to those it read but did not acknowledge
+func (r *RedisEventBus) Lag(ctx context.Context, groupID, topic string) (int64, error) {
+	groups, err := r.client.XInfoGroups(ctx, topic).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read groups of %s: %w", topic, err)
+	}
+
+	for _, g := range groups {
+		if g.Name == groupID {
+			return max(g.Lag, 0) + g.Pending, nil
+		}
+	}
+	return 0, fmt.Errorf("consumer group %s not found on %s", groupID, topic)
+}
+
+// Lag adds the messages pending for the group's consumer to those awaiting acknowledgement
+func (n *NATSEventBus) Lag(ctx context.Context, groupID, topic string) (int64, error) {
+	consumer, err := n.js.Consumer(ctx, n.stream, natsName(groupID+"_"+topic))
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up consumer of group %s on %s: %w", groupID, topic, err)
+	}
+
+	info, err := consumer.Info(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read consumer of group %s on %s: %w", groupID, topic, err)
+	}
+	return int64(info.NumPending) + int64(info.NumAckPending), nil
+}
+
+// Lag is the distance between the group's committed offset and the end of the log
+func (f *FileEventBus) Lag(ctx context.Context, groupID, topic string) (int64, error) {
+	l, err := f.topicLog(topic)
+	if err != nil {
+		return 0, err
+	}
+
+	end, err := l.nextOffset()
+	if err != nil {
+		return 0, err
+	}
+
+	committed, err := f.offsets.load(groupID, topic)
+	if err != nil {
+		return 0, err
+	}
+	return end - max(committed, 0), nil
+}
+
+var (
+	_ LagReporter = (*KafkaEventBus)(nil)
+	_ LagReporter = (*RedisEventBus)(nil)
+	_ LagReporter = (*NATSEventBus)(nil)
+	_ LagReporter = (*FileEventBus)(nil)
+)
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrBusClosed is returned when a bus is used after it started draining or was closed
+var ErrBusClosed = errors.New("event bus is closed")
+
+// Lifecycle is implemented by buses that own consumers and shut down gracefully
+type Lifecycle interface {
+	// Start begins consuming for every subscription registered so far;
+	// later subscriptions start consuming right away
+	Start(ctx context.Context) error
+	// Drain stops fetching new events and waits until in-flight handlers
+	// finish or ctx is done
+	Drain(ctx context.Context) error
+	// Close flushes pending writes and releases the bus's connections
+	Close() error
+}
+
+type busState int
+
+const (
+	busIdle busState = iota
+	busRunning
+	busDraining
+	busClosed
+)
+
+// Shutdown drains bus until ctx is done and then closes it, reporting the errors of both steps
+func Shutdown(ctx context.Context, bus Lifecycle) error {
+	return errors.Join(bus.Drain(ctx), bus.Close())
+}
+
+// waitGroupDone waits for wg until ctx is done
+func waitGroupDone(ctx context.Context, wg interface{ Wait() }) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var (
+	_ Lifecycle = (*KafkaEventBus)(nil)
+	_ Lifecycle = (*FileEventBus)(nil)
+	_ Lifecycle = (*MemoryEventBus)(nil)
+)
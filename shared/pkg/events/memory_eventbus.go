@@ -2,58 +2,231 @@ package events
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"sync"
+
+	"shared/pkg/tracing"
 )
 
-// MemoryEventBus implements EventBus interface using in-memory storage
+// MemoryEventBus implements EventBus interface using in-memory storage. Every topic or
+// pattern with subscribers gets a bounded pool of workers, so a burst of events is queued
+// instead of spawning a goroutine per event.
+//
+// Subscriptions honour their retry policy and dead-letter setting like the broker-backed
+// buses: a failing handler is retried in place, and an event it still fails on is
+// published to the dead-letter topic of its topic on the same bus with the x-dlq-*
+// headers. Nothing is stored, so dead letters nobody subscribed to are only logged.
 type MemoryEventBus struct {
-	subscribers map[string][]EventHandler
-	mu          sync.RWMutex
+	subscribers map[string][]memorySubscriber // by topic or pattern
+	pools       map[string]*topicPool
+	middleware  []EventMiddleware
+
+	workers     int
+	queueSize   int
+	overflow    OverflowPolicy
+	ordered     bool
+	synchronous bool
+
+	state    busState
+	inFlight inFlightCounter
+	done     chan struct{}
+	mu       sync.RWMutex
+}
+
+// memorySubscriber is one handler subscribed to a topic or pattern
+type memorySubscriber struct {
+	handler EventHandler
+	config  SubscribeConfig
+}
+
+// inFlightCounter counts queued and running deliveries. Unlike a sync.WaitGroup it may
+// grow while Drain waits, so handlers can publish follow-up events during a drain.
+type inFlightCounter struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	count int
+}
+
+func (c *inFlightCounter) Add(delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.count += delta
+	if c.count <= 0 && c.cond != nil {
+		c.cond.Broadcast()
+	}
+}
+
+func (c *inFlightCounter) Done() {
+	c.Add(-1)
+}
+
+// Wait blocks until no delivery is queued or running
+func (c *inFlightCounter) Wait() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cond == nil {
+		c.cond = sync.NewCond(&c.mu)
+	}
+	for c.count > 0 {
+		c.cond.Wait()
+	}
 }
 
 // NewMemoryEventBus creates a new in-memory event bus
-func NewMemoryEventBus() *MemoryEventBus {
-	return &MemoryEventBus{
-		subscribers: make(map[string][]EventHandler),
+func NewMemoryEventBus(opts ...MemoryOption) *MemoryEventBus {
+	bus := &MemoryEventBus{
+		subscribers: make(map[string][]memorySubscriber),
+		pools:       make(map[string]*topicPool),
+		workers:     DefaultMemoryWorkers,
+		queueSize:   DefaultMemoryQueueSize,
+		overflow:    OverflowBlock,
+		state:       busRunning,
+		done:        make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(bus)
+	}
+
+	if bus.workers <= 0 {
+		bus.workers = 1
+	}
+	if bus.queueSize < 0 {
+		bus.queueSize = 0
 	}
+
+	return bus
 }
 
-// Publish sends an event to all subscribers of the topic
+// Publish queues an event for all subscribers of the topic and all pattern subscriptions
+// matching the topic or event type. When a subscription's queue is full it blocks or
+// fails with ErrQueueFull, depending on the overflow policy; subscriptions queued before
+// the full one still receive the event. Publishing keeps working while the bus drains, so
+// handlers can still emit events, and fails with ErrBusClosed once it is closed.
 func (m *MemoryEventBus) Publish(ctx context.Context, topic string, event *Event) error {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
+	if m.state == busClosed {
+		m.mu.RUnlock()
+		return ErrBusClosed
+	}
+
+	ctx, span := tracing.StartProducerSpan(ctx, "memory", topic)
+	defer span.End()
+
+	injectMetadata(ctx, event)
+
+	// Every subscription, exact or pattern, gets its own delivery on its own pool
+	var deliveries []memoryDelivery
+	var pools []*topicPool
+	for subscription, subscribers := range m.subscribers {
+		if IsTopicPattern(subscription) && !patternReads(subscription, topic) {
+			continue
+		}
+		if !subscriptionMatches(subscription, topic, event) {
+			continue
+		}
 
-	handlers, exists := m.subscribers[topic]
-	if !exists {
+		delivery := memoryDelivery{topic: topic, event: event, subscribers: make([]memorySubscriber, len(subscribers))}
+		for i, subscriber := range subscribers {
+			delivery.subscribers[i] = memorySubscriber{handler: m.wrap(subscriber.handler), config: subscriber.config}
+		}
+		deliveries = append(deliveries, delivery)
+		pools = append(pools, m.pools[subscription])
+	}
+	if len(deliveries) == 0 {
+		m.mu.RUnlock()
 		return nil // No subscribers for this topic
 	}
 
-	// Execute handlers concurrently
-	for _, handler := range handlers {
-		go func(h EventHandler) {
-			if err := h(ctx, event); err != nil {
-				log.Printf("Error handling event %s: %v", event.Type, err)
-			}
-		}(handler)
+	// Counted while holding the lock, so Drain cannot start waiting before these deliveries are tracked
+	m.inFlight.Add(len(deliveries))
+	m.mu.RUnlock()
+
+	for i, delivery := range deliveries {
+		if m.synchronous {
+			m.deliver(delivery)
+			continue
+		}
+
+		if err := pools[i].enqueue(ctx, delivery, m.overflow, m.done); err != nil {
+			m.inFlight.Add(i - len(deliveries))
+			span.RecordError(err)
+			return fmt.Errorf("failed to publish event: %w", err)
+		}
 	}
 
 	log.Printf("Event published: %s to topic: %s", event.Type, topic)
 	return nil
 }
 
-// Subscribe registers a handler for a topic
-func (m *MemoryEventBus) Subscribe(ctx context.Context, topic string, handler EventHandler) error {
+// Use appends middleware applied to every handler invocation, after built-in panic recovery
+func (m *MemoryEventBus) Use(middleware ...EventMiddleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.middleware = append(m.middleware, middleware...)
+}
+
+// wrap applies panic recovery and the configured middleware; callers hold m.mu
+func (m *MemoryEventBus) wrap(handler EventHandler) EventHandler {
+	return Chain(Chain(handler, m.middleware...), Recover())
+}
+
+// Subscribe registers a handler for a topic, or for every topic and event type matching
+// a pattern such as "user.*" or "*.upgraded". Handlers of the same topic or pattern share
+// a worker pool. Consumer group and broker settings of opts do not apply to the memory bus.
+func (m *MemoryEventBus) Subscribe(ctx context.Context, topic string, handler EventHandler, opts ...SubscribeOption) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.subscribers[topic] = append(m.subscribers[topic], handler)
+	if m.state != busRunning {
+		return ErrBusClosed
+	}
+
+	m.subscribers[topic] = append(m.subscribers[topic], memorySubscriber{handler: handler, config: newSubscribeConfig(opts...)})
+	if _, exists := m.pools[topic]; !exists && !m.synchronous {
+		pool := newTopicPool(m.workers, m.queueSize, m.ordered)
+		pool.start(m.workers, m.done, m.deliver)
+		m.pools[topic] = pool
+	}
 	log.Printf("Handler subscribed to topic: %s", topic)
 	return nil
 }
 
-// Close cleans up the event bus (no-op for memory bus)
+// Start is a no-op; the memory bus delivers events as soon as they are published
+func (m *MemoryEventBus) Start(ctx context.Context) error {
+	return nil
+}
+
+// Drain rejects further subscriptions and waits until queued and in-flight events are
+// handled or ctx is done, including events handlers publish meanwhile
+func (m *MemoryEventBus) Drain(ctx context.Context) error {
+	m.mu.Lock()
+	if m.state == busRunning {
+		m.state = busDraining
+	}
+	m.mu.Unlock()
+
+	if err := waitGroupDone(ctx, &m.inFlight); err != nil {
+		return fmt.Errorf("failed to drain memory event bus: %w", err)
+	}
+	return nil
+}
+
+// Close rejects further publishes and stops the workers, dropping queued events;
+// it does not wait for in-flight handlers, use Drain for that
 func (m *MemoryEventBus) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state == busClosed {
+		return nil
+	}
+	m.state = busClosed
+	close(m.done)
 	log.Println("Memory event bus closed")
 	return nil
 }
@@ -0,0 +1,363 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"shared/pkg/events"
+)
+
+func TestMemoryEventBusDrainKeepsPublishing(t *testing.T) {
+	bus := events.NewMemoryEventBus()
+	ctx := context.Background()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	first := func(ctx context.Context, event *events.Event) error {
+		close(entered)
+		<-release
+		return bus.Publish(ctx, "follow-ups", event)
+	}
+	var followUps atomic.Int32
+	second := func(ctx context.Context, event *events.Event) error {
+		followUps.Add(1)
+		return nil
+	}
+	if err := bus.Subscribe(ctx, "user-events", first); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Subscribe(ctx, "follow-ups", second); err != nil {
+		t.Fatal(err)
+	}
+	publishTestEvent(t, bus, "user-events")
+	<-entered
+
+	drained := make(chan error, 1)
+	go func() { drained <- bus.Drain(ctx) }()
+
+	waitFor(t, "Subscribe to be rejected", func() bool {
+		return errors.Is(bus.Subscribe(ctx, "late", second), events.ErrBusClosed)
+	})
+	close(release)
+
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Fatalf("Drain() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Drain() did not return")
+	}
+	if got := followUps.Load(); got != 1 {
+		t.Errorf("event published by a handler during the drain was handled %d times, want 1", got)
+	}
+
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+	event, err := events.NewEvent(events.UserQuotasResetEvent, "test", "1.0", events.UserQuotasResetData{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Publish(ctx, "user-events", event); !errors.Is(err, events.ErrBusClosed) {
+		t.Errorf("Publish() after Close error = %v, want ErrBusClosed", err)
+	}
+}
+
+func TestMemoryEventBusRetriesThenDeadLetters(t *testing.T) {
+	bus := events.NewMemoryEventBus(events.WithSynchronousDelivery())
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	handler := func(ctx context.Context, event *events.Event) error {
+		calls.Add(1)
+		return errors.New("quota service unavailable")
+	}
+	var dead []*events.Event
+	dlqHandler := func(ctx context.Context, event *events.Event) error {
+		dead = append(dead, event)
+		return nil
+	}
+	if err := bus.Subscribe(ctx, "user-events", handler, events.WithRetryPolicy(fastRetry)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Subscribe(ctx, events.DeadLetterTopic("user-events"), dlqHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	event := publishTestEvent(t, bus, "user-events")
+	if got := calls.Load(); got != int32(fastRetry.MaxAttempts) {
+		t.Errorf("handler called %d times, want %d", got, fastRetry.MaxAttempts)
+	}
+	if len(dead) != 1 {
+		t.Fatalf("dead-letter topic got %d events, want 1", len(dead))
+	}
+	if dead[0].ID != event.ID {
+		t.Errorf("dead-lettered event %s, want %s", dead[0].ID, event.ID)
+	}
+	if got := dead[0].Headers[events.DeadLetterAttemptsHeader]; got != "3" {
+		t.Errorf("header %s = %q, want 3", events.DeadLetterAttemptsHeader, got)
+	}
+	if got := dead[0].Headers[events.DeadLetterSourceTopicHeader]; got != "user-events" {
+		t.Errorf("header %s = %q, want user-events", events.DeadLetterSourceTopicHeader, got)
+	}
+}
+
+func TestMemoryEventBusWithoutDeadLetterDropsFailures(t *testing.T) {
+	bus := events.NewMemoryEventBus(events.WithSynchronousDelivery())
+	ctx := context.Background()
+
+	handler := func(ctx context.Context, event *events.Event) error {
+		return events.DeadLetter(errors.New("invalid payload"))
+	}
+	var dead atomic.Int32
+	dlqHandler := func(ctx context.Context, event *events.Event) error {
+		dead.Add(1)
+		return nil
+	}
+	if err := bus.Subscribe(ctx, "user-events", handler, events.WithDeadLetter(false)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Subscribe(ctx, events.DeadLetterTopic("user-events"), dlqHandler); err != nil {
+		t.Fatal(err)
+	}
+
+	publishTestEvent(t, bus, "user-events")
+	if got := dead.Load(); got != 0 {
+		t.Errorf("dead-letter topic got %d events with dead-lettering disabled", got)
+	}
+}
+
+func TestMemoryEventBusPatternsSkipDeadLetterTopics(t *testing.T) {
+	bus := events.NewMemoryEventBus(events.WithSynchronousDelivery())
+	ctx := context.Background()
+
+	handler := func(ctx context.Context, event *events.Event) error {
+		return events.DeadLetter(errors.New("invalid payload"))
+	}
+	// Each subscription records the error of the dead letters it receives, "" for events
+	record := func(received *[]string) events.EventHandler {
+		return func(ctx context.Context, event *events.Event) error {
+			*received = append(*received, event.Header(events.DeadLetterErrorHeader))
+			return nil
+		}
+	}
+	var all, user, dead []string
+	if err := bus.Subscribe(ctx, "user-events", handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Subscribe(ctx, "*", record(&all)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Subscribe(ctx, "user.*", record(&user)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Subscribe(ctx, "*.dlq", record(&dead)); err != nil {
+		t.Fatal(err)
+	}
+
+	publishTestEvent(t, bus, "user-events")
+	if len(all) != 1 || all[0] != "" || len(user) != 1 || user[0] != "" {
+		t.Errorf("pattern subscriptions received %q and %q, want the event once each", all, user)
+	}
+	if len(dead) != 1 || dead[0] != "invalid payload" {
+		t.Errorf("dead-letter pattern received %q, want the dead letter once", dead)
+	}
+}
+
+func TestMemoryEventBusOrderedDeliveryKeepsPerKeyOrder(t *testing.T) {
+	bus := events.NewMemoryEventBus(events.WithOrderedDelivery(), events.WithWorkers(4), events.WithQueueSize(8))
+	ctx := context.Background()
+	const users, perUser = 8, 50
+
+	var mu sync.Mutex
+	handled := make(map[string][]int)
+	running := make(map[string]bool)
+	var overlapped atomic.Bool
+	handler := func(ctx context.Context, event *events.Event) error {
+		mu.Lock()
+		if running[event.Subject] {
+			overlapped.Store(true)
+		}
+		running[event.Subject] = true
+		mu.Unlock()
+
+		time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+		seq, err := strconv.Atoi(event.Header("seq"))
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		running[event.Subject] = false
+		handled[event.Subject] = append(handled[event.Subject], seq)
+		return nil
+	}
+	if err := bus.Subscribe(ctx, "user-events", handler); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every user's events are published in order by its own goroutine, all at once
+	var wg sync.WaitGroup
+	for u := 0; u < users; u++ {
+		userID := fmt.Sprintf("u%d", u)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seq := 0; seq < perUser; seq++ {
+				event, err := events.NewEvent(events.UserQuotasResetEvent, "test", "1.0", events.UserQuotasResetData{UserID: userID})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				event.Subject = userID
+				event.SetHeader("seq", strconv.Itoa(seq))
+				if err := bus.Publish(ctx, "user-events", event); err != nil {
+					t.Errorf("Publish() error = %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	drain(t, bus)
+
+	if overlapped.Load() {
+		t.Error("events of one user were handled concurrently")
+	}
+	for u := 0; u < users; u++ {
+		userID := fmt.Sprintf("u%d", u)
+		seqs := handled[userID]
+		if len(seqs) != perUser || !sort.IntsAreSorted(seqs) {
+			t.Errorf("events of %s handled in order %v, want 0 to %d", userID, seqs, perUser-1)
+		}
+	}
+}
+
+// newBlockedMemoryBus returns a bus with one worker and a queue of one whose handler is
+// busy with a first event until release is closed, so the next event fills the queue
+func newBlockedMemoryBus(t *testing.T, policy events.OverflowPolicy) (*events.MemoryEventBus, *atomic.Int32, chan struct{}) {
+	t.Helper()
+
+	bus := events.NewMemoryEventBus(events.WithWorkers(1), events.WithQueueSize(1), events.WithOverflowPolicy(policy))
+	t.Cleanup(func() { bus.Close() })
+
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var handled atomic.Int32
+	handler := func(ctx context.Context, event *events.Event) error {
+		select {
+		case entered <- struct{}{}:
+		default:
+		}
+		<-release
+		handled.Add(1)
+		return nil
+	}
+	if err := bus.Subscribe(context.Background(), "user-events", handler); err != nil {
+		t.Fatal(err)
+	}
+
+	publishTestEvent(t, bus, "user-events")
+	<-entered
+	publishTestEvent(t, bus, "user-events")
+	return bus, &handled, release
+}
+
+func TestMemoryEventBusOverflowRejectFailsWhenQueueIsFull(t *testing.T) {
+	bus, handled, release := newBlockedMemoryBus(t, events.OverflowReject)
+
+	event, err := events.NewEvent(events.UserQuotasResetEvent, "test", "1.0", events.UserQuotasResetData{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Publish(context.Background(), "user-events", event); !errors.Is(err, events.ErrQueueFull) {
+		t.Errorf("Publish() to a full queue error = %v, want ErrQueueFull", err)
+	}
+
+	close(release)
+	drain(t, bus)
+	if got := handled.Load(); got != 2 {
+		t.Errorf("handled %d events, want the 2 that were queued", got)
+	}
+}
+
+func TestMemoryEventBusOverflowBlockWaitsForRoom(t *testing.T) {
+	bus, handled, release := newBlockedMemoryBus(t, events.OverflowBlock)
+	newEvent := func() *events.Event {
+		event, err := events.NewEvent(events.UserQuotasResetEvent, "test", "1.0", events.UserQuotasResetData{UserID: "u1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+
+	// A publisher gives up when its context is done before there is room
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bus.Publish(ctx, "user-events", newEvent()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Publish() to a full queue error = %v, want the context's deadline", err)
+	}
+
+	published := make(chan error, 1)
+	go func() { published <- bus.Publish(context.Background(), "user-events", newEvent()) }()
+	select {
+	case err := <-published:
+		t.Fatalf("Publish() to a full queue returned %v before there was room", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-published:
+		if err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish() did not return once there was room")
+	}
+	drain(t, bus)
+	if got := handled.Load(); got != 3 {
+		t.Errorf("handled %d events, want the 3 that were queued", got)
+	}
+}
+
+func TestMemoryEventBusDrainWaitsForInFlightHandlers(t *testing.T) {
+	bus, handled, release := newBlockedMemoryBus(t, events.OverflowBlock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bus.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain() with a handler running error = %v, want the context's deadline", err)
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- bus.Drain(context.Background()) }()
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain() returned %v while a handler was running", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Fatalf("Drain() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Drain() did not return")
+	}
+	if got := handled.Load(); got != 2 {
+		t.Errorf("Drain() returned after %d of 2 events were handled", got)
+	}
+}
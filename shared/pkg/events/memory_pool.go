@@ -0,0 +1,180 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log"
+	"strings"
+
+	"shared/pkg/tracing"
+)
+
+// ErrQueueFull is returned by a MemoryEventBus using OverflowReject when a topic's queue is full
+var ErrQueueFull = errors.New("event queue is full")
+
+// OverflowPolicy decides what Publish does when a topic's queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes Publish wait for room in the queue until its context is done
+	OverflowBlock OverflowPolicy = iota
+	// OverflowReject makes Publish fail right away with ErrQueueFull
+	OverflowReject
+)
+
+// Defaults of the MemoryEventBus worker pool
+const (
+	DefaultMemoryWorkers   = 8
+	DefaultMemoryQueueSize = 1024
+)
+
+// MemoryOption configures a MemoryEventBus
+type MemoryOption func(*MemoryEventBus)
+
+// WithWorkers sets how many workers handle the events of each topic
+func WithWorkers(workers int) MemoryOption {
+	return func(m *MemoryEventBus) {
+		m.workers = workers
+	}
+}
+
+// WithQueueSize sets how many events may wait per queue before the overflow policy applies
+func WithQueueSize(size int) MemoryOption {
+	return func(m *MemoryEventBus) {
+		m.queueSize = size
+	}
+}
+
+// WithOverflowPolicy sets what Publish does when a topic's queue is full
+func WithOverflowPolicy(policy OverflowPolicy) MemoryOption {
+	return func(m *MemoryEventBus) {
+		m.overflow = policy
+	}
+}
+
+// WithOrderedDelivery gives every worker its own queue and routes events by aggregate ID
+// (Subject), so events of one aggregate are handled one at a time in publish order
+func WithOrderedDelivery() MemoryOption {
+	return func(m *MemoryEventBus) {
+		m.ordered = true
+	}
+}
+
+// WithSynchronousDelivery runs handlers inside Publish, one after the other, before it
+// returns; meant for deterministic unit tests
+func WithSynchronousDelivery() MemoryOption {
+	return func(m *MemoryEventBus) {
+		m.synchronous = true
+	}
+}
+
+// memoryDelivery is one published event waiting to be handled by every subscriber of its topic
+type memoryDelivery struct {
+	topic       string
+	event       *Event
+	subscribers []memorySubscriber
+}
+
+// topicPool is the set of workers handling the events of one topic. Unordered pools share
+// a single queue between all workers; ordered pools have one queue per worker.
+type topicPool struct {
+	queues []chan memoryDelivery
+}
+
+func newTopicPool(workers, queueSize int, ordered bool) *topicPool {
+	queues := 1
+	if ordered {
+		queues = workers
+	}
+
+	pool := &topicPool{queues: make([]chan memoryDelivery, queues)}
+	for i := range pool.queues {
+		pool.queues[i] = make(chan memoryDelivery, queueSize)
+	}
+	return pool
+}
+
+// start runs the workers of the pool until done is closed
+func (p *topicPool) start(workers int, done <-chan struct{}, deliver func(memoryDelivery)) {
+	for i := 0; i < workers; i++ {
+		queue := p.queues[i%len(p.queues)]
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				case d := <-queue:
+					deliver(d)
+				}
+			}
+		}()
+	}
+}
+
+// queue returns the queue an event goes to; events with the same key share a queue
+func (p *topicPool) queue(event *Event) chan memoryDelivery {
+	if len(p.queues) == 1 {
+		return p.queues[0]
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(partitionKey(event)))
+	return p.queues[h.Sum32()%uint32(len(p.queues))]
+}
+
+// enqueue hands d to the pool according to policy
+func (p *topicPool) enqueue(ctx context.Context, d memoryDelivery, policy OverflowPolicy, done <-chan struct{}) error {
+	queue := p.queue(d.event)
+
+	if policy == OverflowReject {
+		select {
+		case queue <- d:
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	}
+
+	select {
+	case queue <- d:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return ErrBusClosed
+	}
+}
+
+// deliver runs every handler of d with its subscription's retry policy, detached from
+// the publisher's context like they would be behind a real broker
+func (m *MemoryEventBus) deliver(d memoryDelivery) {
+	defer m.inFlight.Done()
+
+	for _, subscriber := range d.subscribers {
+		handlerCtx, span := tracing.StartConsumerSpan(extractMetadata(context.Background(), d.event), "memory", d.topic)
+		attempts, err := handleWithRetry(handlerCtx, subscriber.config.Retry, subscriber.handler, d.event)
+		endSpan(span, err)
+
+		if err != nil {
+			log.Printf("Error handling event %s after %d attempts: %v", d.event.ID, attempts, err)
+			m.deadLetter(d, subscriber.config, attempts, err)
+		}
+	}
+}
+
+// deadLetter publishes an event a handler failed on to the dead-letter topic of its topic.
+// Events of dead-letter topics are dropped rather than dead-lettered again.
+func (m *MemoryEventBus) deadLetter(d memoryDelivery, config SubscribeConfig, attempts int, cause error) {
+	if !config.DeadLetter || strings.HasSuffix(d.topic, deadLetterTopicSuffix) {
+		log.Printf("Dropping event %s of %s: %v", d.event.ID, d.topic, cause)
+		return
+	}
+
+	dlqEvent := newDeadLetterEvent(d.event, d.topic, 0, d.event.ID, attempts, cause)
+	if err := m.Publish(context.Background(), DeadLetterTopic(d.topic), dlqEvent); err != nil {
+		log.Printf("Failed to route event %s to dead-letter topic %s: %v", d.event.ID, DeadLetterTopic(d.topic), err)
+		return
+	}
+	log.Printf("Event %s of %s routed to %s", d.event.ID, d.topic, DeadLetterTopic(d.topic))
+}
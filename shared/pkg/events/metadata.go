@@ -0,0 +1,112 @@
+package events
+
+import (
+	"context"
+
+	"shared/pkg/tracing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Well-known Event headers
+const (
+	HeaderTraceParent   = "traceparent"
+	HeaderTraceState    = "tracestate"
+	HeaderCorrelationID = "correlationid"
+	HeaderCausationID   = "causationid"
+	HeaderSagaID        = "sagaid"
+)
+
+// SetHeader sets a metadata header on the event
+func (e *Event) SetHeader(key, value string) {
+	if e.Headers == nil {
+		e.Headers = make(map[string]string)
+	}
+	e.Headers[key] = value
+}
+
+// Header returns a metadata header of the event
+func (e *Event) Header(key string) string {
+	return e.Headers[key]
+}
+
+// CorrelationID returns the ID shared by every event caused by the same request
+func (e *Event) CorrelationID() string {
+	return e.Header(HeaderCorrelationID)
+}
+
+// CausationID returns the ID of the event that caused this one
+func (e *Event) CausationID() string {
+	return e.Header(HeaderCausationID)
+}
+
+// SagaID returns the ID of the saga the event takes part in, if any
+func (e *Event) SagaID() string {
+	return e.Header(HeaderSagaID)
+}
+
+type handledEventKey struct{}
+
+// ContextWithEvent marks event as the one being handled, so events published
+// with the returned context are correlated with it
+func ContextWithEvent(ctx context.Context, event *Event) context.Context {
+	return context.WithValue(ctx, handledEventKey{}, event)
+}
+
+// EventFromContext returns the event being handled, if any
+func EventFromContext(ctx context.Context) (*Event, bool) {
+	event, ok := ctx.Value(handledEventKey{}).(*Event)
+	return event, ok
+}
+
+// injectMetadata stamps trace context, correlation, causation and saga headers onto an outgoing event
+func injectMetadata(ctx context.Context, event *Event) {
+	if event.Headers == nil {
+		event.Headers = make(map[string]string)
+	}
+
+	tracing.InjectKafkaTrace(ctx, event.Headers)
+
+	cause, hasCause := EventFromContext(ctx)
+	if hasCause && event.CausationID() == "" {
+		event.SetHeader(HeaderCausationID, cause.ID)
+	}
+
+	if event.SagaID() == "" {
+		if sagaID, ok := sagaIDFromContext(ctx); ok {
+			event.SetHeader(HeaderSagaID, sagaID)
+		} else if hasCause && cause.SagaID() != "" {
+			event.SetHeader(HeaderSagaID, cause.SagaID())
+		}
+	}
+
+	if event.CorrelationID() == "" {
+		correlationID := tracing.CorrelationIDFromContext(ctx)
+		if correlationID == "" && hasCause {
+			correlationID = cause.CorrelationID()
+		}
+		if correlationID == "" {
+			correlationID = event.ID
+		}
+		event.SetHeader(HeaderCorrelationID, correlationID)
+	}
+}
+
+// extractMetadata continues the trace and correlation carried by an incoming event
+func extractMetadata(ctx context.Context, event *Event) context.Context {
+	ctx = tracing.ExtractKafkaTrace(ctx, event.Headers)
+	if correlationID := event.CorrelationID(); correlationID != "" {
+		ctx = tracing.ContextWithCorrelationID(ctx, correlationID)
+	}
+	return ContextWithEvent(ctx, event)
+}
+
+// endSpan records the handler outcome on span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
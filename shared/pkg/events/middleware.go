@@ -0,0 +1,143 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EventMiddleware wraps an EventHandler with cross-cutting behavior
+type EventMiddleware func(EventHandler) EventHandler
+
+// Chain applies middleware to handler so that the first middleware is the outermost
+func Chain(handler EventHandler, middleware ...EventMiddleware) EventHandler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// Recover turns a panicking handler into an error instead of crashing the service
+func Recover() EventMiddleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *Event) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Recovered from panic handling event %s (%s): %v\n%s", event.ID, event.Type, r, debug.Stack())
+					err = fmt.Errorf("panic handling event %s: %v", event.ID, r)
+				}
+			}()
+			return next(ctx, event)
+		}
+	}
+}
+
+// Timeout bounds each handler invocation by d. Handlers must honor ctx cancellation.
+func Timeout(d time.Duration) EventMiddleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *Event) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, event)
+		}
+	}
+}
+
+// Logging logs every handled event with its ID, type, duration and outcome
+func Logging() EventMiddleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *Event) error {
+			start := time.Now()
+			err := next(ctx, event)
+			if err != nil {
+				log.Printf("Event %s (%s) failed after %s: %v", event.ID, event.Type, time.Since(start), err)
+				return err
+			}
+
+			log.Printf("Event %s (%s) handled in %s", event.ID, event.Type, time.Since(start))
+			return nil
+		}
+	}
+}
+
+// Metrics records handled event counts and handler latency per event type and outcome
+func Metrics() EventMiddleware {
+	meter := otel.Meter("shared/events")
+
+	handled, err := meter.Int64Counter("events.handled",
+		metric.WithDescription("Number of events handled"),
+	)
+	if err != nil {
+		log.Printf("Failed to create events.handled counter: %v", err)
+	}
+
+	duration, err := meter.Float64Histogram("events.handler.duration",
+		metric.WithDescription("Event handler latency"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		log.Printf("Failed to create events.handler.duration histogram: %v", err)
+	}
+
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *Event) error {
+			start := time.Now()
+			err := next(ctx, event)
+
+			outcome := "success"
+			if err != nil {
+				outcome = "error"
+			}
+			attributes := metric.WithAttributes(
+				attribute.String("event.type", event.Type),
+				attribute.String("outcome", outcome),
+			)
+
+			if handled != nil {
+				handled.Add(ctx, 1, attributes)
+			}
+			if duration != nil {
+				duration.Record(ctx, time.Since(start).Seconds(), attributes)
+			}
+			return err
+		}
+	}
+}
+
+// Trace wraps each handler invocation in a span carrying the event ID and type
+func Trace() EventMiddleware {
+	tracer := otel.Tracer("shared/events")
+
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *Event) error {
+			ctx, span := tracer.Start(ctx, "handle "+event.Type,
+				trace.WithAttributes(
+					attribute.String("event.id", event.ID),
+					attribute.String("event.type", event.Type),
+					attribute.String("event.source", event.Source),
+				),
+			)
+
+			err := next(ctx, event)
+			endSpan(span, err)
+			return err
+		}
+	}
+}
+
+// Retry retries a failing handler in place according to policy
+func Retry(policy RetryPolicy) EventMiddleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, event *Event) error {
+			_, err := handleWithRetry(ctx, policy, next, event)
+			return err
+		}
+	}
+}
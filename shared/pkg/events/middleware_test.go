@@ -0,0 +1,140 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"shared/pkg/events"
+)
+
+// recording returns middleware that records when it enters and leaves the handler
+func recording(calls *[]string, name string) events.EventMiddleware {
+	return func(next events.EventHandler) events.EventHandler {
+		return func(ctx context.Context, event *events.Event) error {
+			*calls = append(*calls, name+" before")
+			err := next(ctx, event)
+			*calls = append(*calls, name+" after")
+			return err
+		}
+	}
+}
+
+func newMiddlewareEvent(t *testing.T) *events.Event {
+	t.Helper()
+
+	event, err := events.NewEvent(events.UserQuotasResetEvent, "test", "1.0", events.UserQuotasResetData{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestChainAppliesFirstMiddlewareOutermost(t *testing.T) {
+	var calls []string
+	handler := func(ctx context.Context, event *events.Event) error {
+		calls = append(calls, "handler")
+		return nil
+	}
+
+	chained := events.Chain(handler, recording(&calls, "first"), recording(&calls, "second"))
+	if err := chained(context.Background(), newMiddlewareEvent(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"first before", "second before", "handler", "second after", "first after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("ran %v, want %v", calls, want)
+	}
+}
+
+func TestRecoverTurnsPanicsIntoErrors(t *testing.T) {
+	handler := func(ctx context.Context, event *events.Event) error {
+		panic("nil quota")
+	}
+	event := newMiddlewareEvent(t)
+
+	err := events.Chain(handler, events.Recover())(context.Background(), event)
+	if err == nil || !strings.Contains(err.Error(), "panic handling event "+event.ID) || !strings.Contains(err.Error(), "nil quota") {
+		t.Errorf("error = %v, want the panic of event %s", err, event.ID)
+	}
+}
+
+func TestTimeoutCancelsSlowHandlers(t *testing.T) {
+	handler := func(ctx context.Context, event *events.Event) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("handler context has no deadline")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}
+
+	start := time.Now()
+	err := events.Chain(handler, events.Timeout(20*time.Millisecond))(context.Background(), newMiddlewareEvent(t))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the deadline to be exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("handler ran for %s past its timeout", elapsed)
+	}
+}
+
+func TestRetryRetriesFailingHandlers(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "succeeds after failures", failures: 2, err: errors.New("quota service unavailable"), wantCalls: 3},
+		{name: "gives up when exhausted", failures: 5, err: errors.New("quota service unavailable"), wantCalls: 3, wantErr: true},
+		{name: "does not retry dead letters", failures: 5, err: events.DeadLetter(errors.New("invalid payload")), wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			handler := func(ctx context.Context, event *events.Event) error {
+				calls++
+				if calls <= tt.failures {
+					return tt.err
+				}
+				return nil
+			}
+
+			err := events.Chain(handler, events.Retry(fastRetry))(context.Background(), newMiddlewareEvent(t))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want an error: %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRouterRunsHandlerMiddlewareInsideRouterMiddleware(t *testing.T) {
+	var calls []string
+	router := events.NewRouter(events.WithRouterMiddleware(recording(&calls, "router")))
+	events.On(router, events.UserQuotasResetEvent, func(ctx context.Context, data events.UserQuotasResetData) error {
+		calls = append(calls, "handler")
+		return nil
+	}, recording(&calls, "per-handler"))
+
+	if err := router.Handle(context.Background(), newMiddlewareEvent(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"router before", "per-handler before", "handler", "per-handler after", "router after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("ran %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,476 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shared/pkg/tracing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Defaults of the NATSEventBus
+const (
+	DefaultNATSStream        = "SMM_EVENTS"
+	DefaultNATSSubjectPrefix = "events"
+	DefaultNATSBatchSize     = 100
+	DefaultNATSFetchWait     = time.Second
+	DefaultNATSAckWait       = time.Minute
+)
+
+// NATSEventBus implements EventBus on NATS JetStream. All topics live in one stream, each
+// under the subject "<prefix>.<topic>"; consumer groups are durable pull consumers that
+// acknowledge a message once it was handled or dead-lettered. Messages that are not
+// acknowledged within the ack wait, e.g. because the consumer crashed, are redelivered.
+type NATSEventBus struct {
+	conn          *nats.Conn
+	js            jetstream.JetStream
+	stream        string
+	subjectPrefix string
+	groupID       string
+	batchSize     int
+	fetchWait     time.Duration
+	ackWait       time.Duration
+	middleware    []EventMiddleware
+
+	state        busState
+	pending      []subscription
+	stopFetching []context.CancelFunc
+	consumers    sync.WaitGroup
+	mu           sync.RWMutex
+}
+
+// NATSOption configures a NATSEventBus
+type NATSOption func(*NATSEventBus)
+
+// WithNATSStream sets the JetStream stream and the subject prefix of its topics
+func WithNATSStream(stream, subjectPrefix string) NATSOption {
+	return func(n *NATSEventBus) {
+		n.stream = stream
+		n.subjectPrefix = subjectPrefix
+	}
+}
+
+// WithNATSConsumerGroup sets the default consumer group for subscriptions, usually the service name
+func WithNATSConsumerGroup(groupID string) NATSOption {
+	return func(n *NATSEventBus) {
+		n.groupID = groupID
+	}
+}
+
+// WithNATSAckWait sets how long a delivered message may stay unacknowledged before it is redelivered
+func WithNATSAckWait(d time.Duration) NATSOption {
+	return func(n *NATSEventBus) {
+		n.ackWait = d
+	}
+}
+
+// NewNATSEventBus creates an event bus on JetStream, creating or updating its stream;
+// Close closes conn
+func NewNATSEventBus(ctx context.Context, conn *nats.Conn, opts ...NATSOption) (*NATSEventBus, error) {
+	bus := &NATSEventBus{
+		conn:          conn,
+		stream:        DefaultNATSStream,
+		subjectPrefix: DefaultNATSSubjectPrefix,
+		groupID:       DefaultConsumerGroup,
+		batchSize:     DefaultNATSBatchSize,
+		fetchWait:     DefaultNATSFetchWait,
+		ackWait:       DefaultNATSAckWait,
+	}
+
+	for _, opt := range opts {
+		opt(bus)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jetstream context: %w", err)
+	}
+	bus.js = js
+
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     bus.stream,
+		Subjects: []string{bus.subjectPrefix + ".>"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream %s: %w", bus.stream, err)
+	}
+
+	return bus, nil
+}
+
+func (n *NATSEventBus) subject(topic string) string {
+	return n.subjectPrefix + "." + topic
+}
+
+func (n *NATSEventBus) topic(subject string) string {
+	return strings.TrimPrefix(subject, n.subjectPrefix+".")
+}
+
+// Publish writes the event to the topic's subject. The event ID is used as message ID,
+// so JetStream drops duplicates published within its deduplication window.
+func (n *NATSEventBus) Publish(ctx context.Context, topic string, event *Event) error {
+	n.mu.RLock()
+	closed := n.state == busClosed
+	n.mu.RUnlock()
+	if closed {
+		return ErrBusClosed
+	}
+
+	ctx, span := tracing.StartProducerSpan(ctx, "nats", topic)
+	defer span.End()
+
+	injectMetadata(ctx, event)
+
+	if err := n.publish(ctx, topic, event); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	log.Printf("Event published: %s to topic: %s", event.Type, topic)
+	return nil
+}
+
+func (n *NATSEventBus) publish(ctx context.Context, topic string, event *Event) error {
+	msg, err := encodeNATSMessage(n.subject(topic), event)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.js.PublishMsg(ctx, msg, jetstream.WithMsgID(event.ID))
+	return err
+}
+
+// encodeNATSMessage lays the event out as a message with its CloudEvents JSON as data and
+// every header, including those the JSON cannot carry, as a message header
+func encodeNATSMessage(subject string, event *Event) (*nats.Msg, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = payload
+	for key, value := range event.Headers {
+		msg.Header.Set(key, value)
+	}
+	msg.Header.Set(kafkaContentTypeHeader, CloudEventsContentType)
+	return msg, nil
+}
+
+// decodeNATSMessage reads the event of a message and merges back the headers its JSON
+// does not carry, leaving out the Nats-* headers JetStream adds
+func decodeNATSMessage(data []byte, header nats.Header) (*Event, error) {
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		if key == kafkaContentTypeHeader || strings.HasPrefix(key, "Nats-") || len(values) == 0 {
+			continue
+		}
+		if _, exists := event.Headers[key]; !exists {
+			event.SetHeader(key, values[0])
+		}
+	}
+	return &event, nil
+}
+
+// Use appends middleware applied to every handler invocation, after built-in panic recovery
+func (n *NATSEventBus) Use(middleware ...EventMiddleware) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.middleware = append(n.middleware, middleware...)
+}
+
+func (n *NATSEventBus) wrap(handler EventHandler) EventHandler {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return Chain(Chain(handler, n.middleware...), Recover())
+}
+
+// Subscribe registers a consumer for topic, which starts fetching once the bus is started.
+// Messages are handled one at a time per process. topic may be a pattern, see
+// MemoryEventBus.Subscribe; the consumer then reads every subject of the stream.
+func (n *NATSEventBus) Subscribe(ctx context.Context, topic string, handler EventHandler, opts ...SubscribeOption) error {
+	sub := subscription{
+		ctx:     ctx,
+		topic:   topic,
+		handler: handler,
+		config:  newSubscribeConfig(opts...),
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	switch n.state {
+	case busDraining, busClosed:
+		return ErrBusClosed
+	case busRunning:
+		n.startConsumer(sub)
+	default:
+		n.pending = append(n.pending, sub)
+	}
+	return nil
+}
+
+// Start starts the consumers of all subscriptions registered so far
+func (n *NATSEventBus) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	switch n.state {
+	case busDraining, busClosed:
+		return ErrBusClosed
+	case busRunning:
+		return nil
+	}
+
+	n.state = busRunning
+	for _, sub := range n.pending {
+		n.startConsumer(sub)
+	}
+	n.pending = nil
+	return nil
+}
+
+// startConsumer starts consuming a subscription in the background; callers hold n.mu
+func (n *NATSEventBus) startConsumer(sub subscription) {
+	groupID := sub.config.GroupID
+	if groupID == "" {
+		groupID = n.groupID
+	}
+
+	// Draining only stops fetching; in-flight handlers keep the subscription's context
+	fetchCtx, stopFetching := context.WithCancel(sub.ctx)
+	n.stopFetching = append(n.stopFetching, stopFetching)
+
+	n.consumers.Add(1)
+	go func() {
+		defer n.consumers.Done()
+
+		consumer, err := n.ensureConsumer(fetchCtx, groupID, sub)
+		if err != nil {
+			return
+		}
+		n.consume(fetchCtx, consumer, sub)
+	}()
+}
+
+// ensureConsumer creates or updates the durable consumer of a group and subscription,
+// retrying until it succeeds or ctx is done
+func (n *NATSEventBus) ensureConsumer(ctx context.Context, groupID string, sub subscription) (jetstream.Consumer, error) {
+	filter := n.subject(sub.topic)
+	if IsTopicPattern(sub.topic) {
+		filter = n.subjectPrefix + ".>"
+	}
+
+	deliver := jetstream.DeliverAllPolicy
+	if sub.config.StartOffset == StartOffsetLatest {
+		deliver = jetstream.DeliverNewPolicy
+	}
+
+	config := jetstream.ConsumerConfig{
+		Durable:       natsName(groupID + "_" + sub.topic),
+		FilterSubject: filter,
+		DeliverPolicy: deliver,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       n.ackWait,
+		// Retries and dead-lettering are handled by the bus, not by JetStream
+		MaxDeliver: -1,
+	}
+
+	for attempt := 1; ; attempt++ {
+		consumer, err := n.js.CreateOrUpdateConsumer(ctx, n.stream, config)
+		if err == nil {
+			return consumer, nil
+		}
+
+		log.Printf("Failed to create consumer %s: %v", config.Durable, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(sub.config.Retry.Backoff(attempt)):
+		}
+	}
+}
+
+// consume fetches batches until fetchCtx is done, acknowledging every message that was
+// handled or dead-lettered; the rest are redelivered after a backoff
+func (n *NATSEventBus) consume(fetchCtx context.Context, consumer jetstream.Consumer, sub subscription) {
+	failures := 0
+	for fetchCtx.Err() == nil {
+		batch, err := consumer.Fetch(n.batchSize, jetstream.FetchMaxWait(n.fetchWait))
+		if err == nil {
+			for msg := range batch.Messages() {
+				n.processAndAck(sub.ctx, msg, sub)
+			}
+			err = batch.Error()
+		}
+		if err == nil || errors.Is(err, nats.ErrTimeout) {
+			failures = 0
+			continue
+		}
+		if fetchCtx.Err() != nil {
+			return
+		}
+
+		failures++
+		log.Printf("Error fetching messages for %s: %v", sub.topic, err)
+
+		select {
+		case <-fetchCtx.Done():
+			return
+		case <-time.After(sub.config.Retry.Backoff(failures)):
+		}
+	}
+}
+
+func (n *NATSEventBus) processAndAck(ctx context.Context, msg jetstream.Msg, sub subscription) {
+	var deliveries uint64 = 1
+	offset := ""
+	if meta, err := msg.Metadata(); err == nil {
+		deliveries = meta.NumDelivered
+		offset = strconv.FormatUint(meta.Sequence.Stream, 10)
+	}
+
+	if err := n.processMessage(ctx, msg, offset, deliveries, sub); err != nil {
+		log.Printf("Redelivering message %s of %s later: %v", offset, msg.Subject(), err)
+		msg.NakWithDelay(sub.config.Retry.Backoff(int(deliveries)))
+		return
+	}
+
+	if err := msg.Ack(); err != nil {
+		log.Printf("Failed to acknowledge message %s of %s: %v", offset, msg.Subject(), err)
+	}
+}
+
+// processMessage handles a message, routing it to the dead-letter topic when handling fails.
+// Messages delivered more often than the retry policy allows, e.g. because they crash the
+// consumer, are dead-lettered without being handled again.
+func (n *NATSEventBus) processMessage(ctx context.Context, msg jetstream.Msg, offset string, deliveries uint64, sub subscription) error {
+	topic := n.topic(msg.Subject())
+
+	event, err := decodeNATSMessage(msg.Data(), msg.Headers())
+	if err != nil {
+		log.Printf("Error decoding event: %v", err)
+		return n.deadLetter(ctx, topic, offset, undecodableEvent(topic+"@"+offset, "", msg.Data()), sub.config, 0, err)
+	}
+
+	if IsTopicPattern(sub.topic) && !patternReads(sub.topic, topic) {
+		return nil
+	}
+	if !subscriptionMatches(sub.topic, topic, event) {
+		return nil
+	}
+
+	if sub.config.Retry.Exhausted(int(deliveries) - 1) {
+		err := fmt.Errorf("message delivered %d times without being acknowledged", deliveries-1)
+		return n.deadLetter(ctx, topic, offset, event, sub.config, int(deliveries-1), err)
+	}
+
+	handlerCtx, span := tracing.StartConsumerSpan(extractMetadata(ctx, event), "nats", topic)
+	attempts, err := handleWithRetry(handlerCtx, sub.config.Retry, n.wrap(sub.handler), event)
+	endSpan(span, err)
+
+	if err != nil {
+		log.Printf("Error handling event %s after %d attempts: %v", event.ID, attempts, err)
+		return n.deadLetter(ctx, topic, offset, event, sub.config, attempts, err)
+	}
+
+	return nil
+}
+
+// deadLetter publishes an event that could not be handled to its dead-letter topic,
+// retrying until it succeeds or ctx is done. Without dead-lettering the event is dropped.
+func (n *NATSEventBus) deadLetter(ctx context.Context, topic, offset string, event *Event, config SubscribeConfig, attempts int, cause error) error {
+	if !config.DeadLetter {
+		log.Printf("Dropping message %s of %s: %v", offset, topic, cause)
+		return nil
+	}
+
+	dlqEvent := newDeadLetterEvent(event, topic, 0, offset, attempts, cause)
+	for attempt := 1; ; attempt++ {
+		err := n.publish(ctx, DeadLetterTopic(topic), dlqEvent)
+		if err == nil {
+			break
+		}
+
+		log.Printf("Failed to route message to dead-letter topic %s (attempt %d): %v", DeadLetterTopic(topic), attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to route message to dead-letter topic: %w", err)
+		case <-time.After(config.Retry.Backoff(attempt)):
+		}
+	}
+
+	log.Printf("Message %s of %s routed to %s", offset, topic, DeadLetterTopic(topic))
+	return nil
+}
+
+// Drain stops fetching and waits until in-flight messages are handled and acknowledged or
+// ctx is done. Publishing keeps working until Close, so handlers can still emit events.
+func (n *NATSEventBus) Drain(ctx context.Context) error {
+	n.mu.Lock()
+	if n.state == busClosed {
+		n.mu.Unlock()
+		return ErrBusClosed
+	}
+	n.state = busDraining
+	n.pending = nil
+	for _, stop := range n.stopFetching {
+		stop()
+	}
+	n.mu.Unlock()
+
+	if err := waitGroupDone(ctx, &n.consumers); err != nil {
+		return fmt.Errorf("failed to drain nats event bus: %w", err)
+	}
+	return nil
+}
+
+// Close stops all consumers without waiting for them and closes the NATS connection.
+// Call Drain first for a graceful shutdown.
+func (n *NATSEventBus) Close() error {
+	n.mu.Lock()
+	if n.state == busClosed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.state = busClosed
+	for _, stop := range n.stopFetching {
+		stop()
+	}
+	n.mu.Unlock()
+
+	n.conn.Close()
+	return nil
+}
+
+// natsName turns a group and topic into a valid durable consumer name
+func natsName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '/', '\\':
+			return '_'
+		}
+		return r
+	}, name)
+}
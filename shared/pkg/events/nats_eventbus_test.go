@@ -0,0 +1,47 @@
+package events
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNATSMessageKeepsDeadLetterHeaders(t *testing.T) {
+	event, err := NewEvent(UserQuotasResetEvent, "test", "1.0", UserQuotasResetData{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dlqEvent := newDeadLetterEvent(event, "user-events", 0, "42", 3, errors.New("quota service unavailable"))
+
+	msg, err := encodeNATSMessage("events.user-events.dlq", dlqEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// JetStream stores the deduplication ID as a header
+	msg.Header.Set("Nats-Msg-Id", event.ID)
+
+	decoded, err := decodeNATSMessage(msg.Data, msg.Header)
+	if err != nil {
+		t.Fatalf("decodeNATSMessage() error = %v", err)
+	}
+
+	if decoded.ID != event.ID {
+		t.Errorf("decoded event %s, want %s", decoded.ID, event.ID)
+	}
+	want := map[string]string{
+		DeadLetterErrorHeader:           "quota service unavailable",
+		DeadLetterAttemptsHeader:        "3",
+		DeadLetterSourceTopicHeader:     "user-events",
+		DeadLetterSourcePartitionHeader: "0",
+		DeadLetterSourceOffsetHeader:    "42",
+	}
+	for key, value := range want {
+		if got := decoded.Header(key); got != value {
+			t.Errorf("header %s = %q, want %q", key, got, value)
+		}
+	}
+	for _, key := range []string{"Nats-Msg-Id", kafkaContentTypeHeader} {
+		if _, exists := decoded.Headers[key]; exists {
+			t.Errorf("transport header %s was copied to the event", key)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"shared/pkg/database"
+	"shared/pkg/tracing"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OutboxEventBus writes published events to the outbox table instead of the broker.
+// When the context carries a transaction (see database.TxManager) the row is written
+// in that transaction, so the event is only recorded if the domain change commits.
+type OutboxEventBus struct {
+	db  *sqlx.DB
+	bus EventBus
+}
+
+// NewOutboxEventBus creates an outbox-backed bus; subscriptions are delegated to bus
+func NewOutboxEventBus(db *sqlx.DB, bus EventBus) *OutboxEventBus {
+	return &OutboxEventBus{
+		db:  db,
+		bus: bus,
+	}
+}
+
+// Publish stores the event in the outbox for the relay to dispatch
+func (o *OutboxEventBus) Publish(ctx context.Context, topic string, event *Event) error {
+	// Capture trace and correlation now; the relay publishes from its own context
+	injectMetadata(ctx, event)
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	query := `
+		INSERT INTO outbox (event_id, topic, event_type, payload, created_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+	`
+
+	now := time.Now().UTC()
+	_, err = database.Executor(ctx, o.db).ExecContext(ctx, query,
+		event.ID,
+		topic,
+		event.Type,
+		payload,
+		now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write event to outbox: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe registers a handler on the underlying bus
+func (o *OutboxEventBus) Subscribe(ctx context.Context, topic string, handler EventHandler, opts ...SubscribeOption) error {
+	return o.bus.Subscribe(ctx, topic, handler, opts...)
+}
+
+// Close is a no-op; the underlying bus is owned and closed by the caller
+func (o *OutboxEventBus) Close() error {
+	return nil
+}
+
+// OutboxRelayConfig configures the outbox relay worker
+type OutboxRelayConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+	Retry        RetryPolicy
+}
+
+// DefaultOutboxRelayConfig returns sensible defaults for the relay
+func DefaultOutboxRelayConfig() OutboxRelayConfig {
+	return OutboxRelayConfig{
+		BatchSize:    100,
+		PollInterval: time.Second,
+		Retry: RetryPolicy{
+			MaxAttempts:    10,
+			InitialBackoff: time.Second,
+			MaxBackoff:     5 * time.Minute,
+			Multiplier:     2,
+		},
+	}
+}
+
+// OutboxRelay drains pending outbox rows into an EventBus. Rows that still fail after
+// Retry.MaxAttempts are published to the dead-letter topic of their topic with the same
+// x-dlq-* headers as consumer failures, and are then left in the table marked as dead
+// lettered for inspection.
+type OutboxRelay struct {
+	db           *sqlx.DB
+	bus          EventBus
+	config       OutboxRelayConfig
+	deadLettered metric.Int64Counter
+}
+
+// NewOutboxRelay creates a new relay that dispatches outbox rows to bus
+func NewOutboxRelay(db *sqlx.DB, bus EventBus, config OutboxRelayConfig) *OutboxRelay {
+	defaults := DefaultOutboxRelayConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.Retry == (RetryPolicy{}) {
+		config.Retry = defaults.Retry
+	}
+
+	deadLettered, err := otel.Meter("shared/events").Int64Counter("outbox.dead_lettered",
+		metric.WithDescription("Number of outbox events moved to a dead-letter topic"),
+	)
+	if err != nil {
+		log.Printf("Failed to create outbox.dead_lettered counter: %v", err)
+	}
+
+	return &OutboxRelay{
+		db:           db,
+		bus:          bus,
+		config:       config,
+		deadLettered: deadLettered,
+	}
+}
+
+type outboxRow struct {
+	ID        int64          `db:"id"`
+	Topic     string         `db:"topic"`
+	Payload   []byte         `db:"payload"`
+	Attempts  int            `db:"attempts"`
+	LastError sql.NullString `db:"last_error"`
+}
+
+// Run polls the outbox until ctx is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			dispatched, err := r.DispatchPending(ctx)
+			if err != nil {
+				log.Printf("Error dispatching outbox events: %v", err)
+				break
+			}
+			// Keep draining while full batches are coming back
+			if dispatched < r.config.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchPending publishes one batch of due outbox rows and returns how many were handled
+func (r *OutboxRelay) DispatchPending(ctx context.Context) (int, error) {
+	// Rows are marked dispatched once published, so publishes wait for the broker's
+	// acknowledgement even on a bus with an async producer
+	ctx = withAcknowledgedWrites(ctx)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin outbox transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT id, topic, payload, attempts, last_error
+		FROM outbox
+		WHERE dispatched_at IS NULL AND dead_lettered_at IS NULL AND next_attempt_at <= $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`
+
+	var rows []outboxRow
+	now := time.Now().UTC()
+	if err := tx.SelectContext(ctx, &rows, query, now, r.config.BatchSize); err != nil {
+		return 0, fmt.Errorf("failed to load outbox events: %w", err)
+	}
+
+	// Rows whose dead-lettering failed on an earlier pass are only dead-lettered again
+	pending := make([]outboxRow, 0, len(rows))
+	for _, row := range rows {
+		if !r.config.Retry.Exhausted(row.Attempts) {
+			pending = append(pending, row)
+			continue
+		}
+		if err := r.deadLetter(ctx, tx, row, row.Attempts, errors.New(row.LastError.String)); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, batch := range r.batches(pending) {
+		errs := r.dispatch(ctx, batch)
+		for i, row := range batch {
+			if err := errs[i]; err != nil {
+				attempts := row.Attempts + 1
+				log.Printf("Failed to dispatch outbox event %d (attempt %d): %v", row.ID, attempts, err)
+
+				if err := r.markFailed(ctx, tx, row.ID, attempts, err); err != nil {
+					return 0, err
+				}
+				if r.config.Retry.Exhausted(attempts) {
+					if err := r.deadLetter(ctx, tx, row, attempts, err); err != nil {
+						return 0, err
+					}
+				}
+				continue
+			}
+
+			if err := r.markDispatched(ctx, tx, row.ID); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit outbox transaction: %w", err)
+	}
+
+	return len(rows), nil
+}
+
+// batches splits rows into runs of the same topic when the bus publishes batches, and into
+// single rows otherwise
+func (r *OutboxRelay) batches(rows []outboxRow) [][]outboxRow {
+	if _, ok := r.bus.(BatchPublisher); !ok {
+		batches := make([][]outboxRow, len(rows))
+		for i := range rows {
+			batches[i] = rows[i : i+1]
+		}
+		return batches
+	}
+
+	var batches [][]outboxRow
+	for start := 0; start < len(rows); {
+		end := start + 1
+		for end < len(rows) && rows[end].Topic == rows[start].Topic {
+			end++
+		}
+		batches = append(batches, rows[start:end])
+		start = end
+	}
+	return batches
+}
+
+// dispatch publishes a batch of rows of one topic and returns the outcome of each row.
+// Rows that cannot be decoded fail on their own; when publishing fails, all others do.
+func (r *OutboxRelay) dispatch(ctx context.Context, batch []outboxRow) []error {
+	errs := make([]error, len(batch))
+	events := make([]*Event, 0, len(batch))
+	for i, row := range batch {
+		var event Event
+		if err := json.Unmarshal(row.Payload, &event); err != nil {
+			errs[i] = fmt.Errorf("failed to unmarshal outbox payload: %w", err)
+			continue
+		}
+		events = append(events, &event)
+	}
+
+	var err error
+	switch len(events) {
+	case 0:
+		return errs
+	case 1:
+		err = r.bus.Publish(tracing.ExtractKafkaTrace(ctx, events[0].Headers), batch[0].Topic, events[0])
+	default:
+		err = PublishBatch(ctx, r.bus, batch[0].Topic, events)
+	}
+
+	if err != nil {
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+	}
+	return errs
+}
+
+func (r *OutboxRelay) markDispatched(ctx context.Context, tx *sqlx.Tx, id int64) error {
+	query := `UPDATE outbox SET dispatched_at = $1, attempts = attempts + 1, last_error = NULL WHERE id = $2`
+	if _, err := tx.ExecContext(ctx, query, time.Now().UTC(), id); err != nil {
+		return fmt.Errorf("failed to mark outbox event %d as dispatched: %w", id, err)
+	}
+	return nil
+}
+
+func (r *OutboxRelay) markFailed(ctx context.Context, tx *sqlx.Tx, id int64, attempts int, cause error) error {
+	query := `UPDATE outbox SET attempts = $1, last_error = $2, next_attempt_at = $3 WHERE id = $4`
+
+	nextAttempt := time.Now().UTC().Add(r.config.Retry.Backoff(attempts))
+	lastError := sql.NullString{String: cause.Error(), Valid: true}
+	if _, err := tx.ExecContext(ctx, query, attempts, lastError, nextAttempt, id); err != nil {
+		return fmt.Errorf("failed to record outbox failure for event %d: %w", id, err)
+	}
+	return nil
+}
+
+// deadLetter publishes a row that exhausted its attempts to the dead-letter topic of its
+// topic and marks it as dead lettered. When that fails too the row is retried after the
+// backoff of its last attempt and dead-lettered again on a later pass.
+func (r *OutboxRelay) deadLetter(ctx context.Context, tx *sqlx.Tx, row outboxRow, attempts int, cause error) error {
+	id := strconv.FormatInt(row.ID, 10)
+
+	var event Event
+	if err := json.Unmarshal(row.Payload, &event); err != nil {
+		event = *undecodableEvent(id, "", row.Payload)
+	}
+
+	dlqTopic := DeadLetterTopic(row.Topic)
+	dlqEvent := newDeadLetterEvent(&event, row.Topic, 0, id, attempts, cause)
+	if err := r.bus.Publish(ctx, dlqTopic, dlqEvent); err != nil {
+		log.Printf("Failed to route outbox event %d to dead-letter topic %s: %v", row.ID, dlqTopic, err)
+
+		query := `UPDATE outbox SET next_attempt_at = $1 WHERE id = $2`
+		nextAttempt := time.Now().UTC().Add(r.config.Retry.Backoff(attempts))
+		if _, err := tx.ExecContext(ctx, query, nextAttempt, row.ID); err != nil {
+			return fmt.Errorf("failed to record outbox failure for event %d: %w", row.ID, err)
+		}
+		return nil
+	}
+
+	query := `UPDATE outbox SET dead_lettered_at = $1 WHERE id = $2`
+	if _, err := tx.ExecContext(ctx, query, time.Now().UTC(), row.ID); err != nil {
+		return fmt.Errorf("failed to mark outbox event %d as dead lettered: %w", row.ID, err)
+	}
+
+	if r.deadLettered != nil {
+		r.deadLettered.Add(ctx, 1, metric.WithAttributes(attribute.String("topic", row.Topic)))
+	}
+	log.Printf("Outbox event %d (%s) routed to %s after %d attempts: %v", row.ID, event.Type, dlqTopic, attempts, cause)
+	return nil
+}
+
+// OutboxStats counts the rows of the outbox that were not dispatched
+type OutboxStats struct {
+	// Pending rows wait for their first or next attempt
+	Pending int `db:"pending" json:"pending"`
+	// Failing rows have failed at least once and are still retried
+	Failing int `db:"failing" json:"failing"`
+	// DeadLettered rows exhausted their attempts and were moved to a dead-letter topic
+	DeadLettered int `db:"dead_lettered" json:"dead_lettered"`
+}
+
+// Stats counts the rows that were not dispatched, e.g. for a health check or an alert on
+// dead-lettered events
+func (r *OutboxRelay) Stats(ctx context.Context) (OutboxStats, error) {
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE dead_lettered_at IS NULL) AS pending,
+			COUNT(*) FILTER (WHERE dead_lettered_at IS NULL AND attempts > 0) AS failing,
+			COUNT(*) FILTER (WHERE dead_lettered_at IS NOT NULL) AS dead_lettered
+		FROM outbox
+		WHERE dispatched_at IS NULL
+	`
+
+	var stats OutboxStats
+	if err := r.db.GetContext(ctx, &stats, query); err != nil {
+		return OutboxStats{}, fmt.Errorf("failed to count outbox events: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,556 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"shared/pkg/tracing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Fields of a stream entry written by RedisEventBus
+const (
+	redisEventField   = "event"
+	redisKeyField     = "key"
+	redisTypeField    = "type"
+	redisHeadersField = "headers"
+)
+
+// Defaults of the RedisEventBus
+const (
+	DefaultRedisBatchSize    = 100
+	DefaultRedisBlock        = time.Second
+	DefaultRedisClaimMinIdle = time.Minute
+)
+
+// RedisEventBus implements EventBus on Redis Streams. Every topic is a stream and every
+// consumer group a Redis consumer group; entries are acknowledged with XACK once they were
+// handled or dead-lettered, and entries left pending by a crashed consumer are taken over
+// with XCLAIM after they have been idle for the claim interval.
+type RedisEventBus struct {
+	client       redis.UniversalClient
+	groupID      string
+	consumer     string
+	maxLen       int64
+	batchSize    int64
+	block        time.Duration
+	claimMinIdle time.Duration
+	middleware   []EventMiddleware
+
+	state        busState
+	pending      []subscription
+	stopFetching []context.CancelFunc
+	consumers    sync.WaitGroup
+	mu           sync.RWMutex
+}
+
+// RedisOption configures a RedisEventBus
+type RedisOption func(*RedisEventBus)
+
+// WithRedisConsumerGroup sets the default consumer group for subscriptions, usually the service name
+func WithRedisConsumerGroup(groupID string) RedisOption {
+	return func(r *RedisEventBus) {
+		r.groupID = groupID
+	}
+}
+
+// WithRedisConsumerName sets the name of this process within its consumer groups;
+// it defaults to the host name and process ID
+func WithRedisConsumerName(name string) RedisOption {
+	return func(r *RedisEventBus) {
+		r.consumer = name
+	}
+}
+
+// WithRedisMaxLen caps every stream at roughly n entries; zero keeps all entries
+func WithRedisMaxLen(n int64) RedisOption {
+	return func(r *RedisEventBus) {
+		r.maxLen = n
+	}
+}
+
+// WithRedisClaimMinIdle sets how long an entry stays pending before another consumer claims it
+func WithRedisClaimMinIdle(d time.Duration) RedisOption {
+	return func(r *RedisEventBus) {
+		r.claimMinIdle = d
+	}
+}
+
+// NewRedisEventBus creates an event bus on Redis Streams; Close closes client
+func NewRedisEventBus(client redis.UniversalClient, opts ...RedisOption) *RedisEventBus {
+	host, _ := os.Hostname()
+
+	bus := &RedisEventBus{
+		client:       client,
+		groupID:      DefaultConsumerGroup,
+		consumer:     fmt.Sprintf("%s-%d", host, os.Getpid()),
+		batchSize:    DefaultRedisBatchSize,
+		block:        DefaultRedisBlock,
+		claimMinIdle: DefaultRedisClaimMinIdle,
+	}
+
+	for _, opt := range opts {
+		opt(bus)
+	}
+
+	return bus
+}
+
+// Publish appends the event to the topic's stream
+func (r *RedisEventBus) Publish(ctx context.Context, topic string, event *Event) error {
+	r.mu.RLock()
+	closed := r.state == busClosed
+	r.mu.RUnlock()
+	if closed {
+		return ErrBusClosed
+	}
+
+	ctx, span := tracing.StartProducerSpan(ctx, "redis", topic)
+	defer span.End()
+
+	injectMetadata(ctx, event)
+
+	if err := r.add(ctx, topic, partitionKey(event), event); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	log.Printf("Event published: %s to topic: %s", event.Type, topic)
+	return nil
+}
+
+func (r *RedisEventBus) add(ctx context.Context, stream, key string, event *Event) error {
+	values, err := redisValues(key, event)
+	if err != nil {
+		return err
+	}
+
+	return r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: r.maxLen,
+		Approx: r.maxLen > 0,
+		Values: values,
+	}).Err()
+}
+
+// redisValues lays an event out as the fields of a stream entry. Headers the CloudEvents
+// JSON cannot carry, such as the x-dlq-* headers, go to a field of their own.
+func redisValues(key string, event *Event) (map[string]interface{}, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	values := map[string]interface{}{
+		redisEventField: payload,
+		redisKeyField:   key,
+		redisTypeField:  event.Type,
+	}
+	if headers := event.plainHeaders(); len(headers) > 0 {
+		encoded, err := json.Marshal(headers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal headers: %w", err)
+		}
+		values[redisHeadersField] = encoded
+	}
+	return values, nil
+}
+
+// decodeRedisEntry reads the event of a stream entry together with its headers field
+func decodeRedisEntry(values map[string]interface{}) (*Event, error) {
+	payload, _ := values[redisEventField].(string)
+
+	var event Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return nil, err
+	}
+
+	if encoded, ok := values[redisHeadersField].(string); ok {
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(encoded), &headers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal headers: %w", err)
+		}
+		mergeHeaders(&event, headers)
+	}
+	return &event, nil
+}
+
+// Use appends middleware applied to every handler invocation, after built-in panic recovery
+func (r *RedisEventBus) Use(middleware ...EventMiddleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.middleware = append(r.middleware, middleware...)
+}
+
+func (r *RedisEventBus) wrap(handler EventHandler) EventHandler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return Chain(Chain(handler, r.middleware...), Recover())
+}
+
+// Subscribe registers a consumer for topic, which starts reading once the bus is started.
+// Entries of a stream are handled one at a time per process; with several processes in a
+// group, entries of one aggregate may be handled concurrently. topic may be a pattern, see
+// MemoryEventBus.Subscribe; it covers the streams that exist when the consumer starts.
+func (r *RedisEventBus) Subscribe(ctx context.Context, topic string, handler EventHandler, opts ...SubscribeOption) error {
+	sub := subscription{
+		ctx:     ctx,
+		topic:   topic,
+		handler: handler,
+		config:  newSubscribeConfig(opts...),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.state {
+	case busDraining, busClosed:
+		return ErrBusClosed
+	case busRunning:
+		r.startConsumers(sub)
+	default:
+		r.pending = append(r.pending, sub)
+	}
+	return nil
+}
+
+// Start starts the consumers of all subscriptions registered so far
+func (r *RedisEventBus) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.state {
+	case busDraining, busClosed:
+		return ErrBusClosed
+	case busRunning:
+		return nil
+	}
+
+	r.state = busRunning
+	for _, sub := range r.pending {
+		r.startConsumers(sub)
+	}
+	r.pending = nil
+	return nil
+}
+
+// startConsumers starts one consumer per stream of a subscription; callers hold r.mu
+func (r *RedisEventBus) startConsumers(sub subscription) {
+	groupID := sub.config.GroupID
+	if groupID == "" {
+		groupID = r.groupID
+	}
+
+	// Draining only stops reading; in-flight handlers keep the subscription's context
+	fetchCtx, stopFetching := context.WithCancel(sub.ctx)
+	r.stopFetching = append(r.stopFetching, stopFetching)
+
+	r.consumers.Add(1)
+	go func() {
+		defer r.consumers.Done()
+
+		streams := []string{sub.topic}
+		if IsTopicPattern(sub.topic) {
+			resolved, err := r.resolvePattern(fetchCtx, sub.topic, sub.config.Retry)
+			if err != nil {
+				return
+			}
+			streams = resolved
+		}
+
+		var wg sync.WaitGroup
+		for _, stream := range streams {
+			wg.Add(1)
+			go func(stream string) {
+				defer wg.Done()
+				r.consume(fetchCtx, sub, groupID, stream)
+			}(stream)
+		}
+		wg.Wait()
+	}()
+}
+
+// resolvePattern lists the streams a pattern subscription reads, waiting until there is
+// at least one; it only fails when ctx is done
+func (r *RedisEventBus) resolvePattern(ctx context.Context, pattern string, retry RetryPolicy) ([]string, error) {
+	for attempt := 1; ; attempt++ {
+		all, err := r.listStreams(ctx)
+		if err != nil {
+			log.Printf("Failed to list streams for pattern %s: %v", pattern, err)
+		} else if streams := patternTopics(pattern, all); len(streams) > 0 {
+			log.Printf("Pattern %s subscribed to streams: %s", pattern, strings.Join(streams, ", "))
+			return streams, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retry.Backoff(attempt)):
+		}
+	}
+}
+
+func (r *RedisEventBus) listStreams(ctx context.Context) ([]string, error) {
+	var streams []string
+	var cursor uint64
+	for {
+		keys, next, err := r.client.ScanType(ctx, cursor, "*", 100, "stream").Result()
+		if err != nil {
+			return nil, err
+		}
+		streams = append(streams, keys...)
+
+		cursor = next
+		if cursor == 0 {
+			return streams, nil
+		}
+	}
+}
+
+// consume reads new entries of stream for the group and, every claim interval, claims
+// entries other consumers left pending for too long
+func (r *RedisEventBus) consume(fetchCtx context.Context, sub subscription, groupID, stream string) {
+	if err := r.ensureGroup(fetchCtx, stream, groupID, sub.config); err != nil {
+		return
+	}
+
+	nextClaim := time.Now()
+	failures := 0
+	for fetchCtx.Err() == nil {
+		if time.Now().After(nextClaim) {
+			r.claimStuck(fetchCtx, sub, groupID, stream)
+			nextClaim = time.Now().Add(r.claimMinIdle / 2)
+		}
+
+		result, err := r.client.XReadGroup(fetchCtx, &redis.XReadGroupArgs{
+			Group:    groupID,
+			Consumer: r.consumer,
+			Streams:  []string{stream, ">"},
+			Count:    r.batchSize,
+			Block:    r.block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if fetchCtx.Err() != nil {
+				return
+			}
+
+			failures++
+			log.Printf("Error reading stream %s: %v", stream, err)
+
+			select {
+			case <-fetchCtx.Done():
+				return
+			case <-time.After(sub.config.Retry.Backoff(failures)):
+			}
+			continue
+		}
+		failures = 0
+
+		for _, s := range result {
+			for _, msg := range s.Messages {
+				r.processAndAck(sub.ctx, stream, groupID, msg, 1, sub)
+			}
+		}
+	}
+}
+
+// ensureGroup creates the consumer group of a stream, and the stream itself, if needed
+func (r *RedisEventBus) ensureGroup(ctx context.Context, stream, groupID string, config SubscribeConfig) error {
+	start := "0"
+	if config.StartOffset == StartOffsetLatest {
+		start = "$"
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := r.client.XGroupCreateMkStream(ctx, stream, groupID, start).Err()
+		if err == nil || strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return nil
+		}
+
+		log.Printf("Failed to create consumer group %s on %s: %v", groupID, stream, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(config.Retry.Backoff(attempt)):
+		}
+	}
+}
+
+// claimStuck takes over entries that were delivered to some consumer of the group but not
+// acknowledged within the claim interval, e.g. because that consumer crashed
+func (r *RedisEventBus) claimStuck(ctx context.Context, sub subscription, groupID, stream string) {
+	pending, err := r.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  groupID,
+		Idle:   r.claimMinIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  r.batchSize,
+	}).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to list pending entries of %s: %v", stream, err)
+		}
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	ids := make([]string, len(pending))
+	deliveries := make(map[string]int64, len(pending))
+	for i, p := range pending {
+		ids[i] = p.ID
+		deliveries[p.ID] = p.RetryCount
+	}
+
+	claimed, err := r.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    groupID,
+		Consumer: r.consumer,
+		MinIdle:  r.claimMinIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		log.Printf("Failed to claim pending entries of %s: %v", stream, err)
+		return
+	}
+
+	for _, msg := range claimed {
+		log.Printf("Claimed entry %s of %s after %d deliveries", msg.ID, stream, deliveries[msg.ID])
+		r.processAndAck(sub.ctx, stream, groupID, msg, deliveries[msg.ID]+1, sub)
+	}
+}
+
+// processAndAck acknowledges an entry once it was handled or dead-lettered; otherwise it
+// stays pending and is claimed again later
+func (r *RedisEventBus) processAndAck(ctx context.Context, stream, groupID string, msg redis.XMessage, deliveries int64, sub subscription) {
+	if err := r.processMessage(ctx, stream, msg, deliveries, sub); err != nil {
+		log.Printf("Leaving entry %s of %s pending: %v", msg.ID, stream, err)
+		return
+	}
+
+	if err := r.client.XAck(ctx, stream, groupID, msg.ID).Err(); err != nil {
+		log.Printf("Failed to acknowledge entry %s of %s: %v", msg.ID, stream, err)
+	}
+}
+
+// processMessage handles an entry, routing it to the dead-letter stream when handling fails.
+// Entries delivered more often than the retry policy allows, e.g. because they crash the
+// consumer, are dead-lettered without being handled again.
+func (r *RedisEventBus) processMessage(ctx context.Context, stream string, msg redis.XMessage, deliveries int64, sub subscription) error {
+	event, err := decodeRedisEntry(msg.Values)
+	if err != nil {
+		log.Printf("Error decoding event: %v", err)
+		key, _ := msg.Values[redisKeyField].(string)
+		payload, _ := msg.Values[redisEventField].(string)
+		return r.deadLetter(ctx, stream, msg.ID, undecodableEvent(stream+"@"+msg.ID, key, []byte(payload)), sub.config, 0, err)
+	}
+
+	if !subscriptionMatches(sub.topic, stream, event) {
+		return nil
+	}
+
+	if sub.config.Retry.Exhausted(int(deliveries) - 1) {
+		err := fmt.Errorf("entry delivered %d times without being acknowledged", deliveries-1)
+		return r.deadLetter(ctx, stream, msg.ID, event, sub.config, int(deliveries-1), err)
+	}
+
+	handlerCtx, span := tracing.StartConsumerSpan(extractMetadata(ctx, event), "redis", stream)
+	attempts, err := handleWithRetry(handlerCtx, sub.config.Retry, r.wrap(sub.handler), event)
+	endSpan(span, err)
+
+	if err != nil {
+		log.Printf("Error handling event %s after %d attempts: %v", event.ID, attempts, err)
+		return r.deadLetter(ctx, stream, msg.ID, event, sub.config, attempts, err)
+	}
+
+	return nil
+}
+
+// deadLetter appends an event that could not be handled to the dead-letter stream, retrying
+// until it succeeds or ctx is done. Without dead-lettering the event is dropped.
+func (r *RedisEventBus) deadLetter(ctx context.Context, stream, id string, event *Event, config SubscribeConfig, attempts int, cause error) error {
+	if !config.DeadLetter {
+		log.Printf("Dropping entry %s of %s: %v", id, stream, cause)
+		return nil
+	}
+
+	dlqEvent := newDeadLetterEvent(event, stream, 0, id, attempts, cause)
+	for attempt := 1; ; attempt++ {
+		err := r.add(ctx, DeadLetterTopic(stream), partitionKey(event), dlqEvent)
+		if err == nil {
+			break
+		}
+
+		log.Printf("Failed to route entry to dead-letter stream %s (attempt %d): %v", DeadLetterTopic(stream), attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to route entry to dead-letter stream: %w", err)
+		case <-time.After(config.Retry.Backoff(attempt)):
+		}
+	}
+
+	log.Printf("Entry %s of %s routed to %s", id, stream, DeadLetterTopic(stream))
+	return nil
+}
+
+// Drain stops reading and waits until in-flight entries are handled and acknowledged or
+// ctx is done. Publishing keeps working until Close, so handlers can still emit events.
+func (r *RedisEventBus) Drain(ctx context.Context) error {
+	r.mu.Lock()
+	if r.state == busClosed {
+		r.mu.Unlock()
+		return ErrBusClosed
+	}
+	r.state = busDraining
+	r.pending = nil
+	for _, stop := range r.stopFetching {
+		stop()
+	}
+	r.mu.Unlock()
+
+	if err := waitGroupDone(ctx, &r.consumers); err != nil {
+		return fmt.Errorf("failed to drain redis event bus: %w", err)
+	}
+	return nil
+}
+
+// Close stops all consumers without waiting for them and closes the Redis client.
+// Call Drain first for a graceful shutdown.
+func (r *RedisEventBus) Close() error {
+	r.mu.Lock()
+	if r.state == busClosed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.state = busClosed
+	for _, stop := range r.stopFetching {
+		stop()
+	}
+	r.mu.Unlock()
+
+	if err := r.client.Close(); err != nil {
+		return fmt.Errorf("failed to close redis client: %w", err)
+	}
+	return nil
+}
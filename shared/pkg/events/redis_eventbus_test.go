@@ -0,0 +1,113 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeStreams is the part of a Redis client the bus uses to append to and range over
+// streams; calling anything else panics
+type fakeStreams struct {
+	redis.UniversalClient
+
+	mu      sync.Mutex
+	streams map[string][]redis.XMessage
+}
+
+func newFakeStreams() *fakeStreams {
+	return &fakeStreams{streams: make(map[string][]redis.XMessage)}
+}
+
+func (f *fakeStreams) XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Redis stores every field value as a string
+	values := make(map[string]interface{})
+	for field, value := range a.Values.(map[string]interface{}) {
+		switch v := value.(type) {
+		case []byte:
+			values[field] = string(v)
+		default:
+			values[field] = fmt.Sprint(v)
+		}
+	}
+
+	id := fmt.Sprintf("%d-0", len(f.streams[a.Stream])+1)
+	f.streams[a.Stream] = append(f.streams[a.Stream], redis.XMessage{ID: id, Values: values})
+
+	cmd := redis.NewStringCmd(ctx)
+	cmd.SetVal(id)
+	return cmd
+}
+
+func (f *fakeStreams) XRangeN(ctx context.Context, stream, start, stop string, count int64) *redis.XMessageSliceCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return redis.NewXMessageSliceCmdResult(append([]redis.XMessage(nil), f.streams[stream]...), nil)
+}
+
+func (f *fakeStreams) XRevRangeN(ctx context.Context, stream, start, stop string, count int64) *redis.XMessageSliceCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries := f.streams[stream]
+	if len(entries) == 0 {
+		return redis.NewXMessageSliceCmdResult(nil, nil)
+	}
+	return redis.NewXMessageSliceCmdResult([]redis.XMessage{entries[len(entries)-1]}, nil)
+}
+
+func TestRedisEventBusDeadLetterKeepsHeaders(t *testing.T) {
+	streams := newFakeStreams()
+	bus := NewRedisEventBus(streams)
+	ctx := context.Background()
+
+	event, err := NewEvent(UserQuotasResetEvent, "test", "1.0", UserQuotasResetData{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event.Subject = "u1"
+
+	err = bus.deadLetter(ctx, "user-events", "1700000000000-0", event, newSubscribeConfig(), 3, errors.New("quota service unavailable"))
+	if err != nil {
+		t.Fatalf("deadLetter() error = %v", err)
+	}
+
+	var dead []*Event
+	err = bus.Replay(ctx, DeadLetterTopic("user-events"), ReplayFrom{}, func(ctx context.Context, event *Event) error {
+		dead = append(dead, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if len(dead) != 1 {
+		t.Fatalf("dead-letter stream has %d entries, want 1", len(dead))
+	}
+
+	if dead[0].ID != event.ID {
+		t.Errorf("dead-lettered event %s, want %s", dead[0].ID, event.ID)
+	}
+	want := map[string]string{
+		DeadLetterErrorHeader:           "quota service unavailable",
+		DeadLetterAttemptsHeader:        "3",
+		DeadLetterSourceTopicHeader:     "user-events",
+		DeadLetterSourcePartitionHeader: "0",
+		DeadLetterSourceOffsetHeader:    "1700000000000-0",
+	}
+	for key, value := range want {
+		if got := dead[0].Header(key); got != value {
+			t.Errorf("header %s = %q, want %q", key, got, value)
+		}
+	}
+	if dead[0].Header(DeadLetterFailedAtHeader) == "" {
+		t.Errorf("header %s is missing", DeadLetterFailedAtHeader)
+	}
+}
@@ -0,0 +1,316 @@
+package events
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed schemas
+var schemaFiles embed.FS
+
+var (
+	// ErrSchemaNotFound is returned for event types or versions missing from the registry
+	ErrSchemaNotFound = errors.New("event schema not found")
+	// ErrSchemaValidation is returned when an event payload does not match its schema
+	ErrSchemaValidation = errors.New("event payload does not match schema")
+)
+
+// UpcastFunc converts a payload from one schema version to the next
+type UpcastFunc func(data json.RawMessage) (json.RawMessage, error)
+
+// SchemaEntry describes one version of an event payload
+type SchemaEntry struct {
+	Type    string
+	Version string
+	Payload reflect.Type
+	Schema  json.RawMessage
+
+	parsed map[string]interface{}
+}
+
+type upcaster struct {
+	toVersion string
+	upcast    UpcastFunc
+}
+
+// Registry maps (type, version) to a payload type and JSON Schema, validates outgoing
+// events and upcasts incoming ones to the latest registered version
+type Registry struct {
+	mu        sync.RWMutex
+	schemas   map[string]map[string]*SchemaEntry
+	upcasters map[string]map[string]upcaster
+}
+
+// NewRegistry creates an empty schema registry
+func NewRegistry() *Registry {
+	return &Registry{
+		schemas:   make(map[string]map[string]*SchemaEntry),
+		upcasters: make(map[string]map[string]upcaster),
+	}
+}
+
+// Register adds a payload version. payload is a value or pointer of the payload struct;
+// when schema is nil it is loaded from the embedded schemas directory.
+func (r *Registry) Register(eventType, version string, payload interface{}, schema []byte) error {
+	if schema == nil {
+		loaded, err := LoadSchema(eventType, version)
+		if err != nil {
+			return err
+		}
+		schema = loaded
+	}
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		return fmt.Errorf("invalid schema for %s %s: %w", eventType, version, err)
+	}
+
+	entry := &SchemaEntry{
+		Type:    eventType,
+		Version: version,
+		Schema:  schema,
+		parsed:  parsed,
+	}
+	if payload != nil {
+		entry.Payload = reflect.TypeOf(payload)
+		for entry.Payload.Kind() == reflect.Ptr {
+			entry.Payload = entry.Payload.Elem()
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.schemas[eventType] == nil {
+		r.schemas[eventType] = make(map[string]*SchemaEntry)
+	}
+	r.schemas[eventType][version] = entry
+	return nil
+}
+
+// MustRegister is like Register but panics on error; meant for package initialization
+func (r *Registry) MustRegister(eventType, version string, payload interface{}, schema []byte) {
+	if err := r.Register(eventType, version, payload, schema); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterUpcaster registers a conversion of eventType payloads from one version to another
+func (r *Registry) RegisterUpcaster(eventType, fromVersion, toVersion string, fn UpcastFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.upcasters[eventType] == nil {
+		r.upcasters[eventType] = make(map[string]upcaster)
+	}
+	r.upcasters[eventType][fromVersion] = upcaster{toVersion: toVersion, upcast: fn}
+}
+
+// Lookup returns the schema entry of an event type and version
+func (r *Registry) Lookup(eventType, version string) (*SchemaEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.schemas[eventType][version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %s", ErrSchemaNotFound, eventType, version)
+	}
+	return entry, nil
+}
+
+// Latest returns the highest registered version of an event type
+func (r *Registry) Latest(eventType string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	latest := ""
+	for version := range r.schemas[eventType] {
+		if latest == "" || compareVersions(version, latest) > 0 {
+			latest = version
+		}
+	}
+	return latest, latest != ""
+}
+
+// Entries returns all registered schema entries ordered by type and version
+func (r *Registry) Entries() []SchemaEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var entries []SchemaEntry
+	for _, versions := range r.schemas {
+		for _, entry := range versions {
+			entries = append(entries, *entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Type != entries[j].Type {
+			return entries[i].Type < entries[j].Type
+		}
+		return compareVersions(entries[i].Version, entries[j].Version) < 0
+	})
+	return entries
+}
+
+// Validate checks the event payload against the schema of its type and version
+func (r *Registry) Validate(event *Event) error {
+	entry, err := r.Lookup(event.Type, event.Version)
+	if err != nil {
+		return err
+	}
+
+	value, err := decodeJSON(event.Data)
+	if err != nil {
+		return fmt.Errorf("%w: %s %s: %v", ErrSchemaValidation, event.Type, event.Version, err)
+	}
+
+	if err := validateSchema(entry.parsed, value, "$"); err != nil {
+		return fmt.Errorf("%w: %s %s: %v", ErrSchemaValidation, event.Type, event.Version, err)
+	}
+	return nil
+}
+
+// Upcast converts the event payload in place to the latest registered version of its type.
+// Events of unregistered types are left untouched. Errors are marked with DeadLetter: a
+// missing upcaster or a payload an upcaster rejects fails the same way on every attempt.
+func (r *Registry) Upcast(event *Event) error {
+	latest, ok := r.Latest(event.Type)
+	if !ok {
+		return nil
+	}
+
+	r.mu.RLock()
+	chain := r.upcasters[event.Type]
+	r.mu.RUnlock()
+
+	version := event.Version
+	data := event.Data
+	for version != latest {
+		step, ok := chain[version]
+		if !ok {
+			return DeadLetter(fmt.Errorf("no upcaster for %s from version %s to %s", event.Type, version, latest))
+		}
+
+		upcasted, err := step.upcast(data)
+		if err != nil {
+			return DeadLetter(fmt.Errorf("failed to upcast %s from version %s: %w", event.Type, version, err))
+		}
+
+		version = step.toVersion
+		data = upcasted
+	}
+
+	if version != event.Version {
+		event.Version = version
+		event.DataSchema = DataSchemaURI(event.Type, version)
+		event.Data = data
+	}
+	return nil
+}
+
+// LoadSchema reads a schema shipped in the embedded schemas directory
+func LoadSchema(eventType, version string) ([]byte, error) {
+	schema, err := schemaFiles.ReadFile(path.Join("schemas", eventType, version+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrSchemaNotFound, eventType, version)
+	}
+	return schema, nil
+}
+
+// compareVersions orders dotted numeric versions such as "1.0" and "1.10"
+func compareVersions(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// DefaultRegistry returns a registry with every event payload shipped in this package
+func DefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.MustRegister(UserRegisteredEvent, "1.0", UserRegisteredData{}, nil)
+	registry.MustRegister(UserTierUpgradedEvent, "1.0", UserTierUpgradedData{}, nil)
+	registry.MustRegister(UserQuotaUpdatedEvent, "1.0", UserQuotaUpdatedData{}, nil)
+	registry.MustRegister(UserQuotaProvisionedEvent, "1.0", UserQuotaProvisionedData{}, nil)
+	registry.MustRegister(UserQuotaProvisioningFailedEvent, "1.0", UserQuotaProvisioningFailedData{}, nil)
+	registry.MustRegister(UserTierRevertedEvent, "1.0", UserTierRevertedData{}, nil)
+	registry.MustRegister(UserQuotaConsumedEvent, "1.0", UserQuotaConsumedData{}, nil)
+	registry.MustRegister(UserQuotaLimitChangedEvent, "1.0", UserQuotaLimitChangedData{}, nil)
+	registry.MustRegister(UserTierChangedEvent, "1.0", UserTierChangedData{}, nil)
+	registry.MustRegister(UserQuotasResetEvent, "1.0", UserQuotasResetData{}, nil)
+	registry.MustRegister(ProductCreatedEvent, "1.0", ProductCreatedData{}, nil)
+	registry.MustRegister(ProductUpdatedEvent, "1.0", ProductUpdatedData{}, nil)
+	registry.MustRegister(ProductDeletedEvent, "1.0", ProductDeletedData{}, nil)
+	registry.MustRegister(ContentDraftedEvent, "1.0", ContentDraftedData{}, nil)
+	registry.MustRegister(ContentScheduledEvent, "1.0", ContentScheduledData{}, nil)
+	registry.MustRegister(ContentPublishedEvent, "1.0", ContentPublishedData{}, nil)
+	registry.MustRegister(ContentFailedEvent, "1.0", ContentFailedData{}, nil)
+	registry.MustRegister(AIDescriptionRequestedEvent, "1.0", AIDescriptionRequestedData{}, nil)
+	registry.MustRegister(AIDescriptionCompletedEvent, "1.0", AIDescriptionCompletedData{}, nil)
+	registry.MustRegister(AIDescriptionFailedEvent, "1.0", AIDescriptionFailedData{}, nil)
+	registry.MustRegister(AIVideoRequestedEvent, "1.0", AIVideoRequestedData{}, nil)
+	registry.MustRegister(AIVideoCompletedEvent, "1.0", AIVideoCompletedData{}, nil)
+	return registry
+}
+
+// SchemaEventBus validates published events and upcasts consumed ones using a Registry
+type SchemaEventBus struct {
+	bus      EventBus
+	registry *Registry
+}
+
+// NewSchemaEventBus wraps bus with schema validation and upcasting
+func NewSchemaEventBus(bus EventBus, registry *Registry) *SchemaEventBus {
+	return &SchemaEventBus{
+		bus:      bus,
+		registry: registry,
+	}
+}
+
+// Publish validates the event against its schema before publishing it
+func (s *SchemaEventBus) Publish(ctx context.Context, topic string, event *Event) error {
+	if err := s.registry.Validate(event); err != nil {
+		return err
+	}
+	return s.bus.Publish(ctx, topic, event)
+}
+
+// Subscribe registers a handler that always receives the latest payload version
+func (s *SchemaEventBus) Subscribe(ctx context.Context, topic string, handler EventHandler, opts ...SubscribeOption) error {
+	return s.bus.Subscribe(ctx, topic, func(ctx context.Context, event *Event) error {
+		if err := s.registry.Upcast(event); err != nil {
+			return err
+		}
+		return handler(ctx, event)
+	}, opts...)
+}
+
+// Close is a no-op; the underlying bus is owned and closed by the caller
+func (s *SchemaEventBus) Close() error {
+	return nil
+}
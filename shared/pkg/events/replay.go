@@ -0,0 +1,276 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/segmentio/kafka-go"
+)
+
+// ReplayFrom is where a replay starts: at Offset or, when Time is set, at the first event
+// stored at or after Time. Offsets are per partition on Kafka, the position in the stream on
+// Redis, the stream sequence on NATS and the record offset on the file bus.
+type ReplayFrom struct {
+	Offset int64
+	Time   time.Time
+}
+
+// Replayer re-reads the events stored on a topic without a consumer group, e.g. to rebuild
+// a projection. Replay hands events to handler in order, stops at the first error and
+// returns once it reached the end of the topic as it was when the replay started.
+type Replayer interface {
+	Replay(ctx context.Context, topic string, from ReplayFrom, handler EventHandler) error
+}
+
+// Replay re-reads every partition of topic in turn
+func (k *KafkaEventBus) Replay(ctx context.Context, topic string, from ReplayFrom, handler EventHandler) error {
+	partitions, err := k.partitions(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range partitions {
+		if err := k.replayPartition(ctx, topic, p, from, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (k *KafkaEventBus) replayPartition(ctx context.Context, topic string, partition int, from ReplayFrom, handler EventHandler) error {
+	first, last, err := committedRange(ctx, k.brokers, topic, partition, from.Time)
+	if err != nil {
+		return err
+	}
+	start := first
+	if from.Time.IsZero() {
+		start = max(from.Offset, first)
+	}
+
+	return readCommitted(ctx, k.brokers, topic, partition, start, last, func(msg kafka.Message) (bool, error) {
+		event, err := decodeKafkaMessage(msg)
+		if err != nil {
+			return false, fmt.Errorf("failed to decode event at %s[%d]@%d: %w", topic, partition, msg.Offset, err)
+		}
+		if err := handler(ContextWithEvent(ctx, event), event); err != nil {
+			return false, fmt.Errorf("failed to replay event at %s[%d]@%d: %w", topic, partition, msg.Offset, err)
+		}
+		return true, nil
+	})
+}
+
+// committedReadWait is how long readCommitted waits for the next record before it takes the
+// partition as read up to its last stable offset
+const committedReadWait = 2 * time.Second
+
+// committedRange returns the first offset of a partition and its last stable offset, below
+// which every transaction is committed or aborted. With a non-zero at, first is the first
+// offset stored at or after at instead, or the last stable offset when there is none.
+func committedRange(ctx context.Context, brokers []string, topic string, partition int, at time.Time) (first, last int64, err error) {
+	requests := []kafka.OffsetRequest{kafka.FirstOffsetOf(partition), kafka.LastOffsetOf(partition)}
+	if !at.IsZero() {
+		requests = append(requests, kafka.TimeOffsetOf(partition, at))
+	}
+
+	client := &kafka.Client{Addr: kafka.TCP(brokers...)}
+	offsets, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics:         map[string][]kafka.OffsetRequest{topic: requests},
+		IsolationLevel: kafka.ReadCommitted,
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read offsets of %s[%d]: %w", topic, partition, err)
+	}
+
+	for _, p := range offsets.Topics[topic] {
+		if p.Partition != partition {
+			continue
+		}
+		if p.Error != nil {
+			return 0, 0, fmt.Errorf("failed to read offsets of %s[%d]: %w", topic, partition, p.Error)
+		}
+
+		first, last = p.FirstOffset, p.LastOffset
+		if !at.IsZero() {
+			first = last
+			for offset := range p.Offsets {
+				if offset >= 0 {
+					first = max(offset, p.FirstOffset)
+				}
+			}
+		}
+		return first, last, nil
+	}
+	return 0, 0, fmt.Errorf("no offsets for %s[%d]", topic, partition)
+}
+
+// readCommitted hands the committed records of a partition from start up to last to fn, in
+// order, until fn returns false or an error. Aborted records and the control records that end transactions take up offsets but
+// are never returned, so the read also ends once no record arrives for committedReadWait.
+func readCommitted(ctx context.Context, brokers []string, topic string, partition int, start, last int64, fn func(kafka.Message) (bool, error)) error {
+	if start >= last {
+		return nil
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        brokers,
+		Topic:          topic,
+		Partition:      partition,
+		MinBytes:       1,
+		MaxBytes:       10e6,
+		MaxWait:        committedReadWait,
+		IsolationLevel: kafka.ReadCommitted,
+	})
+	defer reader.Close()
+
+	if err := reader.SetOffset(start); err != nil {
+		return fmt.Errorf("failed to seek %s[%d] to %d: %w", topic, partition, start, err)
+	}
+
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, committedReadWait)
+		msg, err := reader.ReadMessage(readCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				return nil
+			}
+			return fmt.Errorf("failed to read %s[%d]: %w", topic, partition, err)
+		}
+
+		// Records committed after the read started are left for the next one
+		if msg.Offset >= last {
+			return nil
+		}
+		more, err := fn(msg)
+		if err != nil || !more || msg.Offset+1 >= last {
+			return err
+		}
+	}
+}
+
+// Replay re-reads the stream with XRANGE. Entry IDs start with their creation time in
+// milliseconds, so a replay from a time starts at the first ID of that millisecond.
+func (r *RedisEventBus) Replay(ctx context.Context, topic string, from ReplayFrom, handler EventHandler) error {
+	latest, err := r.client.XRevRangeN(ctx, topic, "+", "-", 1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read end of %s: %w", topic, err)
+	}
+	if len(latest) == 0 {
+		return nil
+	}
+	end := latest[0].ID
+
+	start := "-"
+	skip := from.Offset
+	if !from.Time.IsZero() {
+		start = strconv.FormatInt(from.Time.UnixMilli(), 10)
+		skip = 0
+	}
+
+	for {
+		entries, err := r.client.XRangeN(ctx, topic, start, end, r.batchSize).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", topic, err)
+		}
+
+		for _, entry := range entries {
+			if skip > 0 {
+				skip--
+				continue
+			}
+
+			event, err := decodeRedisEntry(entry.Values)
+			if err != nil {
+				return fmt.Errorf("failed to decode entry %s of %s: %w", entry.ID, topic, err)
+			}
+			if err := handler(ContextWithEvent(ctx, event), event); err != nil {
+				return fmt.Errorf("failed to replay entry %s of %s: %w", entry.ID, topic, err)
+			}
+		}
+
+		if int64(len(entries)) < r.batchSize {
+			return nil
+		}
+		start = "(" + entries[len(entries)-1].ID
+	}
+}
+
+// Replay re-reads the subject of topic with an ordered consumer, which JetStream removes
+// once the replay stops reading
+func (n *NATSEventBus) Replay(ctx context.Context, topic string, from ReplayFrom, handler EventHandler) error {
+	stream, err := n.js.Stream(ctx, n.stream)
+	if err != nil {
+		return fmt.Errorf("failed to look up stream %s: %w", n.stream, err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read stream %s: %w", n.stream, err)
+	}
+	last := info.State.LastSeq
+
+	config := jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{n.subject(topic)},
+	}
+	switch {
+	case !from.Time.IsZero():
+		start := from.Time
+		config.DeliverPolicy = jetstream.DeliverByStartTimePolicy
+		config.OptStartTime = &start
+	case from.Offset > 0:
+		config.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		config.OptStartSeq = uint64(from.Offset)
+	}
+
+	consumer, err := stream.OrderedConsumer(ctx, config)
+	if err != nil {
+		return fmt.Errorf("failed to create replay consumer for %s: %w", topic, err)
+	}
+
+	for {
+		batch, err := consumer.Fetch(n.batchSize, jetstream.FetchMaxWait(n.fetchWait))
+		if err != nil {
+			return fmt.Errorf("failed to fetch from %s: %w", topic, err)
+		}
+
+		received := 0
+		for msg := range batch.Messages() {
+			received++
+
+			meta, err := msg.Metadata()
+			if err != nil {
+				return fmt.Errorf("failed to read metadata of message on %s: %w", topic, err)
+			}
+
+			event, err := decodeNATSMessage(msg.Data(), msg.Headers())
+			if err != nil {
+				return fmt.Errorf("failed to decode event at %s@%d: %w", topic, meta.Sequence.Stream, err)
+			}
+			if err := handler(ContextWithEvent(ctx, event), event); err != nil {
+				return fmt.Errorf("failed to replay event at %s@%d: %w", topic, meta.Sequence.Stream, err)
+			}
+
+			if meta.Sequence.Stream >= last || meta.NumPending == 0 {
+				return nil
+			}
+		}
+		if err := batch.Error(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("failed to fetch from %s: %w", topic, err)
+		}
+
+		// Nothing left on this subject up to the end of the stream
+		if received == 0 {
+			return ctx.Err()
+		}
+	}
+}
+
+var (
+	_ Replayer = (*KafkaEventBus)(nil)
+	_ Replayer = (*RedisEventBus)(nil)
+	_ Replayer = (*NATSEventBus)(nil)
+	_ Replayer = (*FileEventBus)(nil)
+)
@@ -0,0 +1,78 @@
+package events
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RetryPolicy describes how many times an operation is attempted and how long to wait between attempts
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     time.Minute,
+		Multiplier:     2,
+	}
+}
+
+// Backoff returns the delay before the given attempt (1-based) is retried
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 || p.InitialBackoff <= 0 {
+		return 0
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		backoff *= multiplier
+		if p.MaxBackoff > 0 && backoff >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+
+	return time.Duration(backoff)
+}
+
+// Exhausted reports whether no further attempts are allowed after the given attempt count
+func (p RetryPolicy) Exhausted(attempts int) bool {
+	return p.MaxAttempts > 0 && attempts >= p.MaxAttempts
+}
+
+// handleWithRetry invokes handler until it succeeds, the policy is exhausted or the
+// error is marked with DeadLetter, returning the number of attempts made and the last error
+func handleWithRetry(ctx context.Context, policy RetryPolicy, handler EventHandler, event *Event) (int, error) {
+	attempts := 0
+	for {
+		attempts++
+
+		err := handler(ctx, event)
+		if err == nil {
+			return attempts, nil
+		}
+
+		if policy.Exhausted(attempts) || IsDeadLetter(err) {
+			return attempts, err
+		}
+
+		log.Printf("Error handling event %s (attempt %d): %v", event.ID, attempts, err)
+
+		select {
+		case <-ctx.Done():
+			return attempts, err
+		case <-time.After(policy.Backoff(attempts)):
+		}
+	}
+}
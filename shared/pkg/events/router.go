@@ -0,0 +1,140 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// ErrUnknownEventType is returned for events no handler is registered for
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// DeadLetterError marks a failure that retrying cannot fix; buses that support
+// dead-lettering route the message there immediately
+type DeadLetterError struct {
+	Err error
+}
+
+func (e *DeadLetterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeadLetterError) Unwrap() error {
+	return e.Err
+}
+
+// DeadLetter wraps err so the message skips retries and goes to the dead-letter topic
+func DeadLetter(err error) error {
+	return &DeadLetterError{Err: err}
+}
+
+// IsDeadLetter reports whether err was marked with DeadLetter
+func IsDeadLetter(err error) bool {
+	var dlqErr *DeadLetterError
+	return errors.As(err, &dlqErr)
+}
+
+// UnknownEventPolicy decides what a Router does with events it has no handler for
+type UnknownEventPolicy int
+
+const (
+	// UnknownEventIgnore logs and acknowledges the event
+	UnknownEventIgnore UnknownEventPolicy = iota
+	// UnknownEventError fails the event so it is retried
+	UnknownEventError
+	// UnknownEventDeadLetter sends the event straight to the dead-letter topic
+	UnknownEventDeadLetter
+)
+
+// Router dispatches events to typed handlers by event type
+type Router struct {
+	mu            sync.RWMutex
+	handlers      map[string]EventHandler
+	unknownPolicy UnknownEventPolicy
+	middleware    []EventMiddleware
+}
+
+// RouterOption configures a Router
+type RouterOption func(*Router)
+
+// WithUnknownEventPolicy sets how events without a handler are treated
+func WithUnknownEventPolicy(policy UnknownEventPolicy) RouterOption {
+	return func(r *Router) {
+		r.unknownPolicy = policy
+	}
+}
+
+// WithRouterMiddleware wraps every handler of the router
+func WithRouterMiddleware(middleware ...EventMiddleware) RouterOption {
+	return func(r *Router) {
+		r.middleware = append(r.middleware, middleware...)
+	}
+}
+
+// NewRouter creates a new event router
+func NewRouter(opts ...RouterOption) *Router {
+	router := &Router{
+		handlers:      make(map[string]EventHandler),
+		unknownPolicy: UnknownEventIgnore,
+	}
+
+	for _, opt := range opts {
+		opt(router)
+	}
+
+	return router
+}
+
+// On registers fn for eventType. The payload is decoded into T before fn is called;
+// the event itself is available through EventFromContext. Middleware passed here
+// only wraps this handler, inside the router-wide middleware.
+func On[T any](r *Router, eventType string, fn func(ctx context.Context, data T) error, middleware ...EventMiddleware) {
+	handler := func(ctx context.Context, event *Event) error {
+		var data T
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return DeadLetter(fmt.Errorf("failed to decode %s payload: %w", eventType, err))
+		}
+		return fn(ctx, data)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[eventType] = Chain(handler, middleware...)
+}
+
+// Handle dispatches the event to its handler; it is an EventHandler
+func (r *Router) Handle(ctx context.Context, event *Event) error {
+	r.mu.RLock()
+	handler, exists := r.handlers[event.Type]
+	middleware := r.middleware
+	r.mu.RUnlock()
+
+	if !exists {
+		return r.handleUnknown(event)
+	}
+
+	return Chain(handler, middleware...)(ContextWithEvent(ctx, event), event)
+}
+
+// Subscribe routes every event published on topic through the router
+func (r *Router) Subscribe(ctx context.Context, bus EventBus, topic string, opts ...SubscribeOption) error {
+	return bus.Subscribe(ctx, topic, r.Handle, opts...)
+}
+
+func (r *Router) handleUnknown(event *Event) error {
+	err := fmt.Errorf("%w: %s", ErrUnknownEventType, event.Type)
+
+	switch r.unknownPolicy {
+	case UnknownEventError:
+		return err
+	case UnknownEventDeadLetter:
+		return DeadLetter(err)
+	default:
+		log.Printf("Ignoring event %s of unhandled type %s", event.ID, event.Type)
+		return nil
+	}
+}
@@ -0,0 +1,137 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"shared/pkg/events"
+)
+
+// newRouterBus subscribes router to user-events on a synchronous memory bus and returns
+// the dead letters the subscription produces
+func newRouterBus(t *testing.T, router *events.Router) (events.EventBus, *[]*events.Event) {
+	t.Helper()
+
+	bus := events.NewMemoryEventBus(events.WithSynchronousDelivery())
+	ctx := context.Background()
+
+	var dead []*events.Event
+	dlqHandler := func(ctx context.Context, event *events.Event) error {
+		dead = append(dead, event)
+		return nil
+	}
+	if err := router.Subscribe(ctx, bus, "user-events", events.WithRetryPolicy(fastRetry)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Subscribe(ctx, events.DeadLetterTopic("user-events"), dlqHandler); err != nil {
+		t.Fatal(err)
+	}
+	return bus, &dead
+}
+
+func TestRouterDecodesTypedPayloads(t *testing.T) {
+	router := events.NewRouter()
+	var got []events.UserQuotasResetData
+	var handled []string
+	events.On(router, events.UserQuotasResetEvent, func(ctx context.Context, data events.UserQuotasResetData) error {
+		got = append(got, data)
+		if event, ok := events.EventFromContext(ctx); ok {
+			handled = append(handled, event.ID)
+		}
+		return nil
+	})
+	bus, dead := newRouterBus(t, router)
+
+	event := publishUserEvent(t, bus, "user-events", "u42")
+	if len(got) != 1 || got[0].UserID != "u42" {
+		t.Errorf("handler got %+v, want the payload of user u42", got)
+	}
+	if len(handled) != 1 || handled[0] != event.ID {
+		t.Errorf("handler saw events %v in its context, want %s", handled, event.ID)
+	}
+	if len(*dead) != 0 {
+		t.Errorf("%d events dead-lettered, want none", len(*dead))
+	}
+}
+
+func TestRouterDeadLettersUndecodablePayloads(t *testing.T) {
+	router := events.NewRouter()
+	calls := 0
+	events.On(router, events.UserQuotasResetEvent, func(ctx context.Context, data events.UserQuotasResetData) error {
+		calls++
+		return nil
+	})
+	bus, dead := newRouterBus(t, router)
+
+	event, err := events.NewEvent(events.UserQuotasResetEvent, "test", "1.0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event.Data = json.RawMessage(`["not", "an", "object"]`)
+	if err := bus.Publish(context.Background(), "user-events", event); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 0 {
+		t.Errorf("handler called %d times with an undecodable payload", calls)
+	}
+	if len(*dead) != 1 {
+		t.Fatalf("%d events dead-lettered, want 1", len(*dead))
+	}
+	// The decode failure skips the retries
+	if got := (*dead)[0].Header(events.DeadLetterAttemptsHeader); got != "1" {
+		t.Errorf("dead-lettered after %s attempts, want 1", got)
+	}
+	if got := (*dead)[0].Header(events.DeadLetterErrorHeader); !strings.Contains(got, "failed to decode") {
+		t.Errorf("dead-letter error = %q, want the decode failure", got)
+	}
+}
+
+func TestRouterUnknownEventPolicies(t *testing.T) {
+	tests := []struct {
+		name             string
+		policy           events.UnknownEventPolicy
+		wantErr          bool
+		wantDeadLetter   bool
+		wantDeadLettered string // attempts header of the dead letter, "" for none
+	}{
+		{name: "ignore", policy: events.UnknownEventIgnore},
+		{name: "error", policy: events.UnknownEventError, wantErr: true, wantDeadLettered: "3"},
+		{name: "dead letter", policy: events.UnknownEventDeadLetter, wantErr: true, wantDeadLetter: true, wantDeadLettered: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := events.NewRouter(events.WithUnknownEventPolicy(tt.policy))
+			events.On(router, events.UserRegisteredEvent, func(ctx context.Context, data events.UserRegisteredData) error {
+				t.Error("handler of another event type called")
+				return nil
+			})
+
+			event, err := events.NewEvent(events.UserQuotasResetEvent, "test", "1.0", events.UserQuotasResetData{UserID: "u1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = router.Handle(context.Background(), event)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, events.ErrUnknownEventType)) {
+				t.Errorf("Handle() error = %v, want an unknown event type error: %v", err, tt.wantErr)
+			}
+			if events.IsDeadLetter(err) != tt.wantDeadLetter {
+				t.Errorf("Handle() error %v marked for dead-lettering: %v, want %v", err, events.IsDeadLetter(err), tt.wantDeadLetter)
+			}
+
+			bus, dead := newRouterBus(t, router)
+			publishTestEvent(t, bus, "user-events")
+			var attempts string
+			if len(*dead) == 1 {
+				attempts = (*dead)[0].Header(events.DeadLetterAttemptsHeader)
+			}
+			if len(*dead) > 1 || attempts != tt.wantDeadLettered {
+				t.Errorf("%d dead letters after %q attempts, want %q", len(*dead), attempts, tt.wantDeadLettered)
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"shared/pkg/database"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Saga statuses
+const (
+	SagaRunning     = "running"
+	SagaCompleted   = "completed"
+	SagaCompensated = "compensated"
+)
+
+// DefaultSagaPollInterval is how often the orchestrator looks for steps that timed out
+const DefaultSagaPollInterval = 5 * time.Second
+
+// ErrUnknownSaga is returned when starting a saga that was never registered
+var ErrUnknownSaga = errors.New("unknown saga")
+
+// SagaStep is one step of a saga. Action starts the step, usually by changing local state
+// and publishing an event another service reacts to. A step with CompletedBy waits for an
+// event of that type carrying the saga's ID (see HeaderSagaID) before the saga moves on;
+// other steps complete as soon as Action returns.
+//
+// When an event of type FailedBy arrives, the steps before the failed one are compensated
+// in reverse order. When Timeout passes first, the waiting step is compensated as well,
+// since its outcome is unknown; compensations must tolerate undoing a step that never took
+// effect.
+type SagaStep struct {
+	Name        string
+	Action      func(ctx context.Context, saga *Saga) error
+	Compensate  func(ctx context.Context, saga *Saga) error
+	CompletedBy string
+	FailedBy    string
+	Timeout     time.Duration
+}
+
+// SagaDefinition names a saga and its steps in order
+type SagaDefinition struct {
+	Name  string
+	Steps []SagaStep
+}
+
+// Saga is the persisted state of one saga instance
+type Saga struct {
+	ID        string     `db:"id"`
+	Name      string     `db:"name"`
+	Status    string     `db:"status"`
+	Step      int        `db:"step"`
+	Data      []byte     `db:"data"`
+	LastError string     `db:"last_error"`
+	Deadline  *time.Time `db:"deadline"`
+	CreatedAt time.Time  `db:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at"`
+}
+
+// Decode unmarshals the saga's data into v
+func (s *Saga) Decode(v interface{}) error {
+	return json.Unmarshal(s.Data, v)
+}
+
+// SetData replaces the saga's data, e.g. to keep what a step did for its compensation
+func (s *Saga) SetData(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal saga data: %w", err)
+	}
+	s.Data = data
+	return nil
+}
+
+type sagaIDKey struct{}
+
+// contextWithSagaID makes events published with the returned context part of the saga
+func contextWithSagaID(ctx context.Context, sagaID string) context.Context {
+	return context.WithValue(ctx, sagaIDKey{}, sagaID)
+}
+
+func sagaIDFromContext(ctx context.Context) (string, bool) {
+	sagaID, ok := ctx.Value(sagaIDKey{}).(string)
+	return sagaID, ok
+}
+
+// SagaOrchestrator runs sagas and keeps their state in the sagas table. Every transition
+// runs in one transaction together with the actions and compensations it invokes, so
+// their database changes and the events they record in the outbox commit with the new
+// state or not at all. Replies are idempotent: events that do not match the current step
+// of a running saga are ignored.
+type SagaOrchestrator struct {
+	db           *sqlx.DB
+	txManager    *database.TxManager
+	pollInterval time.Duration
+	batchSize    int
+
+	mu          sync.RWMutex
+	definitions map[string]SagaDefinition
+}
+
+// SagaOption configures a SagaOrchestrator
+type SagaOption func(*SagaOrchestrator)
+
+// WithSagaPollInterval sets how often the orchestrator looks for steps that timed out
+func WithSagaPollInterval(d time.Duration) SagaOption {
+	return func(o *SagaOrchestrator) {
+		o.pollInterval = d
+	}
+}
+
+// NewSagaOrchestrator creates an orchestrator storing sagas in db
+func NewSagaOrchestrator(db *sqlx.DB, opts ...SagaOption) *SagaOrchestrator {
+	orchestrator := &SagaOrchestrator{
+		db:           db,
+		txManager:    database.NewTxManager(db),
+		pollInterval: DefaultSagaPollInterval,
+		batchSize:    100,
+		definitions:  make(map[string]SagaDefinition),
+	}
+
+	for _, opt := range opts {
+		opt(orchestrator)
+	}
+
+	return orchestrator
+}
+
+// Register adds a saga definition, replacing one with the same name
+func (o *SagaOrchestrator) Register(definition SagaDefinition) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.definitions[definition.Name] = definition
+}
+
+func (o *SagaOrchestrator) definition(name string) (SagaDefinition, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	definition, ok := o.definitions[name]
+	return definition, ok
+}
+
+// Start creates a saga with the given data and runs its steps up to the first one that
+// waits for a reply. It joins the transaction carried by ctx, if any, so a failing step
+// rolls back the caller's changes along with the saga. It returns the saga's ID.
+func (o *SagaOrchestrator) Start(ctx context.Context, name string, data interface{}) (string, error) {
+	definition, ok := o.definition(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownSaga, name)
+	}
+
+	now := time.Now().UTC()
+	saga := &Saga{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Status:    SagaRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := saga.SetData(data); err != nil {
+		return "", err
+	}
+
+	err := o.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		query := `
+			INSERT INTO sagas (id, name, status, step, data, last_error, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, '', $6, $6)
+		`
+		if _, err := database.Executor(ctx, o.db).ExecContext(ctx, query, saga.ID, saga.Name, saga.Status, saga.Step, saga.Data, now); err != nil {
+			return fmt.Errorf("failed to create saga: %w", err)
+		}
+
+		return o.advance(ctx, definition, saga)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Saga started: %s %s", name, saga.ID)
+	return saga.ID, nil
+}
+
+// Handle is the EventHandler for replies to saga steps; subscribe it to the topics the
+// participants reply on
+func (o *SagaOrchestrator) Handle(ctx context.Context, event *Event) error {
+	sagaID := event.SagaID()
+	if sagaID == "" {
+		return nil
+	}
+
+	return o.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		saga, definition, ok, err := o.lock(ctx, sagaID, false)
+		if err != nil || !ok {
+			return err
+		}
+
+		step := definition.Steps[saga.Step]
+		switch event.Type {
+		case step.CompletedBy:
+			saga.Step++
+			return o.advance(ctx, definition, saga)
+		case step.FailedBy:
+			return o.compensate(ctx, definition, saga, saga.Step-1, fmt.Sprintf("step %s failed: %s %s", step.Name, event.Type, event.ID))
+		default:
+			return nil
+		}
+	})
+}
+
+// advance runs the steps from the current one until a step waits for a reply or the saga
+// is complete, then saves the saga
+func (o *SagaOrchestrator) advance(ctx context.Context, definition SagaDefinition, saga *Saga) error {
+	saga.Deadline = nil
+
+	for saga.Step < len(definition.Steps) {
+		step := definition.Steps[saga.Step]
+		if err := step.Action(contextWithSagaID(ctx, saga.ID), saga); err != nil {
+			return fmt.Errorf("saga %s step %s failed: %w", saga.ID, step.Name, err)
+		}
+
+		if step.CompletedBy != "" {
+			if step.Timeout > 0 {
+				deadline := time.Now().UTC().Add(step.Timeout)
+				saga.Deadline = &deadline
+			}
+			return o.save(ctx, saga)
+		}
+		saga.Step++
+	}
+
+	saga.Status = SagaCompleted
+	log.Printf("Saga completed: %s %s", saga.Name, saga.ID)
+	return o.save(ctx, saga)
+}
+
+// compensate undoes the steps from the given one back to the first, then saves the saga
+func (o *SagaOrchestrator) compensate(ctx context.Context, definition SagaDefinition, saga *Saga, from int, cause string) error {
+	// Compensations can read the cause from the saga
+	saga.LastError = cause
+
+	for i := from; i >= 0; i-- {
+		step := definition.Steps[i]
+		if step.Compensate == nil {
+			continue
+		}
+		if err := step.Compensate(contextWithSagaID(ctx, saga.ID), saga); err != nil {
+			return fmt.Errorf("failed to compensate step %s of saga %s: %w", step.Name, saga.ID, err)
+		}
+	}
+
+	saga.Status = SagaCompensated
+	saga.Deadline = nil
+	log.Printf("Saga compensated: %s %s (%s)", saga.Name, saga.ID, cause)
+	return o.save(ctx, saga)
+}
+
+// lock loads a running saga for update. It reports false for sagas that are unknown, not
+// running or not defined in this orchestrator, and, with skipLocked, locked elsewhere.
+func (o *SagaOrchestrator) lock(ctx context.Context, id string, skipLocked bool) (*Saga, SagaDefinition, bool, error) {
+	query := `
+		SELECT id, name, status, step, data, last_error, deadline, created_at, updated_at
+		FROM sagas WHERE id = $1
+		FOR UPDATE
+	`
+	if skipLocked {
+		query += " SKIP LOCKED"
+	}
+
+	var saga Saga
+	if err := database.Executor(ctx, o.db).GetContext(ctx, &saga, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, SagaDefinition{}, false, nil
+		}
+		return nil, SagaDefinition{}, false, fmt.Errorf("failed to load saga %s: %w", id, err)
+	}
+
+	definition, ok := o.definition(saga.Name)
+	if !ok || saga.Status != SagaRunning || saga.Step >= len(definition.Steps) {
+		return nil, SagaDefinition{}, false, nil
+	}
+	return &saga, definition, true, nil
+}
+
+func (o *SagaOrchestrator) save(ctx context.Context, saga *Saga) error {
+	saga.UpdatedAt = time.Now().UTC()
+
+	query := `
+		UPDATE sagas
+		SET status = $1, step = $2, data = $3, last_error = $4, deadline = $5, updated_at = $6
+		WHERE id = $7
+	`
+	_, err := database.Executor(ctx, o.db).ExecContext(ctx, query,
+		saga.Status,
+		saga.Step,
+		saga.Data,
+		saga.LastError,
+		saga.Deadline,
+		saga.UpdatedAt,
+		saga.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save saga %s: %w", saga.ID, err)
+	}
+	return nil
+}
+
+// Run compensates sagas whose waiting step timed out until ctx is cancelled
+func (o *SagaOrchestrator) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := o.TimeOutExpired(ctx); err != nil {
+			log.Printf("Error timing out sagas: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// TimeOutExpired compensates one batch of sagas past their step's deadline and returns how
+// many it looked at. A saga whose compensation fails keeps running, records the error and
+// is retried on the next call.
+func (o *SagaOrchestrator) TimeOutExpired(ctx context.Context) (int, error) {
+	query := `
+		SELECT id FROM sagas
+		WHERE status = $1 AND deadline <= $2
+		ORDER BY deadline
+		LIMIT $3
+	`
+
+	var ids []string
+	if err := o.db.SelectContext(ctx, &ids, query, SagaRunning, time.Now().UTC(), o.batchSize); err != nil {
+		return 0, fmt.Errorf("failed to load expired sagas: %w", err)
+	}
+
+	for _, id := range ids {
+		err := o.txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+			saga, definition, ok, err := o.lock(ctx, id, true)
+			if err != nil || !ok || saga.Deadline == nil || saga.Deadline.After(time.Now()) {
+				return err
+			}
+
+			step := definition.Steps[saga.Step]
+			return o.compensate(ctx, definition, saga, saga.Step, fmt.Sprintf("step %s timed out", step.Name))
+		})
+		if err != nil {
+			log.Printf("Failed to time out saga %s: %v", id, err)
+			o.recordError(ctx, id, err)
+		}
+	}
+
+	return len(ids), nil
+}
+
+// recordError notes why a saga is stuck, outside the transaction that failed
+func (o *SagaOrchestrator) recordError(ctx context.Context, id string, cause error) {
+	query := `UPDATE sagas SET last_error = $1, updated_at = $2 WHERE id = $3`
+	if _, err := o.db.ExecContext(ctx, query, cause.Error(), time.Now().UTC(), id); err != nil {
+		log.Printf("Failed to record error of saga %s: %v", id, err)
+	}
+}
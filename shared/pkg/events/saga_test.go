@@ -0,0 +1,279 @@
+package events_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"shared/pkg/database/databasetest"
+	"shared/pkg/events"
+)
+
+// sagaTable keeps the sagas table of a stand-in database in memory
+type sagaTable struct {
+	mu    sync.Mutex
+	sagas map[string]events.Saga
+}
+
+func newSagaDB() (*databasetest.DB, *sagaTable) {
+	table := &sagaTable{sagas: make(map[string]events.Saga)}
+	return &databasetest.DB{Query: table.query, Exec: table.exec}, table
+}
+
+func (s *sagaTable) query(query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query = strings.Join(strings.Fields(query), " ")
+	switch {
+	case strings.HasPrefix(query, "SELECT id FROM sagas"):
+		var rows [][]driver.Value
+		for id, saga := range s.sagas {
+			if saga.Status == args[0].Value && saga.Deadline != nil && !saga.Deadline.After(args[1].Value.(time.Time)) {
+				rows = append(rows, []driver.Value{id})
+			}
+		}
+		return []string{"id"}, rows, nil
+	case strings.HasPrefix(query, "SELECT id, name, status"):
+		saga, ok := s.sagas[args[0].Value.(string)]
+		if !ok {
+			return nil, nil, nil
+		}
+		var deadline driver.Value
+		if saga.Deadline != nil {
+			deadline = *saga.Deadline
+		}
+		columns := []string{"id", "name", "status", "step", "data", "last_error", "deadline", "created_at", "updated_at"}
+		row := []driver.Value{saga.ID, saga.Name, saga.Status, int64(saga.Step), saga.Data, saga.LastError, deadline, saga.CreatedAt, saga.UpdatedAt}
+		return columns, [][]driver.Value{row}, nil
+	default:
+		return nil, nil, nil
+	}
+}
+
+func (s *sagaTable) exec(query string, args []driver.NamedValue) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query = strings.Join(strings.Fields(query), " ")
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO sagas"):
+		id := args[0].Value.(string)
+		s.sagas[id] = events.Saga{
+			ID:        id,
+			Name:      args[1].Value.(string),
+			Status:    args[2].Value.(string),
+			Step:      int(args[3].Value.(int64)),
+			Data:      args[4].Value.([]byte),
+			CreatedAt: args[5].Value.(time.Time),
+			UpdatedAt: args[5].Value.(time.Time),
+		}
+	case strings.HasPrefix(query, "UPDATE sagas SET status"):
+		saga := s.sagas[args[6].Value.(string)]
+		saga.Status = args[0].Value.(string)
+		saga.Step = int(args[1].Value.(int64))
+		saga.Data = args[2].Value.([]byte)
+		saga.LastError = args[3].Value.(string)
+		saga.Deadline = nil
+		if deadline, ok := args[4].Value.(time.Time); ok {
+			saga.Deadline = &deadline
+		}
+		saga.UpdatedAt = args[5].Value.(time.Time)
+		s.sagas[saga.ID] = saga
+	case strings.HasPrefix(query, "UPDATE sagas SET last_error"):
+		saga := s.sagas[args[2].Value.(string)]
+		saga.LastError = args[0].Value.(string)
+		s.sagas[saga.ID] = saga
+	}
+	return 1, nil
+}
+
+func (s *sagaTable) get(id string) events.Saga {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sagas[id]
+}
+
+// stepLog records the actions and compensations a saga ran, in order
+type stepLog struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (l *stepLog) record(step string) func(ctx context.Context, saga *events.Saga) error {
+	return func(ctx context.Context, saga *events.Saga) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.steps = append(l.steps, step)
+		return nil
+	}
+}
+
+func (l *stepLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.steps...)
+}
+
+const (
+	paymentRequestedEvent = "payment.requested"
+	paymentCompletedEvent = "payment.completed"
+	paymentFailedEvent    = "payment.failed"
+)
+
+// orderSaga is a running order saga and what it ran
+type orderSaga struct {
+	orchestrator *events.SagaOrchestrator
+	bus          *events.MemoryEventBus
+	table        *sagaTable
+	steps        *stepLog
+	id           string
+}
+
+// startOrderSaga runs an order saga whose charge step asks a payment participant on the
+// memory bus, which answers with replies. One worker per topic hands the orchestrator its
+// replies in turn, as the row lock on the saga would.
+func startOrderSaga(t *testing.T, timeout time.Duration, replies ...string) orderSaga {
+	t.Helper()
+
+	bus := events.NewMemoryEventBus(events.WithWorkers(1))
+	t.Cleanup(func() { bus.Close() })
+	db, table := newSagaDB()
+	orchestrator := events.NewSagaOrchestrator(db.Open())
+	steps := &stepLog{}
+	ctx := context.Background()
+
+	orchestrator.Register(events.SagaDefinition{
+		Name: "order",
+		Steps: []events.SagaStep{
+			{Name: "reserve", Action: steps.record("reserve"), Compensate: steps.record("release")},
+			{Name: "notify", Action: steps.record("notify"), Compensate: steps.record("retract")},
+			{
+				Name: "charge",
+				Action: func(ctx context.Context, saga *events.Saga) error {
+					steps.record("charge")(ctx, saga)
+					event, err := events.NewEvent(paymentRequestedEvent, "orders", "1.0", map[string]string{})
+					if err != nil {
+						return err
+					}
+					return bus.Publish(ctx, "payments", event)
+				},
+				Compensate:  steps.record("refund"),
+				CompletedBy: paymentCompletedEvent,
+				FailedBy:    paymentFailedEvent,
+				Timeout:     timeout,
+			},
+			{Name: "confirm", Action: steps.record("confirm")},
+		},
+	})
+
+	participant := func(ctx context.Context, request *events.Event) error {
+		for _, reply := range replies {
+			event, err := events.NewEvent(reply, "payments", "1.0", map[string]string{})
+			if err != nil {
+				return err
+			}
+			// The request being handled carries the saga's ID over to the reply
+			if err := bus.Publish(ctx, "payment-replies", event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := bus.Subscribe(ctx, "payments", participant); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Subscribe(ctx, "payment-replies", orchestrator.Handle); err != nil {
+		t.Fatal(err)
+	}
+
+	sagaID, err := orchestrator.Start(ctx, "order", map[string]string{"order": "o1"})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	return orderSaga{orchestrator: orchestrator, bus: bus, table: table, steps: steps, id: sagaID}
+}
+
+func (o orderSaga) waitFor(t *testing.T, status string) events.Saga {
+	t.Helper()
+
+	waitFor(t, "saga to be "+status, func() bool {
+		return o.table.get(o.id).Status == status
+	})
+	return o.table.get(o.id)
+}
+
+func TestSagaOrchestratorCompletesOnReply(t *testing.T) {
+	order := startOrderSaga(t, time.Minute, paymentCompletedEvent)
+
+	saga := order.waitFor(t, events.SagaCompleted)
+	if want := []string{"reserve", "notify", "charge", "confirm"}; !reflect.DeepEqual(order.steps.get(), want) {
+		t.Errorf("ran %v, want %v", order.steps.get(), want)
+	}
+	if saga.Step != 4 || saga.Deadline != nil {
+		t.Errorf("completed saga at step %d with deadline %v, want step 4 and no deadline", saga.Step, saga.Deadline)
+	}
+}
+
+func TestSagaOrchestratorCompensatesInReverseOnFailure(t *testing.T) {
+	order := startOrderSaga(t, time.Minute, paymentFailedEvent)
+
+	saga := order.waitFor(t, events.SagaCompensated)
+	// The failed step took no effect, so only the steps before it are compensated
+	if want := []string{"reserve", "notify", "charge", "retract", "release"}; !reflect.DeepEqual(order.steps.get(), want) {
+		t.Errorf("ran %v, want %v", order.steps.get(), want)
+	}
+	if !strings.Contains(saga.LastError, "step charge failed") {
+		t.Errorf("last error = %q, want the failed step", saga.LastError)
+	}
+}
+
+func TestSagaOrchestratorCompensatesTimedOutStep(t *testing.T) {
+	order := startOrderSaga(t, time.Millisecond)
+
+	waitFor(t, "saga to time out", func() bool {
+		if _, err := order.orchestrator.TimeOutExpired(context.Background()); err != nil {
+			t.Fatalf("TimeOutExpired() error = %v", err)
+		}
+		return order.table.get(order.id).Status == events.SagaCompensated
+	})
+
+	// The outcome of the waiting step is unknown, so it is compensated as well
+	if want := []string{"reserve", "notify", "charge", "refund", "retract", "release"}; !reflect.DeepEqual(order.steps.get(), want) {
+		t.Errorf("ran %v, want %v", order.steps.get(), want)
+	}
+	if saga := order.table.get(order.id); !strings.Contains(saga.LastError, "step charge timed out") {
+		t.Errorf("last error = %q, want the timed out step", saga.LastError)
+	}
+}
+
+func TestSagaOrchestratorIgnoresDuplicateReplies(t *testing.T) {
+	order := startOrderSaga(t, time.Minute, paymentCompletedEvent, paymentCompletedEvent, paymentFailedEvent)
+	ctx := context.Background()
+
+	order.waitFor(t, events.SagaCompleted)
+	if err := order.bus.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replies to a finished saga are ignored too, even when handled directly
+	reply, err := events.NewEvent(paymentCompletedEvent, "payments", "1.0", map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply.SetHeader(events.HeaderSagaID, order.id)
+	if err := order.orchestrator.Handle(ctx, reply); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	if want := []string{"reserve", "notify", "charge", "confirm"}; !reflect.DeepEqual(order.steps.get(), want) {
+		t.Errorf("ran %v, want %v", order.steps.get(), want)
+	}
+	if saga := order.table.get(order.id); saga.Status != events.SagaCompleted || saga.Step != 4 {
+		t.Errorf("saga %s at step %d, want completed at step 4", saga.Status, saga.Step)
+	}
+}
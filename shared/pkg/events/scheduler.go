@@ -0,0 +1,253 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"shared/pkg/database"
+	"shared/pkg/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SchedulerConfig configures the delivery of scheduled events
+type SchedulerConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+	// Retry spaces out attempts to publish a due event; MaxAttempts 0 retries until it succeeds
+	Retry RetryPolicy
+}
+
+// DefaultSchedulerConfig returns sensible defaults for the scheduler
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		BatchSize:    100,
+		PollInterval: time.Second,
+		Retry: RetryPolicy{
+			InitialBackoff: time.Second,
+			MaxBackoff:     5 * time.Minute,
+			Multiplier:     2,
+		},
+	}
+}
+
+// EventScheduler is an EventBus that can also publish events later. Scheduled events are
+// kept in the scheduled_events table, written in the caller's transaction when ctx carries
+// one, so they survive restarts. Run publishes them to the underlying bus once they are due
+// and marks them delivered in the same transaction that locked them, so every event is
+// published at least once; a crash between the two publishes it again.
+type EventScheduler struct {
+	db     *sqlx.DB
+	bus    EventBus
+	config SchedulerConfig
+}
+
+// NewEventScheduler creates a scheduler publishing to bus; subscriptions are delegated to bus
+func NewEventScheduler(db *sqlx.DB, bus EventBus, config SchedulerConfig) *EventScheduler {
+	defaults := DefaultSchedulerConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.Retry == (RetryPolicy{}) {
+		config.Retry = defaults.Retry
+	}
+
+	return &EventScheduler{
+		db:     db,
+		bus:    bus,
+		config: config,
+	}
+}
+
+// Publish publishes the event immediately on the underlying bus
+func (s *EventScheduler) Publish(ctx context.Context, topic string, event *Event) error {
+	return s.bus.Publish(ctx, topic, event)
+}
+
+// PublishAt schedules the event for delivery at the given time; times in the past are due
+// immediately. Scheduling an event ID again is a no-op, cancel it first to reschedule.
+func (s *EventScheduler) PublishAt(ctx context.Context, topic string, event *Event, at time.Time) error {
+	// Capture trace and correlation now; the scheduler publishes from its own context
+	injectMetadata(ctx, event)
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	query := `
+		INSERT INTO scheduled_events (event_id, topic, event_type, payload, deliver_at, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $5, $6)
+		ON CONFLICT (event_id) DO NOTHING
+	`
+
+	_, err = database.Executor(ctx, s.db).ExecContext(ctx, query,
+		event.ID,
+		topic,
+		event.Type,
+		payload,
+		at.UTC(),
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to schedule event: %w", err)
+	}
+
+	log.Printf("Event scheduled: %s to topic: %s at %s", event.Type, topic, at.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// PublishAfter schedules the event for delivery once delay has passed
+func (s *EventScheduler) PublishAfter(ctx context.Context, topic string, event *Event, delay time.Duration) error {
+	return s.PublishAt(ctx, topic, event, time.Now().Add(delay))
+}
+
+// Cancel stops a scheduled event from being delivered. It reports false when no pending
+// event has that ID, e.g. because it was already delivered or cancelled.
+func (s *EventScheduler) Cancel(ctx context.Context, eventID string) (bool, error) {
+	query := `
+		UPDATE scheduled_events SET cancelled_at = $1
+		WHERE event_id = $2 AND delivered_at IS NULL AND cancelled_at IS NULL
+	`
+
+	result, err := database.Executor(ctx, s.db).ExecContext(ctx, query, time.Now().UTC(), eventID)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel scheduled event %s: %w", eventID, err)
+	}
+
+	cancelled, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return cancelled > 0, nil
+}
+
+// Subscribe registers a handler on the underlying bus
+func (s *EventScheduler) Subscribe(ctx context.Context, topic string, handler EventHandler, opts ...SubscribeOption) error {
+	return s.bus.Subscribe(ctx, topic, handler, opts...)
+}
+
+// Close is a no-op; the underlying bus is owned and closed by the caller
+func (s *EventScheduler) Close() error {
+	return nil
+}
+
+type scheduledRow struct {
+	ID       int64  `db:"id"`
+	Topic    string `db:"topic"`
+	Payload  []byte `db:"payload"`
+	Attempts int    `db:"attempts"`
+}
+
+// Run delivers due events until ctx is cancelled
+func (s *EventScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			delivered, err := s.DeliverDue(ctx)
+			if err != nil {
+				log.Printf("Error delivering scheduled events: %v", err)
+				break
+			}
+			// Keep delivering while full batches are coming back
+			if delivered < s.config.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue publishes one batch of due events and returns how many were handled. Several
+// schedulers may share the table; each due event is locked by one of them.
+func (s *EventScheduler) DeliverDue(ctx context.Context) (int, error) {
+	// Events are marked delivered once published, so publishes wait for the broker's
+	// acknowledgement even on a bus with an async producer
+	ctx = withAcknowledgedWrites(ctx)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin scheduler transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT id, topic, payload, attempts
+		FROM scheduled_events
+		WHERE delivered_at IS NULL AND cancelled_at IS NULL
+			AND next_attempt_at <= $1 AND ($2 = 0 OR attempts < $2)
+		ORDER BY next_attempt_at, id
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	`
+
+	var rows []scheduledRow
+	now := time.Now().UTC()
+	if err := tx.SelectContext(ctx, &rows, query, now, s.config.Retry.MaxAttempts, s.config.BatchSize); err != nil {
+		return 0, fmt.Errorf("failed to load scheduled events: %w", err)
+	}
+
+	for _, row := range rows {
+		if err := s.deliver(ctx, row); err != nil {
+			attempts := row.Attempts + 1
+			log.Printf("Failed to deliver scheduled event %d (attempt %d): %v", row.ID, attempts, err)
+
+			if err := s.markFailed(ctx, tx, row.ID, attempts, err); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if err := s.markDelivered(ctx, tx, row.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit scheduler transaction: %w", err)
+	}
+
+	return len(rows), nil
+}
+
+func (s *EventScheduler) deliver(ctx context.Context, row scheduledRow) error {
+	var event Event
+	if err := json.Unmarshal(row.Payload, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal scheduled payload: %w", err)
+	}
+
+	return s.bus.Publish(tracing.ExtractKafkaTrace(ctx, event.Headers), row.Topic, &event)
+}
+
+func (s *EventScheduler) markDelivered(ctx context.Context, tx *sqlx.Tx, id int64) error {
+	query := `UPDATE scheduled_events SET delivered_at = $1, attempts = attempts + 1, last_error = NULL WHERE id = $2`
+	if _, err := tx.ExecContext(ctx, query, time.Now().UTC(), id); err != nil {
+		return fmt.Errorf("failed to mark scheduled event %d as delivered: %w", id, err)
+	}
+	return nil
+}
+
+func (s *EventScheduler) markFailed(ctx context.Context, tx *sqlx.Tx, id int64, attempts int, cause error) error {
+	query := `UPDATE scheduled_events SET attempts = $1, last_error = $2, next_attempt_at = $3 WHERE id = $4`
+
+	nextAttempt := time.Now().UTC().Add(s.config.Retry.Backoff(attempts))
+	lastError := sql.NullString{String: cause.Error(), Valid: true}
+	if _, err := tx.ExecContext(ctx, query, attempts, lastError, nextAttempt, id); err != nil {
+		return fmt.Errorf("failed to record delivery failure for scheduled event %d: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"shared/pkg/database/databasetest"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ackFailingWriter accepts every write without waiting for the broker, like an async
+// producer, or fails it like a broker that never acknowledges
+type ackFailingWriter struct {
+	fail bool
+}
+
+func (w *ackFailingWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.fail {
+		return errors.New("not enough replicas")
+	}
+	return nil
+}
+
+func (w *ackFailingWriter) Close() error { return nil }
+
+func TestSchedulerLeavesEventPendingWhenWriteIsNotAcknowledged(t *testing.T) {
+	config := DefaultKafkaProducerConfig()
+	config.Async = true
+	bus := NewKafkaEventBus([]string{"localhost:9092"}, WithProducerConfig(config))
+	defer bus.Close()
+	bus.writer = &ackFailingWriter{}
+	bus.ackWriter = &ackFailingWriter{fail: true}
+
+	event, err := NewEvent(UserQuotasResetEvent, "test", "1.0", UserQuotasResetData{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &databasetest.DB{
+		Columns:      []string{"id", "topic", "payload", "attempts"},
+		Rows:         [][]driver.Value{{int64(1), "user-events", payload, int64(0)}},
+		RowsAffected: 1,
+	}
+	scheduler := NewEventScheduler(db.Open(), bus, DefaultSchedulerConfig())
+
+	if _, err := scheduler.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+
+	execs := db.Executed()
+	if len(execs) != 1 {
+		t.Fatalf("executed %d statements, want 1: %v", len(execs), execs)
+	}
+	if strings.Contains(execs[0], "delivered_at") || !strings.Contains(execs[0], "last_error = $2") {
+		t.Errorf("scheduled event was not left pending with its error: %s", execs[0])
+	}
+}
@@ -0,0 +1,104 @@
+package events_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"shared/pkg/events"
+	"shared/pkg/events/eventstest"
+)
+
+func TestSchemasUnchanged(t *testing.T) {
+	eventstest.AssertSchemasUnchanged(t, events.DefaultRegistry())
+}
+
+func TestFixturesValid(t *testing.T) {
+	eventstest.AssertFixturesValid(t, events.DefaultRegistry())
+}
+
+type greetingV1 struct {
+	Name string `json:"name"`
+}
+
+type greetingV2 struct {
+	FirstName string `json:"first_name"`
+}
+
+func newGreetingRegistry(t *testing.T) *events.Registry {
+	t.Helper()
+
+	registry := events.NewRegistry()
+	for version, payload := range map[string]interface{}{"1.0": greetingV1{}, "2.0": greetingV2{}} {
+		schema, err := json.Marshal(events.GenerateSchema("greeting", version, reflect.TypeOf(payload)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		registry.MustRegister("greeting", version, payload, schema)
+	}
+	return registry
+}
+
+func TestUpcast(t *testing.T) {
+	registry := newGreetingRegistry(t)
+	registry.RegisterUpcaster("greeting", "1.0", "2.0", func(data json.RawMessage) (json.RawMessage, error) {
+		var v1 greetingV1
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return nil, err
+		}
+		return json.Marshal(greetingV2{FirstName: v1.Name})
+	})
+
+	event, err := events.NewEvent("greeting", "test", "1.0", greetingV1{Name: "Ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Upcast(event); err != nil {
+		t.Fatalf("Upcast() error = %v", err)
+	}
+	if event.Version != "2.0" || event.DataSchema != events.DataSchemaURI("greeting", "2.0") {
+		t.Errorf("upcast event has version %s and schema %s", event.Version, event.DataSchema)
+	}
+	if err := registry.Validate(event); err != nil {
+		t.Errorf("upcast payload does not match the 2.0 schema: %v", err)
+	}
+}
+
+func TestUpcastErrorsAreDeadLettered(t *testing.T) {
+	tests := []struct {
+		name     string
+		upcaster events.UpcastFunc
+	}{
+		{name: "no upcaster"},
+		{name: "upcaster fails", upcaster: func(json.RawMessage) (json.RawMessage, error) {
+			return nil, errors.New("cannot split name")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newGreetingRegistry(t)
+			if tt.upcaster != nil {
+				registry.RegisterUpcaster("greeting", "1.0", "2.0", tt.upcaster)
+			}
+
+			event, err := events.NewEvent("greeting", "test", "1.0", greetingV1{Name: "Ada"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = registry.Upcast(event)
+			if err == nil {
+				t.Fatal("Upcast() succeeded, want an error")
+			}
+			if !events.IsDeadLetter(err) {
+				t.Errorf("Upcast() error %v is not marked for dead-lettering", err)
+			}
+			if event.Version != "1.0" {
+				t.Errorf("failed upcast changed the version to %s", event.Version)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"time"
+)
+
+// SubscribeOption configures a single subscription
+type SubscribeOption func(*SubscribeConfig)
+
+// Start offsets for consumer groups without a committed offset
+const (
+	StartOffsetEarliest int64 = -2
+	StartOffsetLatest   int64 = -1
+)
+
+// CommitMode controls when consumed offsets are committed
+type CommitMode int
+
+const (
+	// CommitModeSync commits every message's offset as soon as it is processed
+	CommitModeSync CommitMode = iota
+	// CommitModeInterval commits processed offsets in the background every CommitInterval
+	CommitModeInterval
+)
+
+// SubscribeConfig holds the per-subscription settings collected from SubscribeOptions.
+// Zero values for broker settings mean "use the bus default".
+type SubscribeConfig struct {
+	Retry          RetryPolicy
+	DeadLetter     bool
+	GroupID        string
+	StartOffset    int64
+	MinBytes       int
+	MaxBytes       int
+	CommitMode     CommitMode
+	CommitInterval time.Duration
+}
+
+// WithRetryPolicy sets how failed handler invocations are retried
+func WithRetryPolicy(policy RetryPolicy) SubscribeOption {
+	return func(c *SubscribeConfig) {
+		c.Retry = policy
+	}
+}
+
+// WithDeadLetter enables or disables routing exhausted messages to the dead-letter topic
+func WithDeadLetter(enabled bool) SubscribeOption {
+	return func(c *SubscribeConfig) {
+		c.DeadLetter = enabled
+	}
+}
+
+// WithGroupID sets the consumer group of the subscription
+func WithGroupID(groupID string) SubscribeOption {
+	return func(c *SubscribeConfig) {
+		c.GroupID = groupID
+	}
+}
+
+// WithStartOffset sets where a new consumer group starts reading, see StartOffsetEarliest and StartOffsetLatest
+func WithStartOffset(offset int64) SubscribeOption {
+	return func(c *SubscribeConfig) {
+		c.StartOffset = offset
+	}
+}
+
+// WithFetchBytes sets the minimum and maximum number of bytes fetched per request
+func WithFetchBytes(minBytes, maxBytes int) SubscribeOption {
+	return func(c *SubscribeConfig) {
+		c.MinBytes = minBytes
+		c.MaxBytes = maxBytes
+	}
+}
+
+// WithCommitMode sets how offsets are committed; interval only applies to CommitModeInterval
+func WithCommitMode(mode CommitMode, interval time.Duration) SubscribeOption {
+	return func(c *SubscribeConfig) {
+		c.CommitMode = mode
+		c.CommitInterval = interval
+	}
+}
+
+func newSubscribeConfig(opts ...SubscribeOption) SubscribeConfig {
+	config := SubscribeConfig{
+		Retry:       DefaultRetryPolicy(),
+		DeadLetter:  true,
+		StartOffset: StartOffsetEarliest,
+		CommitMode:  CommitModeSync,
+	}
+
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return config
+}
+
+func (c SubscribeConfig) commitInterval() time.Duration {
+	if c.CommitMode != CommitModeInterval {
+		return 0
+	}
+	if c.CommitInterval <= 0 {
+		return time.Second
+	}
+	return c.CommitInterval
+}
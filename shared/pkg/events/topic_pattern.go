@@ -0,0 +1,72 @@
+package events
+
+import (
+	"strings"
+)
+
+// TopicWildcard matches any run of characters in a subscription pattern
+const TopicWildcard = "*"
+
+// IsTopicPattern reports whether a subscription topic is a pattern such as "user.*" or
+// "*.upgraded" rather than the name of a single topic
+func IsTopicPattern(topic string) bool {
+	return strings.Contains(topic, TopicWildcard)
+}
+
+// MatchTopicPattern reports whether name matches pattern, where each "*" matches any
+// run of characters, including none and including dots
+func MatchTopicPattern(pattern, name string) bool {
+	parts := strings.Split(pattern, TopicWildcard)
+	if len(parts) == 1 {
+		return pattern == name
+	}
+
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+
+	return strings.HasSuffix(name, last)
+}
+
+// subscriptionMatches reports whether an event published on topic is delivered to a
+// subscription; patterns match either the topic or the event type
+func subscriptionMatches(subscription, topic string, event *Event) bool {
+	if !IsTopicPattern(subscription) {
+		return subscription == topic
+	}
+	return MatchTopicPattern(subscription, topic) || MatchTopicPattern(subscription, event.Type)
+}
+
+// patternTopics returns the topics a pattern subscription has to read. Any topic may carry
+// an event type matching the pattern, so that is every topic except broker-internal ones
+// and dead-letter topics, which are only included for patterns ending in ".dlq".
+func patternTopics(pattern string, topics []string) []string {
+	var matched []string
+	for _, topic := range topics {
+		if patternReads(pattern, topic) {
+			matched = append(matched, topic)
+		}
+	}
+	return matched
+}
+
+// patternReads reports whether a pattern subscription reads topic, see patternTopics
+func patternReads(pattern, topic string) bool {
+	if strings.HasPrefix(topic, "__") {
+		return false
+	}
+	if strings.HasSuffix(topic, deadLetterTopicSuffix) && !strings.HasSuffix(pattern, deadLetterTopicSuffix) {
+		return false
+	}
+	return true
+}
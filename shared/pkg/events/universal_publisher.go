@@ -2,9 +2,9 @@ package events
 
 import (
 	"context"
-	"log"
-	"shared/pkg/domain"
 	"time"
+
+	"shared/pkg/domain"
 )
 
 // UniversalEventPublisher works with any EventBus implementation
@@ -20,25 +20,8 @@ func NewUniversalEventPublisher(eventBus EventBus) *UniversalEventPublisher {
 }
 
 // PublishUserRegistered publishes user registered event
-func (u *UniversalEventPublisher) PublishUserRegistered(ctx context.Context, user interface{}) error {
-	// Handle different user types
-	var data UserRegisteredData
-
-	switch user := user.(type) {
-	case map[string]interface{}:
-		data = UserRegisteredData{
-			UserID:    user["id"].(string),
-			Email:     user["email"].(string),
-			FullName:  user["full_name"].(string),
-			Tier:      user["tier"].(string),
-			CreatedAt: user["created_at"].(string),
-		}
-	default:
-		// If it's a domain user, convert it
-		// For now, just log and return
-		log.Printf("Unsupported user type: %T", user)
-		return nil
-	}
+func (u *UniversalEventPublisher) PublishUserRegistered(ctx context.Context, user *domain.User) error {
+	data := NewUserRegisteredData(user)
 
 	event, err := NewEvent(
 		UserRegisteredEvent,
@@ -49,16 +32,17 @@ func (u *UniversalEventPublisher) PublishUserRegistered(ctx context.Context, use
 	if err != nil {
 		return err
 	}
+	event.Subject = data.UserID
 
 	return u.eventBus.Publish(ctx, "user-events", event)
 }
 
 // PublishUserTierUpgraded publishes user tier upgraded event
-func (u *UniversalEventPublisher) PublishUserTierUpgraded(ctx context.Context, userID string, oldTier, newTier interface{}) error {
+func (u *UniversalEventPublisher) PublishUserTierUpgraded(ctx context.Context, userID string, oldTier, newTier domain.UserTier) error {
 	data := UserTierUpgradedData{
 		UserID:     userID,
-		OldTier:    convertToString(oldTier),
-		NewTier:    convertToString(newTier),
+		OldTier:    string(oldTier),
+		NewTier:    string(newTier),
 		UpgradedAt: time.Now().UTC().Format(time.RFC3339),
 	}
 
@@ -71,24 +55,114 @@ func (u *UniversalEventPublisher) PublishUserTierUpgraded(ctx context.Context, u
 	if err != nil {
 		return err
 	}
+	event.Subject = userID
+
+	return u.eventBus.Publish(ctx, "user-events", event)
+}
+
+// PublishUserQuotaProvisioned reports that a user's quotas match their new tier
+func (u *UniversalEventPublisher) PublishUserQuotaProvisioned(ctx context.Context, userID string, tier domain.UserTier) error {
+	data := UserQuotaProvisionedData{
+		UserID:        userID,
+		Tier:          string(tier),
+		ProvisionedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	event, err := NewEvent(
+		UserQuotaProvisionedEvent,
+		"user-service",
+		"1.0",
+		data,
+	)
+	if err != nil {
+		return err
+	}
+	event.Subject = userID
+
+	return u.eventBus.Publish(ctx, "user-events", event)
+}
+
+// PublishUserQuotaProvisioningFailed reports that a user's quotas could not be changed to
+// their new tier
+func (u *UniversalEventPublisher) PublishUserQuotaProvisioningFailed(ctx context.Context, userID string, reason string) error {
+	data := UserQuotaProvisioningFailedData{
+		UserID:   userID,
+		Reason:   reason,
+		FailedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	event, err := NewEvent(
+		UserQuotaProvisioningFailedEvent,
+		"user-service",
+		"1.0",
+		data,
+	)
+	if err != nil {
+		return err
+	}
+	event.Subject = userID
+
+	return u.eventBus.Publish(ctx, "user-events", event)
+}
+
+// PublishUserTierReverted publishes user tier reverted event, the compensation of an upgrade
+func (u *UniversalEventPublisher) PublishUserTierReverted(ctx context.Context, userID string, fromTier, toTier domain.UserTier, reason string) error {
+	data := UserTierRevertedData{
+		UserID:     userID,
+		FromTier:   string(fromTier),
+		ToTier:     string(toTier),
+		Reason:     reason,
+		RevertedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	event, err := NewEvent(
+		UserTierRevertedEvent,
+		"auth-service",
+		"1.0",
+		data,
+	)
+	if err != nil {
+		return err
+	}
+	event.Subject = userID
 
 	return u.eventBus.Publish(ctx, "user-events", event)
 }
 
 // PublishUserQuotaUpdated publishes user quota updated event
-func (u *UniversalEventPublisher) PublishUserQuotaUpdated(ctx context.Context, userID string, quotas interface{}) error {
-	// For now, just log that we received the event
-	log.Printf("User quota updated event for user: %s", userID)
+func (u *UniversalEventPublisher) PublishUserQuotaUpdated(ctx context.Context, userID string, quotas domain.QuotaInfo) error {
+	event, err := newUserQuotaUpdatedEvent(userID, quotas)
+	if err != nil {
+		return err
+	}
+
+	return u.eventBus.Publish(ctx, "user-events", event)
+}
 
-	// TODO: Implement proper quota data extraction
-	// This is a placeholder implementation
+// UserQuotas are the quotas of one user, see PublishUserQuotasUpdated
+type UserQuotas struct {
+	UserID string
+	Quotas domain.QuotaInfo
+}
+
+// PublishUserQuotasUpdated publishes one quota updated event per user as a single batch
+func (u *UniversalEventPublisher) PublishUserQuotasUpdated(ctx context.Context, updates []UserQuotas) error {
+	events := make([]*Event, len(updates))
+	for i, update := range updates {
+		event, err := newUserQuotaUpdatedEvent(update.UserID, update.Quotas)
+		if err != nil {
+			return err
+		}
+		events[i] = event
+	}
+
+	return PublishBatch(ctx, u.eventBus, "user-events", events)
+}
+
+func newUserQuotaUpdatedEvent(userID string, quotas domain.QuotaInfo) (*Event, error) {
 	data := UserQuotaUpdatedData{
-		UserID: userID,
-		Quotas: QuotaInfoData{
-			AIDescription: QuotaData{Used: 0, Limit: 0},
-			AIVideo:       QuotaData{Used: 0, Limit: 0},
-			AutoPosting:   QuotaData{Used: 0, Limit: 0},
-		},
+		UserID:    userID,
+		Quotas:    NewQuotaInfoData(quotas),
 		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
 	}
 
@@ -98,21 +172,127 @@ func (u *UniversalEventPublisher) PublishUserQuotaUpdated(ctx context.Context, u
 		"1.0",
 		data,
 	)
+	if err != nil {
+		return nil, err
+	}
+	event.Subject = userID
+
+	return event, nil
+}
+
+// PublishProductCreated publishes product created event
+func (u *UniversalEventPublisher) PublishProductCreated(ctx context.Context, data ProductCreatedData) error {
+	stampNow(&data.CreatedAt)
+	return u.publish(ctx, "product-events", ProductCreatedEvent, "product-service", data.ProductID, data)
+}
+
+// PublishProductUpdated publishes product updated event
+func (u *UniversalEventPublisher) PublishProductUpdated(ctx context.Context, data ProductUpdatedData) error {
+	stampNow(&data.UpdatedAt)
+	return u.publish(ctx, "product-events", ProductUpdatedEvent, "product-service", data.ProductID, data)
+}
+
+// PublishProductDeleted publishes product deleted event
+func (u *UniversalEventPublisher) PublishProductDeleted(ctx context.Context, data ProductDeletedData) error {
+	stampNow(&data.DeletedAt)
+	return u.publish(ctx, "product-events", ProductDeletedEvent, "product-service", data.ProductID, data)
+}
+
+// PublishContentDrafted publishes content drafted event
+func (u *UniversalEventPublisher) PublishContentDrafted(ctx context.Context, data ContentDraftedData) error {
+	stampNow(&data.DraftedAt)
+	return u.publish(ctx, "content-events", ContentDraftedEvent, "posting-service", data.ContentID, data)
+}
+
+// PublishContentScheduled publishes content scheduled event
+func (u *UniversalEventPublisher) PublishContentScheduled(ctx context.Context, data ContentScheduledData) error {
+	stampNow(&data.ScheduledAt)
+	return u.publish(ctx, "content-events", ContentScheduledEvent, "posting-service", data.ContentID, data)
+}
+
+// PublishContentPublished publishes content published event
+func (u *UniversalEventPublisher) PublishContentPublished(ctx context.Context, data ContentPublishedData) error {
+	stampNow(&data.PublishedAt)
+	return u.publish(ctx, "content-events", ContentPublishedEvent, "posting-service", data.ContentID, data)
+}
+
+// PublishContentFailed publishes content failed event
+func (u *UniversalEventPublisher) PublishContentFailed(ctx context.Context, data ContentFailedData) error {
+	stampNow(&data.FailedAt)
+	return u.publish(ctx, "content-events", ContentFailedEvent, "posting-service", data.ContentID, data)
+}
+
+// PublishAIDescriptionRequested publishes AI description requested event
+func (u *UniversalEventPublisher) PublishAIDescriptionRequested(ctx context.Context, data AIDescriptionRequestedData) error {
+	stampNow(&data.RequestedAt)
+	return u.publish(ctx, "ai-events", AIDescriptionRequestedEvent, "product-service", data.RequestID, data)
+}
+
+// PublishAIDescriptionCompleted publishes AI description completed event
+func (u *UniversalEventPublisher) PublishAIDescriptionCompleted(ctx context.Context, data AIDescriptionCompletedData) error {
+	stampNow(&data.CompletedAt)
+	return u.publish(ctx, "ai-events", AIDescriptionCompletedEvent, "ai-content-service", data.RequestID, data)
+}
+
+// PublishAIDescriptionFailed publishes AI description failed event
+func (u *UniversalEventPublisher) PublishAIDescriptionFailed(ctx context.Context, data AIDescriptionFailedData) error {
+	stampNow(&data.FailedAt)
+	return u.publish(ctx, "ai-events", AIDescriptionFailedEvent, "ai-content-service", data.RequestID, data)
+}
+
+// PublishAIVideoRequested publishes AI video requested event
+func (u *UniversalEventPublisher) PublishAIVideoRequested(ctx context.Context, data AIVideoRequestedData) error {
+	stampNow(&data.RequestedAt)
+	return u.publish(ctx, "ai-events", AIVideoRequestedEvent, "product-service", data.RequestID, data)
+}
+
+// PublishAIVideoCompleted publishes AI video completed event
+func (u *UniversalEventPublisher) PublishAIVideoCompleted(ctx context.Context, data AIVideoCompletedData) error {
+	stampNow(&data.CompletedAt)
+	return u.publish(ctx, "ai-events", AIVideoCompletedEvent, "ai-content-service", data.RequestID, data)
+}
+
+// publish sends a version 1.0 event about subject to topic
+func (u *UniversalEventPublisher) publish(ctx context.Context, topic, eventType, source, subject string, data interface{}) error {
+	event, err := NewEvent(eventType, source, "1.0", data)
 	if err != nil {
 		return err
 	}
+	event.Subject = subject
 
-	return u.eventBus.Publish(ctx, "user-events", event)
+	return u.eventBus.Publish(ctx, topic, event)
+}
+
+// stampNow sets an empty payload timestamp to the current time
+func stampNow(timestamp *string) {
+	if *timestamp == "" {
+		*timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+}
+
+// NewUserRegisteredData is the user registered payload of a user
+func NewUserRegisteredData(user *domain.User) UserRegisteredData {
+	return UserRegisteredData{
+		UserID:    user.ID.String(),
+		Email:     user.Email,
+		FullName:  user.FullName,
+		Tier:      string(user.Tier),
+		CreatedAt: user.CreatedAt.UTC().Format(time.RFC3339),
+	}
 }
 
-// Helper function to convert various types to string
-func convertToString(v interface{}) string {
-	switch v := v.(type) {
-	case string:
-		return v
-	case domain.UserTier:
-		return string(v)
-	default:
-		return "unknown"
+// NewQuotaInfoData is the payload form of a user's quotas
+func NewQuotaInfoData(quotas domain.QuotaInfo) QuotaInfoData {
+	return QuotaInfoData{
+		AIDescription: QuotaData{Used: quotas.AIDescription.Used, Limit: quotas.AIDescription.Limit},
+		AIVideo:       QuotaData{Used: quotas.AIVideo.Used, Limit: quotas.AIVideo.Limit},
+		AutoPosting:   QuotaData{Used: quotas.AutoPosting.Used, Limit: quotas.AutoPosting.Limit},
 	}
 }
+
+var (
+	_ EventPublisher        = (*UniversalEventPublisher)(nil)
+	_ ProductEventPublisher = (*UniversalEventPublisher)(nil)
+	_ ContentEventPublisher = (*UniversalEventPublisher)(nil)
+	_ AIEventPublisher      = (*UniversalEventPublisher)(nil)
+)
@@ -0,0 +1,142 @@
+package events_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"shared/pkg/domain"
+	"shared/pkg/events"
+	"shared/pkg/events/eventstest"
+
+	"github.com/google/uuid"
+)
+
+// collect registers a typed handler for eventType that appends each decoded payload to received
+func collect[T any](router *events.Router, eventType string, received *[]interface{}) {
+	events.On(router, eventType, func(ctx context.Context, data T) error {
+		*received = append(*received, data)
+		return nil
+	})
+}
+
+// clearTimestamp checks that a payload timestamp is RFC 3339 and blanks it for comparison
+func clearTimestamp(t *testing.T, timestamp *string) {
+	t.Helper()
+
+	if _, err := time.Parse(time.RFC3339, *timestamp); err != nil {
+		t.Errorf("timestamp %q is not RFC 3339: %v", *timestamp, err)
+	}
+	*timestamp = ""
+}
+
+func TestUniversalEventPublisherRoundTrip(t *testing.T) {
+	bus := events.NewMemoryEventBus(events.WithSynchronousDelivery())
+	publisher := events.NewUniversalEventPublisher(bus)
+	ctx := context.Background()
+
+	var received []interface{}
+	router := events.NewRouter()
+	collect[events.UserRegisteredData](router, events.UserRegisteredEvent, &received)
+	collect[events.UserTierUpgradedData](router, events.UserTierUpgradedEvent, &received)
+	collect[events.UserQuotaProvisionedData](router, events.UserQuotaProvisionedEvent, &received)
+	collect[events.UserQuotaProvisioningFailedData](router, events.UserQuotaProvisioningFailedEvent, &received)
+	collect[events.UserTierRevertedData](router, events.UserTierRevertedEvent, &received)
+	collect[events.UserQuotaUpdatedData](router, events.UserQuotaUpdatedEvent, &received)
+	if err := events.NewUniversalEventSubscriber(bus).SubscribeToUserEvents(ctx, router.Handle); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &domain.User{
+		ID:        uuid.MustParse(eventstest.FixtureUserID),
+		Email:     "jane@example.com",
+		FullName:  "Jane Doe",
+		Tier:      domain.UserTierFree,
+		CreatedAt: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+	}
+	user.DowngradeToFree()
+	user.AIDescriptionQuotaUsed = 2
+	other := domain.QuotaInfo{
+		AIDescription: domain.Quota{Used: 7, Limit: 50},
+		AIVideo:       domain.Quota{Used: 1, Limit: 10},
+		AutoPosting:   domain.Quota{Used: 0, Limit: 30},
+	}
+
+	publish := []func() error{
+		func() error { return publisher.PublishUserRegistered(ctx, user) },
+		func() error {
+			return publisher.PublishUserTierUpgraded(ctx, eventstest.FixtureUserID, domain.UserTierFree, domain.UserTierPro)
+		},
+		func() error {
+			return publisher.PublishUserQuotaProvisioned(ctx, eventstest.FixtureUserID, domain.UserTierPro)
+		},
+		func() error {
+			return publisher.PublishUserQuotaProvisioningFailed(ctx, eventstest.FixtureUserID, "user not found")
+		},
+		func() error {
+			return publisher.PublishUserTierReverted(ctx, eventstest.FixtureUserID, domain.UserTierPro, domain.UserTierFree, "timed out")
+		},
+		func() error {
+			return publisher.PublishUserQuotaUpdated(ctx, eventstest.FixtureUserID, user.GetQuotaInfo())
+		},
+		func() error {
+			return publisher.PublishUserQuotasUpdated(ctx, []events.UserQuotas{
+				{UserID: eventstest.FixtureUserID, Quotas: user.GetQuotaInfo()},
+				{UserID: "other", Quotas: other},
+			})
+		},
+	}
+	for i, fn := range publish {
+		if err := fn(); err != nil {
+			t.Fatalf("publish %d: %v", i, err)
+		}
+	}
+
+	userQuotas := events.QuotaInfoData{
+		AIDescription: events.QuotaData{Used: 2, Limit: user.AIDescriptionQuotaLimit},
+		AIVideo:       events.QuotaData{Used: 0, Limit: user.AIVideoQuotaLimit},
+		AutoPosting:   events.QuotaData{Used: 0, Limit: user.AutoPostingQuotaLimit},
+	}
+	want := []interface{}{
+		events.UserRegisteredData{UserID: eventstest.FixtureUserID, Email: "jane@example.com", FullName: "Jane Doe", Tier: "free", CreatedAt: "2024-01-15T10:30:00Z"},
+		events.UserTierUpgradedData{UserID: eventstest.FixtureUserID, OldTier: "free", NewTier: "pro"},
+		events.UserQuotaProvisionedData{UserID: eventstest.FixtureUserID, Tier: "pro"},
+		events.UserQuotaProvisioningFailedData{UserID: eventstest.FixtureUserID, Reason: "user not found"},
+		events.UserTierRevertedData{UserID: eventstest.FixtureUserID, FromTier: "pro", ToTier: "free", Reason: "timed out"},
+		events.UserQuotaUpdatedData{UserID: eventstest.FixtureUserID, Quotas: userQuotas},
+		events.UserQuotaUpdatedData{UserID: eventstest.FixtureUserID, Quotas: userQuotas},
+		events.UserQuotaUpdatedData{UserID: "other", Quotas: events.QuotaInfoData{
+			AIDescription: events.QuotaData{Used: 7, Limit: 50},
+			AIVideo:       events.QuotaData{Used: 1, Limit: 10},
+			AutoPosting:   events.QuotaData{Used: 0, Limit: 30},
+		}},
+	}
+	if len(received) != len(want) {
+		t.Fatalf("received %d events, want %d: %+v", len(received), len(want), received)
+	}
+
+	for i, data := range received {
+		switch d := data.(type) {
+		case events.UserTierUpgradedData:
+			clearTimestamp(t, &d.UpgradedAt)
+			data = d
+		case events.UserQuotaProvisionedData:
+			clearTimestamp(t, &d.ProvisionedAt)
+			data = d
+		case events.UserQuotaProvisioningFailedData:
+			clearTimestamp(t, &d.FailedAt)
+			data = d
+		case events.UserTierRevertedData:
+			clearTimestamp(t, &d.RevertedAt)
+			data = d
+		case events.UserQuotaUpdatedData:
+			clearTimestamp(t, &d.UpdatedAt)
+			data = d
+		}
+
+		if !reflect.DeepEqual(data, want[i]) {
+			t.Errorf("event %d = %+v, want %+v", i, data, want[i])
+		}
+	}
+}
@@ -17,16 +17,31 @@ func NewUniversalEventSubscriber(eventBus EventBus) *UniversalEventSubscriber {
 }
 
 // SubscribeToUserEvents subscribes to user-related events
-func (u *UniversalEventSubscriber) SubscribeToUserEvents(ctx context.Context, handler EventHandler) error {
-	return u.eventBus.Subscribe(ctx, "user-events", handler)
+func (u *UniversalEventSubscriber) SubscribeToUserEvents(ctx context.Context, handler EventHandler, opts ...SubscribeOption) error {
+	return u.eventBus.Subscribe(ctx, "user-events", handler, opts...)
 }
 
 // SubscribeToProductEvents subscribes to product-related events
-func (u *UniversalEventSubscriber) SubscribeToProductEvents(ctx context.Context, handler EventHandler) error {
-	return u.eventBus.Subscribe(ctx, "product-events", handler)
+func (u *UniversalEventSubscriber) SubscribeToProductEvents(ctx context.Context, handler EventHandler, opts ...SubscribeOption) error {
+	return u.eventBus.Subscribe(ctx, "product-events", handler, opts...)
+}
+
+// SubscribeToContentEvents subscribes to social media post events
+func (u *UniversalEventSubscriber) SubscribeToContentEvents(ctx context.Context, handler EventHandler, opts ...SubscribeOption) error {
+	return u.eventBus.Subscribe(ctx, "content-events", handler, opts...)
 }
 
 // SubscribeToAIEvents subscribes to AI-related events
-func (u *UniversalEventSubscriber) SubscribeToAIEvents(ctx context.Context, handler EventHandler) error {
-	return u.eventBus.Subscribe(ctx, "ai-events", handler)
+func (u *UniversalEventSubscriber) SubscribeToAIEvents(ctx context.Context, handler EventHandler, opts ...SubscribeOption) error {
+	return u.eventBus.Subscribe(ctx, "ai-events", handler, opts...)
+}
+
+// SubscribeToPattern subscribes to every topic and event type matching pattern, e.g. "user.*" or "*.upgraded"
+func (u *UniversalEventSubscriber) SubscribeToPattern(ctx context.Context, pattern string, handler EventHandler, opts ...SubscribeOption) error {
+	return u.eventBus.Subscribe(ctx, pattern, handler, opts...)
+}
+
+// SubscribeToAll subscribes to every event on every topic, e.g. for audit and analytics consumers
+func (u *UniversalEventSubscriber) SubscribeToAll(ctx context.Context, handler EventHandler, opts ...SubscribeOption) error {
+	return u.eventBus.Subscribe(ctx, TopicWildcard, handler, opts...)
 }
@@ -22,7 +22,8 @@ func ExtractKafkaTrace(ctx context.Context, headers map[string]string) context.C
 
 func StartKafkaConsumerSpan(ctx context.Context, topic string, partition int, offset int64) (context.Context, trace.Span) {
 	spanName := fmt.Sprintf("kafka.consume %s", topic)
-	ctx, span := Tracer.Start(ctx, spanName,
+	ctx, span := tracer().Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindConsumer),
 		trace.WithAttributes(
 			attribute.String("messaging.system", "kafka"),
 			attribute.String("messaging.destination", topic),
@@ -36,7 +37,8 @@ func StartKafkaConsumerSpan(ctx context.Context, topic string, partition int, of
 
 func StartKafkaProducerSpan(ctx context.Context, topic string, key string) (context.Context, trace.Span) {
 	spanName := fmt.Sprintf("kafka.produce %s", topic)
-	ctx, span := Tracer.Start(ctx, spanName,
+	ctx, span := tracer().Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindProducer),
 		trace.WithAttributes(
 			attribute.String("messaging.system", "kafka"),
 			attribute.String("messaging.destination", topic),
@@ -0,0 +1,37 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartConsumerSpan starts a consumer span for brokers without a dedicated helper
+func StartConsumerSpan(ctx context.Context, system, topic string) (context.Context, trace.Span) {
+	spanName := fmt.Sprintf("%s.consume %s", system, topic)
+	ctx, span := tracer().Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", system),
+			attribute.String("messaging.destination", topic),
+			attribute.String("messaging.operation", "receive"),
+		),
+	)
+	return ctx, span
+}
+
+// StartProducerSpan starts a producer span for brokers without a dedicated helper
+func StartProducerSpan(ctx context.Context, system, topic string) (context.Context, trace.Span) {
+	spanName := fmt.Sprintf("%s.produce %s", system, topic)
+	ctx, span := tracer().Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", system),
+			attribute.String("messaging.destination", topic),
+			attribute.String("messaging.operation", "send"),
+		),
+	)
+	return ctx, span
+}
@@ -25,6 +25,14 @@ type Config struct {
 }
 
 func InitTracer(cfg Config) (func(context.Context) error, error) {
+	// Propagate W3C trace context even when spans are not exported
+	otel.SetTextMapPropagator(
+		propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		),
+	)
+
 	if !cfg.Enabled {
 		// Return a no-op tracer provider
 		tp := sdktrace.NewTracerProvider()
@@ -63,18 +71,20 @@ func InitTracer(cfg Config) (func(context.Context) error, error) {
 		sdktrace.WithResource(res),
 	)
 
-	// Set global tracer provider and propagator
+	// Set global tracer provider
 	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(
-		propagation.NewCompositeTextMapPropagator(
-			propagation.TraceContext{},
-			propagation.Baggage{},
-		),
-	)
 
 	Tracer = tp.Tracer(cfg.ServiceName)
 
 	return tp.Shutdown, nil
 }
 
+// tracer returns the configured tracer, falling back to the global provider before InitTracer runs
+func tracer() trace.Tracer {
+	if Tracer != nil {
+		return Tracer
+	}
+	return otel.Tracer("shared")
+}
+
 // ... rest of the file remains the same